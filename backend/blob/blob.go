@@ -0,0 +1,126 @@
+// Package blob stores generated files, such as export artifacts, under
+// string keys. LocalStore keeps them in a directory; other backends, such as
+// an object store, can implement Store.
+package blob
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotFound is returned when no blob has the key
+var ErrNotFound = errors.New("blob: not found")
+
+// ErrInvalidKey is returned for keys that aren't a single path element
+var ErrInvalidKey = errors.New("blob: invalid key")
+
+// Store saves and serves blobs
+type Store interface {
+	// Create returns a writer for a new blob. The blob becomes visible
+	// under key once the writer is closed without error; Abort on a
+	// failed write discards it.
+	Create(key string) (Writer, error)
+	// Open returns a blob's contents and size
+	Open(key string) (io.ReadCloser, int64, error)
+	// Delete removes a blob; deleting a missing blob is not an error
+	Delete(key string) error
+}
+
+// Writer writes a blob being created
+type Writer interface {
+	io.WriteCloser
+	// Abort discards the blob
+	Abort() error
+}
+
+// LocalStore keeps blobs as files in a directory
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore stores blobs in dir, creating it if needed
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if key == "" || key != filepath.Base(key) || strings.HasPrefix(key, ".") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.dir, key), nil
+}
+
+// Create writes the blob to a temporary file that is renamed into place on
+// Close, so readers never see a partial file
+func (s *LocalStore) Create(key string) (Writer, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	return &localWriter{File: f, path: path}, nil
+}
+
+// Open opens the blob's file
+func (s *LocalStore) Open(key string) (io.ReadCloser, int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, 0, ErrNotFound
+		}
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+// Delete removes the blob's file
+func (s *LocalStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+type localWriter struct {
+	*os.File
+	path string
+}
+
+func (w *localWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	if err := os.Rename(w.File.Name(), w.path); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	return nil
+}
+
+func (w *localWriter) Abort() error {
+	w.File.Close()
+	return os.Remove(w.File.Name())
+}
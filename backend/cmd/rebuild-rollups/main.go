@@ -0,0 +1,117 @@
+// Command rebuild-rollups regenerates the pre-aggregated analytics rollups
+// from the raw responses, for one form or for every form. Responses stored
+// before their device, browser, OS and search text were recorded have them
+// filled in first, and when FIELD_ENCRYPTION_KEY_FILE is set, answers to PII
+// fields still stored in plain text are encrypted.
+//
+//	go run ./cmd/rebuild-rollups [-form <formId>]
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/envelope"
+	"form-builder-backend/services"
+)
+
+func main() {
+	formFlag := flag.String("form", "", "ID of the form to rebuild (default: all forms)")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+
+	mongoURI := os.Getenv("MONGO_URI")
+	if mongoURI == "" {
+		mongoURI = "mongodb://localhost:27017"
+	}
+
+	ctx := context.Background()
+	connectCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(connectCtx, options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		log.Fatal("Failed to connect to MongoDB:", err)
+	}
+	defer client.Disconnect(ctx)
+
+	var fieldCipher *services.FieldCipher
+	if path := os.Getenv("FIELD_ENCRYPTION_KEY_FILE"); path != "" {
+		keys, err := envelope.LoadKeyFile(path)
+		if err != nil {
+			log.Fatal("Failed to load field encryption keys:", err)
+		}
+		fieldCipher = services.NewFieldCipher(keys)
+	}
+
+	database := client.Database("formbuilder")
+	analyticsService := services.NewAnalyticsService(database)
+	responseService := services.NewResponseService(database, fieldCipher)
+	if err := analyticsService.EnsureIndexes(ctx); err != nil {
+		log.Fatal("Failed to create analytics indexes:", err)
+	}
+
+	var formIDs []primitive.ObjectID
+	if *formFlag != "" {
+		formID, err := primitive.ObjectIDFromHex(*formFlag)
+		if err != nil {
+			log.Fatal("Invalid form ID:", err)
+		}
+		formIDs = append(formIDs, formID)
+	} else {
+		ids, err := database.Collection("forms").Distinct(ctx, "_id", bson.M{})
+		if err != nil {
+			log.Fatal("Failed to list forms:", err)
+		}
+		for _, id := range ids {
+			if formID, ok := id.(primitive.ObjectID); ok {
+				formIDs = append(formIDs, formID)
+			}
+		}
+	}
+
+	for _, formID := range formIDs {
+		start := time.Now()
+		backfilled, err := analyticsService.BackfillClientInfo(ctx, formID)
+		if err != nil {
+			log.Fatalf("Failed to backfill client info for form %s: %v", formID.Hex(), err)
+		}
+		if backfilled > 0 {
+			log.Printf("Stored device, browser and OS on %d older responses of form %s", backfilled, formID.Hex())
+		}
+
+		encrypted, err := responseService.BackfillEncryption(ctx, formID)
+		if err != nil {
+			log.Fatalf("Failed to encrypt PII answers for form %s: %v", formID.Hex(), err)
+		}
+		if encrypted > 0 {
+			log.Printf("Encrypted PII answers of %d older records of form %s", encrypted, formID.Hex())
+		}
+
+		indexed, err := responseService.BackfillSearchText(ctx, formID)
+		if err != nil {
+			log.Fatalf("Failed to backfill search text for form %s: %v", formID.Hex(), err)
+		}
+		if indexed > 0 {
+			log.Printf("Indexed %d older responses of form %s for search", indexed, formID.Hex())
+		}
+
+		processed, err := analyticsService.RebuildRollups(ctx, formID)
+		if err != nil {
+			log.Fatalf("Failed to rebuild rollups for form %s: %v", formID.Hex(), err)
+		}
+		log.Printf("Rebuilt rollups for form %s from %d responses in %s", formID.Hex(), processed, time.Since(start).Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,208 @@
+// Package envelope encrypts values with envelope encryption. Each record gets
+// its own data key, which is stored alongside it wrapped by a master key that
+// never leaves its KeyProvider. LocalKeyProvider reads master keys from a
+// file; a KMS can implement KeyProvider instead.
+package envelope
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// KeySize is the length of master and data keys, for AES-256
+const KeySize = 32
+
+// sealedPrefix marks a value sealed by SealValue
+const sealedPrefix = "enc:v1:"
+
+// ErrUnknownKey is returned when unwrapping a data key whose master key the
+// provider doesn't hold
+var ErrUnknownKey = errors.New("envelope: unknown master key")
+
+// ErrDecrypt is returned for ciphertext that fails authentication
+var ErrDecrypt = errors.New("envelope: decryption failed")
+
+// DataKey is a fresh data key, in plaintext for encrypting and wrapped by the
+// master key KeyID for storing
+type DataKey struct {
+	KeyID     string
+	Plaintext []byte
+	Wrapped   []byte
+}
+
+// KeyProvider holds the master keys that wrap data keys
+type KeyProvider interface {
+	// GenerateDataKey returns a new data key wrapped by the current master key
+	GenerateDataKey(ctx context.Context) (*DataKey, error)
+	// UnwrapDataKey returns the plaintext of a data key wrapped by the
+	// master key keyID
+	UnwrapDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyProvider wraps data keys with master keys loaded from a file
+type LocalKeyProvider struct {
+	keys    map[string][]byte // keyed by key ID
+	current string
+}
+
+// LoadKeyFile reads master keys from a file with one base64-encoded 32-byte
+// key per line, such as the output of `openssl rand -base64 32`. The first
+// key wraps new data keys; later ones still unwrap existing data keys, so a
+// key can be rotated by adding a new first line. Blank lines and lines
+// starting with # are ignored.
+func LoadKeyFile(path string) (*LocalKeyProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	provider := &LocalKeyProvider{keys: make(map[string][]byte)}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(text)
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("envelope: %s line %d is not a base64-encoded %d-byte key", path, line, KeySize)
+		}
+		id := KeyID(key)
+		if provider.current == "" {
+			provider.current = id
+		}
+		provider.keys[id] = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if provider.current == "" {
+		return nil, fmt.Errorf("envelope: %s has no keys", path)
+	}
+	return provider, nil
+}
+
+// KeyID identifies a master key without revealing it
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// GenerateDataKey creates a random data key and wraps it with the first key
+// in the file
+func (p *LocalKeyProvider) GenerateDataKey(ctx context.Context) (*DataKey, error) {
+	plaintext := make([]byte, KeySize)
+	if _, err := rand.Read(plaintext); err != nil {
+		return nil, err
+	}
+	wrapped, err := Seal(p.keys[p.current], plaintext)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{KeyID: p.current, Plaintext: plaintext, Wrapped: wrapped}, nil
+}
+
+// UnwrapDataKey decrypts a data key with the master key it was wrapped by
+func (p *LocalKeyProvider) UnwrapDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	master, ok := p.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return Open(master, wrapped)
+}
+
+// Seal encrypts plaintext with AES-256-GCM under key, returning the random
+// nonce followed by the ciphertext
+func Seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts the output of Seal
+func Open(key, sealed []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealValue encrypts a JSON-encodable value into a string that can be stored
+// in its place and recognized with IsSealed
+func SealValue(key []byte, v interface{}) (string, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := Seal(key, plaintext)
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenValue decrypts a value sealed by SealValue. Numbers come back as
+// float64 and lists as []interface{}, as from JSON.
+func OpenValue(key []byte, s string) (interface{}, error) {
+	if !strings.HasPrefix(s, sealedPrefix) {
+		return nil, ErrDecrypt
+	}
+	sealed, err := base64.StdEncoding.DecodeString(s[len(sealedPrefix):])
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := Open(key, sealed)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	if err := json.Unmarshal(plaintext, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// IsSealed reports whether v is a value sealed by SealValue
+func IsSealed(v interface{}) bool {
+	s, ok := v.(string)
+	return ok && strings.HasPrefix(s, sealedPrefix)
+}
+
+// SealedPattern is a regular expression matching sealed values, for queries
+// that must skip them
+const SealedPattern = "^" + sealedPrefix
@@ -0,0 +1,243 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// writeKeyFile writes lines to a key file and returns its path
+func writeKeyFile(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	key := newKey(t)
+	for _, plaintext := range [][]byte{{}, []byte("x"), bytes.Repeat([]byte("secret "), 1000)} {
+		sealed, err := Seal(key, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plaintext) > 0 && bytes.Contains(sealed, plaintext) {
+			t.Errorf("sealed output contains the plaintext")
+		}
+		opened, err := Open(key, sealed)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if !bytes.Equal(opened, plaintext) {
+			t.Errorf("Open = %q, want %q", opened, plaintext)
+		}
+	}
+
+	// Each seal draws a fresh nonce
+	a, _ := Seal(key, []byte("same"))
+	b, _ := Seal(key, []byte("same"))
+	if bytes.Equal(a, b) {
+		t.Error("sealing the same plaintext twice gave the same output")
+	}
+}
+
+func TestOpenDetectsTampering(t *testing.T) {
+	key := newKey(t)
+	sealed, err := Seal(key, []byte("account 4411"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    []byte
+		sealed []byte
+	}{
+		{"nonce flipped", key, flip(sealed, 0)},
+		{"ciphertext flipped", key, flip(sealed, 14)},
+		{"tag flipped", key, flip(sealed, len(sealed)-1)},
+		{"truncated", key, sealed[:len(sealed)-1]},
+		{"shorter than a nonce", key, sealed[:4]},
+		{"wrong key", newKey(t), sealed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Open(tt.key, tt.sealed); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("Open = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+// flip returns a copy of b with one bit of byte i inverted
+func flip(b []byte, i int) []byte {
+	c := append([]byte(nil), b...)
+	c[i] ^= 1
+	return c
+}
+
+func TestSealValueRoundTrip(t *testing.T) {
+	key := newKey(t)
+	tests := []struct {
+		value interface{}
+		want  interface{}
+	}{
+		{"jane@example.com", "jane@example.com"},
+		{"", ""},
+		{42, 42.0}, // numbers come back as float64
+		{true, true},
+		{nil, nil},
+		{[]string{"a", "b"}, []interface{}{"a", "b"}},
+		{map[string]interface{}{"street": "Main St"}, map[string]interface{}{"street": "Main St"}},
+	}
+	for _, tt := range tests {
+		sealed, err := SealValue(key, tt.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsSealed(sealed) {
+			t.Errorf("IsSealed(SealValue(%v)) = false", tt.value)
+		}
+		got, err := OpenValue(key, sealed)
+		if err != nil {
+			t.Fatalf("OpenValue(%v): %v", tt.value, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("OpenValue = %#v, want %#v", got, tt.want)
+		}
+	}
+}
+
+func TestOpenValueRejects(t *testing.T) {
+	key := newKey(t)
+	sealed, err := SealValue(key, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, sealedPrefix))
+
+	tests := []struct {
+		name  string
+		key   []byte
+		value string
+	}{
+		{"plain text", key, "secret"},
+		{"not base64", key, sealedPrefix + "!!!"},
+		{"tampered", key, sealedPrefix + base64.StdEncoding.EncodeToString(flip(raw, len(raw)-1))},
+		{"wrong key", newKey(t), sealed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := OpenValue(tt.key, tt.value); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("OpenValue = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestIsSealed(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  bool
+	}{
+		{sealedPrefix + "AAAA", true},
+		{"enc:v2:AAAA", false},
+		{"hello", false},
+		{42, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsSealed(tt.value); got != tt.want {
+			t.Errorf("IsSealed(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLocalKeyProviderRotation(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKeyBytes := newKey(t), newKey(t)
+	oldLine := base64.StdEncoding.EncodeToString(oldKey)
+	newLine := base64.StdEncoding.EncodeToString(newKeyBytes)
+
+	before, err := LoadKeyFile(writeKeyFile(t, "# field keys", oldLine))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey, err := before.GenerateDataKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataKey.KeyID != KeyID(oldKey) || len(dataKey.Plaintext) != KeySize {
+		t.Fatalf("data key = %s with %d bytes, want %s with %d", dataKey.KeyID, len(dataKey.Plaintext), KeyID(oldKey), KeySize)
+	}
+
+	// A new first line wraps new data keys, and the old one still unwraps
+	after, err := LoadKeyFile(writeKeyFile(t, newLine, "", "  "+oldLine+"  "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := after.UnwrapDataKey(ctx, dataKey.KeyID, dataKey.Wrapped)
+	if err != nil {
+		t.Fatalf("UnwrapDataKey after rotation: %v", err)
+	}
+	if !bytes.Equal(unwrapped, dataKey.Plaintext) {
+		t.Error("unwrapped data key differs from the one generated")
+	}
+	rotated, err := after.GenerateDataKey(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.KeyID != KeyID(newKeyBytes) {
+		t.Errorf("new data keys are wrapped by %s, want the first key %s", rotated.KeyID, KeyID(newKeyBytes))
+	}
+
+	// The old file doesn't hold the new key
+	if _, err := before.UnwrapDataKey(ctx, rotated.KeyID, rotated.Wrapped); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("UnwrapDataKey with an unknown key ID = %v, want ErrUnknownKey", err)
+	}
+	// A wrapped key claiming the wrong master key fails authentication
+	if _, err := after.UnwrapDataKey(ctx, rotated.KeyID, dataKey.Wrapped); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("UnwrapDataKey under the wrong master key = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestLoadKeyFileRejects(t *testing.T) {
+	short := base64.StdEncoding.EncodeToString(make([]byte, 16))
+	tests := []struct {
+		name  string
+		lines []string
+	}{
+		{"empty", nil},
+		{"only comments", []string{"# none yet", ""}},
+		{"not base64", []string{"not a key!"}},
+		{"wrong length", []string{short}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LoadKeyFile(writeKeyFile(t, tt.lines...)); err == nil {
+				t.Error("LoadKeyFile accepted the file")
+			}
+		})
+	}
+	if _, err := LoadKeyFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("LoadKeyFile accepted a missing file")
+	}
+}
@@ -0,0 +1,222 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+)
+
+// Data section field types
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// maxDepth bounds nesting so a corrupt file can't recurse forever
+const maxDepth = 32
+
+var errTruncated = errors.New("unexpected end of data")
+
+// decoder reads values from the data section of a MaxMind DB
+type decoder struct {
+	buf []byte
+}
+
+// decode reads the value at offset, returning it and the offset after it.
+// Maps decode to map[string]interface{} and arrays to []interface{}.
+func (d decoder) decode(offset uint) (interface{}, uint, error) {
+	return d.decodeAt(offset, 0)
+}
+
+func (d decoder) decodeAt(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("data nested too deeply")
+	}
+
+	fieldType, size, offset, err := d.controlByte(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if fieldType == typePointer {
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decodeAt(target, depth+1)
+		return value, next, err
+	}
+
+	// Every entry takes at least a byte, so a corrupt size can't make the
+	// map or array below allocate far more than the file holds
+	if (fieldType == typeMap || fieldType == typeArray) && size > uint(len(d.buf))-offset {
+		return nil, 0, errTruncated
+	}
+
+	switch fieldType {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decodeAt(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			keyStr, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			value, next, err := d.decodeAt(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[keyStr] = value
+			offset = next
+		}
+		return m, offset, nil
+
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decodeAt(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buf)) || end < offset {
+		return nil, 0, errTruncated
+	}
+	b := d.buf[offset:end]
+
+	switch fieldType {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes:
+		return append([]byte(nil), b...), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), end, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errors.New("invalid integer size")
+		}
+		return uint64(beUint(b)), end, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errors.New("invalid integer size")
+		}
+		return int32(beUint(b)), end, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, errors.New("invalid integer size")
+		}
+		return new(big.Int).SetBytes(b), end, nil
+	default:
+		return nil, 0, errors.New("unsupported data type")
+	}
+}
+
+// controlByte reads a field's type and payload size, returning the offset
+// of the payload
+func (d decoder) controlByte(offset uint) (fieldType int, size uint, next uint, err error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, errTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	fieldType = int(ctrl >> 5)
+	if fieldType == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, errTruncated
+		}
+		fieldType = 7 + int(d.buf[offset])
+		offset++
+		if fieldType <= typeMap || fieldType == typeContainer || fieldType == typeEndMarker {
+			return 0, 0, 0, errors.New("invalid extended type")
+		}
+	}
+
+	size = uint(ctrl & 0x1F)
+	if fieldType == typePointer || size < 29 {
+		return fieldType, size, offset, nil
+	}
+
+	extra := size - 28 // 1, 2 or 3 more bytes
+	if offset+extra > uint(len(d.buf)) {
+		return 0, 0, 0, errTruncated
+	}
+	n := beUint(d.buf[offset : offset+extra])
+	switch extra {
+	case 1:
+		size = 29 + n
+	case 2:
+		size = 285 + n
+	default:
+		size = 65821 + n
+	}
+	return fieldType, size, offset + extra, nil
+}
+
+// pointer decodes a pointer whose control byte held sizeBits, returning the
+// data section offset it points to and the offset after the pointer
+func (d decoder) pointer(sizeBits uint, offset uint) (uint, uint, error) {
+	length := (sizeBits>>3)&0x3 + 1
+	if offset+length > uint(len(d.buf)) {
+		return 0, 0, errTruncated
+	}
+	b := d.buf[offset : offset+length]
+	low := sizeBits & 0x7
+
+	var target uint
+	switch length {
+	case 1:
+		target = low<<8 | beUint(b)
+	case 2:
+		target = (low<<16 | beUint(b)) + 2048
+	case 3:
+		target = (low<<24 | beUint(b)) + 526336
+	default:
+		target = beUint(b)
+	}
+	return target, offset + length, nil
+}
+
+// beUint reads a big-endian unsigned integer of up to 8 bytes
+func beUint(b []byte) uint {
+	n := uint(0)
+	for _, c := range b {
+		n = n<<8 | uint(c)
+	}
+	return n
+}
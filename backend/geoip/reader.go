@@ -0,0 +1,219 @@
+// Package geoip looks up the location of IP addresses in a local MaxMind DB
+// (.mmdb) file, such as GeoLite2-City or GeoLite2-Country. Lookups never
+// touch the network.
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of the file
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the number of zero bytes between the search tree
+// and the data section
+const dataSectionSeparator = 16
+
+// Location is where an IP address is registered
+type Location struct {
+	Country     string `json:"country"`     // ISO 3166-1 alpha-2 code
+	CountryName string `json:"countryName"` // English name
+	Region      string `json:"region"`      // English name of the first subdivision, if the database has them
+	City        string `json:"city"`        // English name, if the database has cities
+}
+
+// Reader looks up IP addresses in a MaxMind DB file held in memory.
+// It is safe for concurrent use, and a nil *Reader finds nothing.
+type Reader struct {
+	buf        []byte
+	decoder    decoder
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint
+}
+
+// Open reads a MaxMind DB file into memory
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes parses a MaxMind DB held in buf
+func FromBytes(buf []byte) (*Reader, error) {
+	markerAt := bytes.LastIndex(buf, metadataMarker)
+	if markerAt < 0 {
+		return nil, errors.New("geoip: not a MaxMind DB file, metadata marker not found")
+	}
+
+	metaDecoder := decoder{buf: buf[markerAt+len(metadataMarker):]}
+	metaValue, _, err := metaDecoder.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("geoip: reading metadata: %w", err)
+	}
+	meta, ok := metaValue.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("geoip: metadata is not a map")
+	}
+
+	r := &Reader{
+		buf:        buf,
+		nodeCount:  uintValue(meta["node_count"]),
+		recordSize: uintValue(meta["record_size"]),
+		ipVersion:  uintValue(meta["ip_version"]),
+	}
+	if major := uintValue(meta["binary_format_major_version"]); major != 2 {
+		return nil, fmt.Errorf("geoip: unsupported binary format version %d", major)
+	}
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("geoip: unsupported record size %d", r.recordSize)
+	}
+
+	// Checked before multiplying so a huge node count can't wrap around
+	if r.nodeCount > uint(markerAt) {
+		return nil, errors.New("geoip: search tree is larger than the file")
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	dataStart := treeSize + dataSectionSeparator
+	if dataStart > uint(markerAt) {
+		return nil, errors.New("geoip: search tree is larger than the file")
+	}
+	r.decoder = decoder{buf: buf[dataStart:markerAt]}
+
+	// IPv4 addresses live under ::/96 in IPv6 databases
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.nodeCount; i++ {
+			node = r.readNode(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// Lookup finds the location of ip. ok is false when the address isn't in
+// the database or r is nil.
+func (r *Reader) Lookup(ip net.IP) (loc Location, ok bool, err error) {
+	if r == nil || ip == nil {
+		return Location{}, false, nil
+	}
+
+	record, err := r.lookupRecord(ip)
+	if err != nil || record == 0 {
+		return Location{}, false, err
+	}
+
+	offset := record - r.nodeCount - dataSectionSeparator
+	value, _, err := r.decoder.decode(offset)
+	if err != nil {
+		return Location{}, false, fmt.Errorf("geoip: decoding record: %w", err)
+	}
+
+	data, _ := value.(map[string]interface{})
+	loc = Location{
+		Country:     stringAt(data, "country", "iso_code"),
+		CountryName: stringAt(data, "country", "names", "en"),
+		City:        stringAt(data, "city", "names", "en"),
+	}
+	if loc.Country == "" {
+		// Anycast and EU-wide ranges only carry the registered country
+		loc.Country = stringAt(data, "registered_country", "iso_code")
+		loc.CountryName = stringAt(data, "registered_country", "names", "en")
+	}
+	if subdivisions, ok := data["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		subdivision, _ := subdivisions[0].(map[string]interface{})
+		loc.Region = stringAt(subdivision, "names", "en")
+	}
+
+	return loc, loc.Country != "", nil
+}
+
+// LookupString is Lookup for a textual address
+func (r *Reader) LookupString(address string) (Location, bool, error) {
+	return r.Lookup(net.ParseIP(address))
+}
+
+// lookupRecord walks the search tree and returns the data record ip points
+// to, or 0 when it isn't in the database
+func (r *Reader) lookupRecord(ip net.IP) (uint, error) {
+	node := uint(0)
+	bitCount := 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+		bitCount = 32
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return 0, nil
+	}
+
+	for i := 0; i < bitCount && node < r.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i%8))) & 1
+		node = r.readNode(node, bit)
+	}
+
+	switch {
+	case node == r.nodeCount:
+		return 0, nil
+	case node > r.nodeCount:
+		return node, nil
+	default:
+		return 0, errors.New("geoip: invalid search tree")
+	}
+}
+
+// readNode returns the left (bit 0) or right (bit 1) record of a tree node
+func (r *Reader) readNode(node, bit uint) uint {
+	b := r.buf
+	switch r.recordSize {
+	case 24:
+		offset := node*6 + bit*3
+		return uint(b[offset])<<16 | uint(b[offset+1])<<8 | uint(b[offset+2])
+	case 28:
+		offset := node * 7
+		if bit == 0 {
+			return uint(b[offset+3]&0xF0)<<20 | uint(b[offset])<<16 | uint(b[offset+1])<<8 | uint(b[offset+2])
+		}
+		return uint(b[offset+3]&0x0F)<<24 | uint(b[offset+4])<<16 | uint(b[offset+5])<<8 | uint(b[offset+6])
+	default:
+		offset := node*8 + bit*4
+		return uint(b[offset])<<24 | uint(b[offset+1])<<16 | uint(b[offset+2])<<8 | uint(b[offset+3])
+	}
+}
+
+// stringAt follows a path of map keys to a string
+func stringAt(data map[string]interface{}, path ...string) string {
+	var value interface{} = data
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = m[key]
+	}
+	s, _ := value.(string)
+	return s
+}
+
+func uintValue(v interface{}) uint {
+	switch n := v.(type) {
+	case uint64:
+		return uint(n)
+	case uint32:
+		return uint(n)
+	case uint16:
+		return uint(n)
+	case int32:
+		return uint(n)
+	}
+	return 0
+}
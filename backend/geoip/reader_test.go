@@ -0,0 +1,380 @@
+package geoip
+
+import (
+	"bytes"
+	"net"
+	"sort"
+	"testing"
+)
+
+// mmdbNetwork is a network and the record stored for it in a test database
+type mmdbNetwork struct {
+	cidr   string
+	record map[string]interface{}
+}
+
+// buildMMDB writes a MaxMind DB holding networks, the way the real writer
+// lays it out: the search tree, 16 zero bytes, the data section and then
+// the metadata after its marker
+func buildMMDB(t *testing.T, ipVersion, recordSize int, networks ...mmdbNetwork) []byte {
+	t.Helper()
+
+	// Records are encoded first so the tree can point at them
+	var data []byte
+	offsets := make([]uint, len(networks))
+	for i, network := range networks {
+		offsets[i] = uint(len(data))
+		data = append(data, encodeValue(network.record)...)
+	}
+
+	// Each node holds its two children: a node index, or a data record
+	type child struct {
+		node   int // 0 when empty or a record
+		record int // 1 + the network's index
+	}
+	nodes := [][2]child{{}}
+	for i, network := range networks {
+		_, ipNet, err := net.ParseCIDR(network.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := ipNet.IP
+		ones, _ := ipNet.Mask.Size()
+		if ipVersion == 6 && len(ip) == net.IPv4len {
+			ip = ip.To16()
+			ones += 96
+			// Keep IPv4 under ::/96 rather than ::ffff:0:0/96
+			ip[10], ip[11] = 0, 0
+		}
+		node := 0
+		for bit := 0; bit < ones; bit++ {
+			b := ip[bit/8] >> (7 - uint(bit%8)) & 1
+			if bit == ones-1 {
+				nodes[node][b] = child{record: i + 1}
+				break
+			}
+			if nodes[node][b].node == 0 {
+				nodes = append(nodes, [2]child{})
+				nodes[node][b].node = len(nodes) - 1
+			}
+			node = nodes[node][b].node
+		}
+	}
+
+	nodeCount := uint(len(nodes))
+	value := func(c child) uint {
+		switch {
+		case c.record > 0:
+			return nodeCount + dataSectionSeparator + offsets[c.record-1]
+		case c.node > 0:
+			return uint(c.node)
+		}
+		return nodeCount
+	}
+	var tree []byte
+	for _, node := range nodes {
+		left, right := value(node[0]), value(node[1])
+		switch recordSize {
+		case 24:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			tree = append(tree, byte(left>>16), byte(left>>8), byte(left),
+				byte(left>>24&0x0F)<<4|byte(right>>24&0x0F),
+				byte(right>>16), byte(right>>8), byte(right))
+		default:
+			tree = append(tree, byte(left>>24), byte(left>>16), byte(left>>8), byte(left),
+				byte(right>>24), byte(right>>16), byte(right>>8), byte(right))
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(tree)
+	buf.Write(make([]byte, dataSectionSeparator))
+	buf.Write(data)
+	buf.Write(metadataMarker)
+	buf.Write(encodeValue(map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+		"ip_version":                  uint16(ipVersion),
+		"database_type":               "Test-City",
+	}))
+	return buf.Bytes()
+}
+
+// encodeValue encodes a value for the data section
+func encodeValue(v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return append(control(typeString, uint(len(v))), v...)
+	case uint16:
+		return append(control(typeUint16, 2), byte(v>>8), byte(v))
+	case uint32:
+		return append(control(typeUint32, 4), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case uint64:
+		b := control(typeUint64, 8)
+		for shift := 56; shift >= 0; shift -= 8 {
+			b = append(b, byte(v>>uint(shift)))
+		}
+		return b
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b := control(typeMap, uint(len(v)))
+		for _, key := range keys {
+			b = append(b, encodeValue(key)...)
+			b = append(b, encodeValue(v[key])...)
+		}
+		return b
+	case []interface{}:
+		b := control(typeArray, uint(len(v)))
+		for _, item := range v {
+			b = append(b, encodeValue(item)...)
+		}
+		return b
+	}
+	panic("encodeValue: unsupported type")
+}
+
+// control encodes a control byte, with the extended type and size bytes
+// that follow it
+func control(fieldType int, size uint) []byte {
+	var b []byte
+	first := byte(fieldType) << 5
+	if fieldType > typeMap {
+		first = 0
+	}
+	switch {
+	case size < 29:
+		b = []byte{first | byte(size)}
+	case size < 285:
+		b = []byte{first | 29, byte(size - 29)}
+	case size < 65821:
+		n := size - 285
+		b = []byte{first | 30, byte(n >> 8), byte(n)}
+	default:
+		n := size - 65821
+		b = []byte{first | 31, byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	if fieldType > typeMap {
+		// The extended type goes right after the control byte
+		b = append(b[:1], append([]byte{byte(fieldType - 7)}, b[1:]...)...)
+	}
+	return b
+}
+
+func names(en string) map[string]interface{} {
+	return map[string]interface{}{"names": map[string]interface{}{"en": en, "de": en + " (de)"}}
+}
+
+// testNetworks are a city record, a country-only record and an anycast
+// range carrying just its registered country
+var testNetworks = []mmdbNetwork{
+	{"81.2.69.0/24", map[string]interface{}{
+		"city":         names("London"),
+		"country":      map[string]interface{}{"iso_code": "GB", "names": map[string]interface{}{"en": "United Kingdom"}},
+		"subdivisions": []interface{}{names("England"), names("Greater London")},
+		"location":     map[string]interface{}{"time_zone": "Europe/London", "accuracy_radius": uint16(100)},
+	}},
+	{"2.125.160.0/19", map[string]interface{}{
+		"country": map[string]interface{}{"iso_code": "FR", "names": map[string]interface{}{"en": "France"}},
+	}},
+	{"1.1.1.0/24", map[string]interface{}{
+		"registered_country": map[string]interface{}{"iso_code": "AU", "names": map[string]interface{}{"en": "Australia"}},
+	}},
+	{"2001:db8::/32", map[string]interface{}{
+		"country": map[string]interface{}{"iso_code": "DE", "names": map[string]interface{}{"en": "Germany"}},
+	}},
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		address string
+		want    Location
+		ok      bool
+	}{
+		{"81.2.69.142", Location{Country: "GB", CountryName: "United Kingdom", Region: "England", City: "London"}, true},
+		{"81.2.69.0", Location{Country: "GB", CountryName: "United Kingdom", Region: "England", City: "London"}, true},
+		{"81.2.70.1", Location{}, false},
+		{"2.125.191.255", Location{Country: "FR", CountryName: "France"}, true},
+		{"2.125.192.0", Location{}, false},
+		{"1.1.1.1", Location{Country: "AU", CountryName: "Australia"}, true},
+		{"::ffff:81.2.69.142", Location{Country: "GB", CountryName: "United Kingdom", Region: "England", City: "London"}, true},
+		{"10.0.0.1", Location{}, false},
+		{"not an address", Location{}, false},
+	}
+	for _, recordSize := range []int{24, 28, 32} {
+		r, err := FromBytes(buildMMDB(t, 6, recordSize, testNetworks...))
+		if err != nil {
+			t.Fatalf("record size %d: %v", recordSize, err)
+		}
+		for _, tt := range tests {
+			got, ok, err := r.LookupString(tt.address)
+			if err != nil {
+				t.Errorf("record size %d: Lookup(%s): %v", recordSize, tt.address, err)
+				continue
+			}
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("record size %d: Lookup(%s) = %+v, %v; want %+v, %v", recordSize, tt.address, got, ok, tt.want, tt.ok)
+			}
+		}
+	}
+}
+
+func TestLookupIPv6(t *testing.T) {
+	r, err := FromBytes(buildMMDB(t, 6, 28, testNetworks...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := r.LookupString("2001:db8:1::1"); err != nil || !ok || got.Country != "DE" {
+		t.Errorf("Lookup(2001:db8:1::1) = %+v, %v, %v; want DE", got, ok, err)
+	}
+	if _, ok, err := r.LookupString("2001:db9::1"); err != nil || ok {
+		t.Errorf("Lookup(2001:db9::1) = %v, %v; want not found", ok, err)
+	}
+}
+
+func TestLookupIPv4Database(t *testing.T) {
+	r, err := FromBytes(buildMMDB(t, 4, 24, testNetworks[:3]...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := r.LookupString("2.125.160.1"); err != nil || !ok || got.Country != "FR" {
+		t.Errorf("Lookup(2.125.160.1) = %+v, %v, %v; want FR", got, ok, err)
+	}
+	// An IPv4 database has no IPv6 addresses
+	if _, ok, err := r.LookupString("2001:db8::1"); err != nil || ok {
+		t.Errorf("Lookup(2001:db8::1) = %v, %v; want not found", ok, err)
+	}
+}
+
+func TestNilReader(t *testing.T) {
+	var r *Reader
+	if _, ok, err := r.LookupString("81.2.69.142"); ok || err != nil {
+		t.Errorf("nil reader Lookup = %v, %v; want nothing found", ok, err)
+	}
+}
+
+func TestFromBytesRejects(t *testing.T) {
+	valid := buildMMDB(t, 6, 24, testNetworks...)
+	markerAt := bytes.LastIndex(valid, metadataMarker)
+	withMetadata := func(meta map[string]interface{}) []byte {
+		b := append([]byte(nil), valid[:markerAt+len(metadataMarker)]...)
+		return append(b, encodeValue(meta)...)
+	}
+
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", nil},
+		{"no metadata marker", valid[:markerAt]},
+		{"metadata cut off", valid[:len(valid)-3]},
+		{"metadata not a map", append(valid[:markerAt+len(metadataMarker):markerAt+len(metadataMarker)], encodeValue("v2")...)},
+		{"format version 1", withMetadata(map[string]interface{}{
+			"binary_format_major_version": uint16(1), "node_count": uint32(1), "record_size": uint16(24), "ip_version": uint16(6),
+		})},
+		{"record size 20", withMetadata(map[string]interface{}{
+			"binary_format_major_version": uint16(2), "node_count": uint32(1), "record_size": uint16(20), "ip_version": uint16(6),
+		})},
+		{"tree larger than the file", withMetadata(map[string]interface{}{
+			"binary_format_major_version": uint16(2), "node_count": uint32(1 << 30), "record_size": uint16(32), "ip_version": uint16(6),
+		})},
+		{"node count that wraps the tree size", withMetadata(map[string]interface{}{
+			"binary_format_major_version": uint16(2), "node_count": uint64(1 << 62), "record_size": uint16(32), "ip_version": uint16(6),
+		})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := FromBytes(tt.buf); err == nil {
+				t.Error("FromBytes accepted the file")
+			}
+		})
+	}
+}
+
+// TestCorruptFilesDontPanic cuts a database short at every length and
+// flips every byte of it. Each has to fail cleanly or still look up.
+func TestCorruptFilesDontPanic(t *testing.T) {
+	valid := buildMMDB(t, 6, 28, testNetworks...)
+	addresses := []string{"81.2.69.142", "2.125.160.1", "1.1.1.1", "2001:db8::1", "10.0.0.1"}
+	try := func(name string, buf []byte) {
+		defer func() {
+			if p := recover(); p != nil {
+				t.Fatalf("%s: panic: %v", name, p)
+			}
+		}()
+		r, err := FromBytes(buf)
+		if err != nil {
+			return
+		}
+		for _, address := range addresses {
+			r.LookupString(address)
+		}
+	}
+
+	for n := 0; n < len(valid); n++ {
+		try("truncated", append([]byte(nil), valid[:n]...))
+	}
+	for i := range valid {
+		for _, mask := range []byte{0x01, 0x80, 0xFF} {
+			buf := append([]byte(nil), valid...)
+			buf[i] ^= mask
+			try("corrupted", buf)
+		}
+	}
+}
+
+func TestDecodeRejectsMalformedData(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", nil},
+		{"string longer than the data", []byte{typeString<<5 | 10, 'a', 'b'}},
+		{"size bytes missing", []byte{typeString<<5 | 30, 0x01}},
+		{"extended type missing", []byte{0x00}},
+		{"invalid extended type", []byte{0x00, 0x00}},
+		{"pointer cut off", []byte{typePointer<<5 | 0x08, 0x00}},
+		{"pointer to itself", []byte{typePointer << 5, 0x00}},
+		{"map key not a string", append([]byte{typeMap<<5 | 1}, append(encodeValue(uint16(1)), encodeValue("v")...)...)},
+		{"map larger than the data", []byte{typeMap<<5 | 31, 0xFF, 0xFF, 0xFF}},
+		{"array larger than the data", []byte{0x1F, typeArray - 7, 0xFF, 0xFF, 0xFF}},
+		{"double of 4 bytes", []byte{typeDouble<<5 | 4, 0, 0, 0, 0}},
+		{"uint32 of 9 bytes", []byte{typeUint32<<5 | 9, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if value, _, err := (decoder{buf: tt.buf}).decode(0); err == nil {
+				t.Errorf("decode = %v, want an error", value)
+			}
+		})
+	}
+}
+
+func TestDecodePointers(t *testing.T) {
+	// A map whose value points back at a string earlier in the data, as
+	// real databases share their names
+	shared := encodeValue("United Kingdom")
+	buf := append([]byte(nil), shared...)
+	start := uint(len(buf))
+	buf = append(buf, typeMap<<5|1)
+	buf = append(buf, encodeValue("en")...)
+	buf = append(buf, typePointer<<5, 0x00)
+	buf = append(buf, encodeValue("after")...)
+
+	value, next, err := (decoder{buf: buf}).decode(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, _ := value.(map[string]interface{}); m["en"] != "United Kingdom" {
+		t.Errorf("decode = %v, want the pointed-to name", value)
+	}
+	if after, _, err := (decoder{buf: buf}).decode(next); err != nil || after != "after" {
+		t.Errorf("value after the map = %v, %v; want the pointer skipped", after, err)
+	}
+}
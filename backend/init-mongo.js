@@ -4,12 +4,34 @@ db = db.getSiblingDB("formbuilder");
 // Create collections
 db.createCollection("forms");
 db.createCollection("responses");
+db.createCollection("partial_responses");
+db.createCollection("form_events");
+db.createCollection("analytics_rollups");
+db.createCollection("export_jobs");
+db.createCollection("retention_runs");
 
 // Create indexes
 db.forms.createIndex({ userId: 1 });
 db.forms.createIndex({ createdAt: -1 });
+db.forms.createIndex({ purgeAt: 1 }, { sparse: true });
 db.responses.createIndex({ formId: 1 });
 db.responses.createIndex({ createdAt: -1 });
+db.responses.createIndex({ formId: 1, createdAt: -1, _id: -1 });
+db.responses.createIndex({ formId: 1, device: 1 });
+db.responses.createIndex({ formId: 1, tags: 1 });
+db.responses.createIndex({ formId: 1, status: 1 });
+db.responses.createIndex({ formId: 1, assignee: 1 });
+db.responses.createIndex(
+  { formId: 1, searchText: "text" },
+  { default_language: "none", name: "formId_searchText_text" }
+);
+db.partial_responses.createIndex({ resumeToken: 1 }, { unique: true });
+db.partial_responses.createIndex({ formId: 1, completedAt: 1 });
+db.form_events.createIndex({ formId: 1, sessionId: 1 });
+db.analytics_rollups.createIndex({ formId: 1, granularity: 1, bucket: 1 });
+db.export_jobs.createIndex({ formId: 1, createdAt: -1 });
+db.export_jobs.createIndex({ status: 1, expiresAt: 1 });
+db.retention_runs.createIndex({ "forms.formId": 1, startedAt: -1 });
 
 // Create a user for the application (optional - you can use root user too)
 // This creates a user that can only access the formbuilder database
@@ -1,24 +1,43 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime/multipart"
+	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	_ "time/tzdata" // embedded zone database for analytics timezones
+	"unicode/utf8"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"form-builder-backend/blob"
+	"form-builder-backend/envelope"
+	"form-builder-backend/geoip"
 	"form-builder-backend/models"
+	"form-builder-backend/search"
 	"form-builder-backend/services"
+	"form-builder-backend/useragent"
 	ws "form-builder-backend/websocket"
 )
 
@@ -26,6 +45,14 @@ var client *mongo.Client
 var database *mongo.Database
 var wsHub *ws.Hub
 var analyticsService *services.AnalyticsService
+var responseService *services.ResponseService
+var exportJobService *services.ExportJobService
+var trashService *services.TrashService
+var retentionService *services.RetentionService
+var geoReader *geoip.Reader // nil when GEOIP_DB_PATH isn't set
+
+var fieldCipher *services.FieldCipher // nil when FIELD_ENCRYPTION_KEY_FILE isn't set
+var revealAllowed bool                // FIELD_REVEAL_ALLOWED, see canReadSensitive
 var useMemoryStore bool = false
 var allowedOrigins string
 
@@ -40,14 +67,59 @@ func main() {
 
 	// Initialize services
 	analyticsService = services.NewAnalyticsService(database)
+	if err := analyticsService.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Error creating analytics indexes: %v", err)
+	}
+
+	// Encrypt the answers to PII fields, if a key file is configured
+	if path := os.Getenv("FIELD_ENCRYPTION_KEY_FILE"); path != "" {
+		keys, err := envelope.LoadKeyFile(path)
+		if err != nil {
+			log.Fatal("Failed to load field encryption keys:", err)
+		}
+		fieldCipher = services.NewFieldCipher(keys)
+		log.Printf("Encrypting PII answers with keys from %s", path)
+	} else {
+		log.Println("Field encryption disabled, PII answers are stored in plain text")
+	}
+
+	responseService = services.NewResponseService(database, fieldCipher)
+	revealAllowed = os.Getenv("FIELD_REVEAL_ALLOWED") == "true"
+
+	// Load the offline geolocation database, if one is configured
+	if path := os.Getenv("GEOIP_DB_PATH"); path != "" {
+		reader, err := geoip.Open(path)
+		if err != nil {
+			log.Printf("Geolocation disabled, failed to open %s: %v", path, err)
+		} else {
+			geoReader = reader
+			log.Printf("Geolocating responses with %s", path)
+		}
+	}
 
 	// Initialize WebSocket hub
 	wsHub = ws.NewHub()
 	go wsHub.Run()
 
+	// Watch for submission spikes and drops
+	startAnomalyDetector()
+
+	// Run background exports
+	startExportJobs()
+
+	// Purge deleted forms once they can no longer be restored
+	startTrash()
+
+	// Delete or anonymize responses past their form's retention period
+	startRetention()
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
-		Prefork: false,
+		Prefork:           false,
+		BodyLimit:         fiber.DefaultBodyLimit,
+		StreamRequestBody: true,
+		// Multipart bodies are read by the handlers, under their own limit
+		DisablePreParseMultipartForm: true,
 	})
 
 	// CORS Configuration
@@ -61,7 +133,7 @@ func main() {
 	// Middleware
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     allowedOrigins,
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
+		AllowMethods:     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
 		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-Requested-With",
 		AllowCredentials: true,
 		ExposeHeaders:    "Content-Length,Content-Range",
@@ -74,6 +146,7 @@ func main() {
 		},
 	}))
 	app.Use(logger.New())
+	app.Use(limitBody(fiber.DefaultBodyLimit, isImport))
 
 	// Routes
 	setupRoutes(app)
@@ -112,6 +185,108 @@ func connectMongoDB() {
 	log.Println("Connected to MongoDB")
 }
 
+// importBodyLimit is the largest import upload. Bodies are streamed, so an
+// import is read through a reader capped at this size and its file spooled
+// to disk rather than held in memory; every other route is held to Fiber's
+// default limit.
+const importBodyLimit = 256 << 20
+
+// importMemoryLimit is how much of an import upload is kept in memory before
+// the file is spooled to disk
+const importMemoryLimit = 16 << 20
+
+// errBodyTooLarge is returned by a cappedReader once its limit is passed
+var errBodyTooLarge = errors.New("request body is too large")
+
+// cappedReader reads r up to n bytes and fails past them, instead of ending
+// the body early like an io.LimitReader
+type cappedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	// One byte past the limit is enough to tell the body is too large
+	if int64(len(p)) > c.n+1 {
+		p = p[:c.n+1]
+	}
+	n, err := c.r.Read(p)
+	c.n -= int64(n)
+	if c.n < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
+
+// limitBody rejects request bodies over limit, except on requests skip
+// returns true for
+func limitBody(limit int, skip func(c *fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if skip(c) {
+			return c.Next()
+		}
+		n := c.Request().Header.ContentLength()
+		tooLarge := n > limit
+		if n == -1 {
+			// Chunked bodies have no length up front, so they are read
+			// through a capped reader to be measured
+			if stream := c.Context().RequestBodyStream(); stream != nil {
+				body, err := io.ReadAll(&cappedReader{r: stream, n: int64(limit)})
+				if err != nil && !errors.Is(err, errBodyTooLarge) {
+					return c.Status(400).JSON(fiber.Map{
+						"error": "Failed to read request body",
+					})
+				}
+				tooLarge = err != nil
+				if !tooLarge {
+					c.Request().SetBody(body)
+				}
+			}
+		}
+		if tooLarge {
+			// The rest of the body is never read, so the connection can't
+			// carry another request
+			c.Context().SetConnectionClose()
+			return c.Status(413).JSON(fiber.Map{
+				"error": "Request body is too large",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// readImportUpload parses an import's multipart body, at most
+// importBodyLimit bytes of it. The caller removes the spooled files.
+func readImportUpload(c *fiber.Ctx) (*multipart.Form, error) {
+	boundary := string(c.Request().Header.MultipartFormBoundary())
+	stream := c.Context().RequestBodyStream()
+	if boundary == "" || stream == nil {
+		return nil, errors.New("request is not a multipart upload")
+	}
+	if c.Request().Header.ContentLength() > importBodyLimit {
+		return nil, errBodyTooLarge
+	}
+	body := &cappedReader{r: stream, n: importBodyLimit}
+	upload, err := multipart.NewReader(body, boundary).ReadForm(importMemoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	// Read what follows the closing boundary, so the connection is left at
+	// the next request
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		upload.RemoveAll()
+		return nil, err
+	}
+	return upload, nil
+}
+
+// isImport reports whether c is a response import upload
+func isImport(c *fiber.Ctx) bool {
+	return c.Method() == fiber.MethodPost &&
+		strings.HasPrefix(c.Path(), "/api/v1/responses/form/") &&
+		strings.HasSuffix(c.Path(), "/import")
+}
+
 func setupRoutes(app *fiber.App) {
 	api := app.Group("/api/v1")
 
@@ -143,23 +318,46 @@ func setupRoutes(app *fiber.App) {
 	forms.Get("/:id", getForm)
 	forms.Put("/:id", updateForm)
 	forms.Delete("/:id", deleteForm)
+	forms.Post("/:id/restore", restoreForm)
 	forms.Post("/:id/save-draft", saveDraft)
 	forms.Post("/:id/unpublish", unpublishForm)
+	forms.Post("/:id/exports", createExport)
+	forms.Get("/:id/exports", getExports)
+	forms.Get("/:id/exports/:jobId", getExport)
+	forms.Get("/:id/exports/:jobId/download", downloadExport)
+	forms.Delete("/:id/exports/:jobId", deleteExport)
+	forms.Get("/:id/retention/runs", getRetentionRuns)
+	forms.Get("/:id/segments", getSegments)
+	forms.Put("/:id/segments/:name", saveSegment)
+	forms.Delete("/:id/segments/:name", deleteSegment)
 	
 	// Public routes (no authentication required)
 	public := api.Group("/public")
 	public.Get("/forms/:id", getPublicForm)
+	public.Post("/forms/:id/events", trackFormEvents)
 
 	// Responses routes
 	responses := api.Group("/responses")
 	responses.Post("/", createResponse)
+	responses.Post("/partial", savePartialResponse)
+	responses.Get("/partial/:token", getPartialResponse)
 	responses.Get("/form/:formId", getResponsesByForm)
+	responses.Get("/form/:formId/export", exportResponses)
+	responses.Post("/form/:formId/bulk-delete", bulkDeleteResponses)
+	responses.Post("/form/:formId/import", importResponses)
 	responses.Get("/:id", getResponse)
+	responses.Patch("/:id", updateResponse)
+	responses.Delete("/:id", deleteResponse)
+	responses.Post("/:id/notes", addResponseNote)
+	responses.Delete("/:id/notes/:noteId", deleteResponseNote)
 
 	// Analytics routes
 	analytics := api.Group("/analytics")
 	analytics.Get("/form/:formId", getFormAnalytics)
 	analytics.Get("/form/:formId/realtime", getRealTimeAnalytics)
+	analytics.Get("/form/:formId/funnel", getFormFunnel)
+	analytics.Get("/form/:formId/trends", getResponseTrends)
+	analytics.Get("/form/:formId/crosstab", getCrossTab)
 }
 
 func createForm(c *fiber.Ctx) error {
@@ -180,6 +378,23 @@ func createForm(c *fiber.Ctx) error {
 		req.Status = "draft"
 	}
 
+	if _, err := services.LoadLocation(req.Timezone); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid timezone",
+		})
+	}
+
+	if req.Retention != nil && req.Retention.Days == 0 {
+		req.Retention = nil
+	}
+	if req.Retention != nil {
+		if err := services.ValidateRetention(req.Retention); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+	}
+
 	form := models.Form{
 		Title:       req.Title,
 		Description: req.Description,
@@ -189,6 +404,8 @@ func createForm(c *fiber.Ctx) error {
 		UpdatedAt:   time.Now(),
 		IsActive:    req.Status == "published",
 		UserID:      "default", // TODO: implement user authentication
+		Timezone:    req.Timezone,
+		Retention:   req.Retention,
 	}
 
 	collection := database.Collection("forms")
@@ -211,10 +428,14 @@ func getForms(c *fiber.Ctx) error {
 	status := c.Query("status")
 	userID := c.Query("userId", "default") // TODO: get from auth
 	
-	filter := bson.M{"userId": userID}
+	filter := bson.M{"userId": userID, "deletedAt": nil}
 	if status != "" {
 		filter["status"] = status
 	}
+	// ?deleted=true lists the trash instead
+	if c.QueryBool("deleted") {
+		filter["deletedAt"] = bson.M{"$ne": nil}
+	}
 
 	cursor, err := collection.Find(context.Background(), filter, options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}))
 	if err != nil {
@@ -266,7 +487,7 @@ func getForm(c *fiber.Ctx) error {
 
 	collection := database.Collection("forms")
 	var form models.Form
-	err = collection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&form)
+	err = collection.FindOne(context.Background(), bson.M{"_id": objID, "deletedAt": nil}).Decode(&form)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return c.Status(404).JSON(fiber.Map{
@@ -314,15 +535,55 @@ func updateForm(c *fiber.Ctx) error {
 		update["$set"].(bson.M)["fields"] = *req.Fields
 	}
 	if req.Status != nil {
+		if !isFormStatus(*req.Status) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Status must be draft, published or archived",
+			})
+		}
 		update["$set"].(bson.M)["status"] = *req.Status
 		update["$set"].(bson.M)["isActive"] = (*req.Status == "published")
 	}
-	if req.IsActive != nil {
+	// Archived forms never accept submissions
+	if req.IsActive != nil && (req.Status == nil || *req.Status != "archived") {
 		update["$set"].(bson.M)["isActive"] = *req.IsActive
 	}
+	if req.Timezone != nil {
+		if _, err := services.LoadLocation(*req.Timezone); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Invalid timezone",
+			})
+		}
+		update["$set"].(bson.M)["timezone"] = *req.Timezone
+	}
+	if req.Retention != nil {
+		if req.Retention.Days == 0 {
+			update["$unset"] = bson.M{"retention": ""}
+		} else if err := services.ValidateRetention(req.Retention); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		} else {
+			update["$set"].(bson.M)["retention"] = req.Retention
+		}
+	}
 
 	collection := database.Collection("forms")
-	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": objID}, update)
+
+	var newlyPII []string
+	if req.Fields != nil {
+		var previous models.Form
+		err := collection.FindOne(context.Background(), bson.M{"_id": objID, "deletedAt": nil},
+			options.FindOne().SetProjection(bson.M{"fields": 1})).Decode(&previous)
+		if err != nil && err != mongo.ErrNoDocuments {
+			log.Printf("Error fetching form: %v", err)
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to update form",
+			})
+		}
+		newlyPII = newlyPIIFields(previous.Fields, *req.Fields)
+	}
+
+	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": objID, "deletedAt": nil}, update)
 	if err != nil {
 		log.Printf("Error updating form: %v", err)
 		return c.Status(500).JSON(fiber.Map{
@@ -335,6 +596,7 @@ func updateForm(c *fiber.Ctx) error {
 			"error": "Form not found",
 		})
 	}
+	forgetPIIAnswers(objID, newlyPII)
 
 	// Fetch and return the updated form
 	var updatedForm models.Form
@@ -349,7 +611,77 @@ func updateForm(c *fiber.Ctx) error {
 	return c.JSON(updatedForm)
 }
 
-func deleteForm(c *fiber.Ctx) error {
+// newlyPIIFields lists the fields marked PII in fields that weren't in
+// previous
+func newlyPIIFields(previous, fields []models.FormField) []string {
+	wasPII := make(map[string]bool, len(previous))
+	for _, field := range previous {
+		wasPII[field.ID] = field.PII
+	}
+	var ids []string
+	for _, field := range fields {
+		if field.PII && !wasPII[field.ID] {
+			ids = append(ids, field.ID)
+		}
+	}
+	return ids
+}
+
+// forgetPIIAnswers hides the answers given to fields before they were
+// marked PII: the analytics stop breaking them down, and in the background
+// they are encrypted when a key is configured and taken out of search
+func forgetPIIAnswers(formID primitive.ObjectID, fieldIDs []string) {
+	if len(fieldIDs) == 0 {
+		return
+	}
+	if err := analyticsService.ForgetAnswers(context.Background(), formID, fieldIDs); err != nil {
+		log.Printf("Error removing PII answers from analytics rollups: %v", err)
+	}
+	go func() {
+		if _, err := responseService.BackfillEncryption(context.Background(), formID); err != nil {
+			log.Printf("Error encrypting PII answers of form %s: %v", formID.Hex(), err)
+		}
+		if _, err := responseService.BackfillSearchText(context.Background(), formID); err != nil {
+			log.Printf("Error removing PII answers of form %s from search: %v", formID.Hex(), err)
+		}
+	}()
+}
+
+func getSegments(c *fiber.Ctx) error {
+	id := c.Params("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid form ID",
+		})
+	}
+
+	var form models.Form
+	err = database.Collection("forms").FindOne(context.Background(), bson.M{
+		"_id":       objID,
+		"userId":    "default", // TODO: get from auth
+		"deletedAt": nil,
+	}).Decode(&form)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		log.Printf("Error fetching form segments: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch segments",
+		})
+	}
+
+	if form.Segments == nil {
+		form.Segments = []models.Segment{}
+	}
+	return c.JSON(form.Segments)
+}
+
+// saveSegment creates or replaces a named segment on a form
+func saveSegment(c *fiber.Ctx) error {
 	id := c.Params("id")
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -358,30 +690,259 @@ func deleteForm(c *fiber.Ctx) error {
 		})
 	}
 
+	name, err := url.PathUnescape(c.Params("name"))
+	if err != nil || strings.TrimSpace(name) == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid segment name",
+		})
+	}
+
+	var req models.SaveSegmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	if req.Filter.IsEmpty() {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Segment filter is required",
+		})
+	}
+
 	collection := database.Collection("forms")
-	result, err := collection.DeleteOne(context.Background(), bson.M{"_id": objID})
+	var form models.Form
+	err = collection.FindOne(context.Background(), bson.M{
+		"_id":       objID,
+		"userId":    "default", // TODO: get from auth
+		"deletedAt": nil,
+	}).Decode(&form)
 	if err != nil {
-		log.Printf("Error deleting form: %v", err)
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		log.Printf("Error fetching form: %v", err)
 		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to delete form",
+			"error": "Failed to save segment",
+		})
+	}
+
+	// Check the filter against the form's fields and timezone before saving it
+	loc, err := services.LoadLocation(form.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	if _, err := services.ResponseFilterMatch(&form, req.Filter, loc); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	now := time.Now()
+	segment := models.Segment{
+		Name:      name,
+		Filter:    req.Filter,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Replace the segment in place if it exists, otherwise append it
+	result, err := collection.UpdateOne(context.Background(),
+		bson.M{"_id": objID, "segments.name": name},
+		bson.M{"$set": bson.M{
+			"segments.$.filter":    req.Filter,
+			"segments.$.updatedAt": now,
+		}})
+	if err == nil && result.MatchedCount > 0 {
+		if existing, ok := services.FindSegment(&form, name); ok {
+			segment.CreatedAt = existing.CreatedAt
+		}
+		return c.JSON(segment)
+	}
+	if err == nil {
+		result, err = collection.UpdateOne(context.Background(),
+			bson.M{"_id": objID, "segments.name": bson.M{"$ne": name}},
+			bson.M{"$push": bson.M{"segments": segment}})
+	}
+	if err != nil {
+		log.Printf("Error saving segment: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to save segment",
+		})
+	}
+	if result.MatchedCount == 0 {
+		return c.Status(409).JSON(fiber.Map{
+			"error": "Segment was modified concurrently, please retry",
+		})
+	}
+
+	return c.Status(201).JSON(segment)
+}
+
+func deleteSegment(c *fiber.Ctx) error {
+	id := c.Params("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid form ID",
+		})
+	}
+
+	name, err := url.PathUnescape(c.Params("name"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid segment name",
+		})
+	}
+
+	result, err := database.Collection("forms").UpdateOne(context.Background(),
+		bson.M{
+			"_id":           objID,
+			"userId":        "default", // TODO: get from auth
+			"deletedAt":     nil,
+			"segments.name": name,
+		},
+		bson.M{"$pull": bson.M{"segments": bson.M{"name": name}}})
+	if err != nil {
+		log.Printf("Error deleting segment: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete segment",
 		})
 	}
 
-	if result.DeletedCount == 0 {
+	if result.MatchedCount == 0 {
 		return c.Status(404).JSON(fiber.Map{
-			"error": "Form not found",
+			"error": "Segment not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Segment deleted successfully",
+	})
+}
+
+// deleteForm moves a form to the trash, where it can be restored until its
+// purgeAt time. ?permanent=true purges it and its data straight away, which
+// also works for forms already in the trash.
+func deleteForm(c *fiber.Ctx) error {
+	id := c.Params("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid form ID",
+		})
+	}
+
+	if c.QueryBool("permanent") {
+		count, err := database.Collection("forms").CountDocuments(context.Background(), bson.M{
+			"_id":    objID,
+			"userId": "default", // TODO: get from auth
+		})
+		if err != nil {
+			log.Printf("Error finding form: %v", err)
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to delete form",
+			})
+		}
+		if count == 0 {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		if err := trashService.Purge(context.Background(), objID); err != nil {
+			log.Printf("Error purging form: %v", err)
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to delete form",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"message": "Form deleted successfully",
+		})
+	}
+
+	form, err := trashService.Delete(context.Background(), objID, "default") // TODO: get from auth
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		log.Printf("Error deleting form: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete form",
 		})
 	}
 
 	return c.JSON(fiber.Map{
 		"message": "Form deleted successfully",
+		"purgeAt": form.PurgeAt,
+	})
+}
+
+// restoreForm takes a form out of the trash
+func restoreForm(c *fiber.Ctx) error {
+	id := c.Params("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid form ID",
+		})
+	}
+
+	form, err := trashService.Restore(context.Background(), objID, "default") // TODO: get from auth
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found in trash",
+			})
+		}
+		log.Printf("Error restoring form: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to restore form",
+		})
+	}
+
+	return c.JSON(form)
+}
+
+// isFormStatus reports whether status is a form status
+func isFormStatus(status string) bool {
+	return status == "draft" || status == "published" || status == "archived"
+}
+
+// isFormArchived reports whether a form is archived, to tell respondents why
+// it no longer accepts submissions
+func isFormArchived(id primitive.ObjectID) bool {
+	count, err := database.Collection("forms").CountDocuments(context.Background(), bson.M{
+		"_id":       id,
+		"status":    "archived",
+		"deletedAt": nil,
 	})
+	if err != nil {
+		log.Printf("Error finding form: %v", err)
+	}
+	return count > 0
+}
+
+// canReadSensitive reports whether the requester may see the decrypted
+// answers to a form's PII fields. Until there is auth to grant it, reading
+// them takes FIELD_REVEAL_ALLOWED on the server and ?reveal=true on the
+// request; anyone else sees them masked.
+func canReadSensitive(c *fiber.Ctx, form *models.Form) bool {
+	return revealAllowed && c.Query("reveal") == "true" &&
+		form.UserID == "default" // TODO: get from auth, and let owners grant access
 }
 
 func createResponse(c *fiber.Ctx) error {
 	var req struct {
-		FormID string                 `json:"formId" validate:"required"`
-		Data   map[string]interface{} `json:"data" validate:"required"`
+		FormID      string                 `json:"formId" validate:"required"`
+		Data        map[string]interface{} `json:"data" validate:"required"`
+		ResumeToken string                 `json:"resumeToken,omitempty"`
+		SessionID   string                 `json:"sessionId,omitempty"`
+		Referrer    string                 `json:"referrer,omitempty"`
+		UTM         models.UTMParams       `json:"utm,omitempty"`
+		Tags        []string               `json:"tags,omitempty"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -420,6 +981,11 @@ func createResponse(c *fiber.Ctx) error {
 	}).Decode(&form)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
+			if isFormArchived(formObjID) {
+				return c.Status(410).JSON(fiber.Map{
+					"error": "This form has been archived and no longer accepts responses",
+				})
+			}
 			return c.Status(404).JSON(fiber.Map{
 				"error": "Form not found or not published",
 			})
@@ -437,13 +1003,51 @@ func createResponse(c *fiber.Ctx) error {
 		})
 	}
 
+	// Encrypt the answers to PII fields before they are stored
+	data, key, err := fieldCipher.Encrypt(context.Background(), &form, req.Data)
+	if err != nil {
+		log.Printf("Error encrypting response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to save response",
+		})
+	}
+
 	// Create form response
+	client := useragent.Parse(c.Get("User-Agent"))
 	response := models.FormResponse{
-		FormID:    formObjID,
-		Data:      req.Data,
-		CreatedAt: time.Now(),
-		IPAddress: c.IP(),
-		UserAgent: c.Get("User-Agent"),
+		FormID:     formObjID,
+		Data:       data,
+		Encryption: key,
+		CreatedAt:  time.Now(),
+		IPAddress:  c.IP(),
+		UserAgent:  c.Get("User-Agent"),
+		Device:     client.Device,
+		Browser:    client.Browser,
+		OS:         client.OS,
+		Bot:        client.Bot,
+		SessionID:  req.SessionID,
+	}
+	response.SearchText = search.Text(&form, response.Data)
+	response.Rollup = services.RollupModes(&form, response.Data)
+
+	// Record where the respondent came from
+	response.Referrer, response.ReferrerDomain, response.UTM = responseAttribution(c, req.Referrer, req.UTM)
+
+	// Tags from the form link, e.g. ?tags=beta,newsletter, so the analytics
+	// tag filter can segment submissions from the start
+	if tags := normalizeTags(req.Tags); len(tags) > 0 {
+		if len(tags) > maxSubmittedTags {
+			tags = tags[:maxSubmittedTags]
+		}
+		response.Tags = tags
+	}
+
+	// Geolocate the submitter from the local database
+	if location, ok, err := geoReader.LookupString(response.IPAddress); err != nil {
+		log.Printf("Error geolocating response: %v", err)
+	} else if ok {
+		response.Country = location.Country
+		response.Region = location.Region
 	}
 
 	// Insert response into database
@@ -458,6 +1062,30 @@ func createResponse(c *fiber.Ctx) error {
 
 	response.ID = result.InsertedID.(primitive.ObjectID)
 
+	// Keep the analytics rollups in step with the stored responses
+	if err := analyticsService.RecordResponse(context.Background(), &form, &response); err != nil {
+		log.Printf("Error updating analytics rollups: %v", err)
+	}
+
+	// Close out the partial submission this response was resumed from
+	if req.ResumeToken != "" {
+		completePartialResponse(formObjID, req.ResumeToken, response.ID)
+	}
+
+	// Record the submit on the renderer session so conversion doesn't depend on
+	// the client reporting it
+	if req.SessionID != "" {
+		_, err := database.Collection("form_events").InsertOne(context.Background(), models.FormEvent{
+			FormID:    formObjID,
+			SessionID: req.SessionID,
+			Type:      models.EventTypeSubmit,
+			CreatedAt: response.CreatedAt,
+		})
+		if err != nil {
+			log.Printf("Error recording submit event: %v", err)
+		}
+	}
+
 	// Broadcast new response via WebSocket
 	wsMessage := ws.Message{
 		Type:      ws.MessageTypeNewResponse,
@@ -467,50 +1095,425 @@ func createResponse(c *fiber.Ctx) error {
 			"id":          response.ID.Hex(),
 			"formId":      req.FormID,
 			"submittedAt": response.CreatedAt,
-			"data":        req.Data,
-			"device":      getDeviceFromUserAgent(response.UserAgent),
+			"data":        services.MaskSensitive(&form, req.Data),
+			"device":      response.Device,
+			"location":    services.FormatLocation(response.Country, response.Region),
 		},
 	}
 	wsHub.BroadcastToForm(req.FormID, wsMessage)
 
 	// Also broadcast analytics update
-	if analytics, err := analyticsService.GetFormAnalytics(req.FormID); err == nil {
-		analyticsMessage := ws.Message{
-			Type:      ws.MessageTypeAnalyticsUpdate,
+	scheduleAnalyticsBroadcast(req.FormID)
+
+	return c.Status(201).JSON(fiber.Map{
+		"message": "Response submitted successfully",
+		"id":      response.ID.Hex(),
+	})
+}
+
+// analyticsBroadcastDelay coalesces the analytics updates of a burst of
+// submissions into a single recomputation per form
+const analyticsBroadcastDelay = 2 * time.Second
+
+var pendingAnalyticsBroadcasts = struct {
+	sync.Mutex
+	forms map[string]bool
+}{forms: make(map[string]bool)}
+
+// scheduleAnalyticsBroadcast broadcasts fresh analytics for a form after
+// analyticsBroadcastDelay, unless a broadcast is already pending
+func scheduleAnalyticsBroadcast(formID string) {
+	pendingAnalyticsBroadcasts.Lock()
+	defer pendingAnalyticsBroadcasts.Unlock()
+
+	if pendingAnalyticsBroadcasts.forms[formID] {
+		return
+	}
+	pendingAnalyticsBroadcasts.forms[formID] = true
+
+	time.AfterFunc(analyticsBroadcastDelay, func() {
+		pendingAnalyticsBroadcasts.Lock()
+		delete(pendingAnalyticsBroadcasts.forms, formID)
+		pendingAnalyticsBroadcasts.Unlock()
+
+		analytics, err := analyticsService.GetFormAnalytics(formID, services.AnalyticsOptions{})
+		if err != nil {
+			log.Printf("Error computing analytics for broadcast: %v", err)
+			return
+		}
+		wsHub.BroadcastToForm(formID, ws.Message{
+			Type:      ws.MessageTypeAnalyticsUpdate,
 			Timestamp: time.Now(),
-			FormID:    req.FormID,
+			FormID:    formID,
 			Data:      analytics,
+		})
+	})
+}
+
+// startAnomalyDetector runs the submission anomaly checks in the background.
+// ANOMALY_CHECK_INTERVAL sets how often they run ("off" disables them), and
+// alerts are posted to ALERT_WEBHOOK_URL when it is set.
+func startAnomalyDetector() {
+	config := services.DefaultAnomalyConfig()
+	if interval := os.Getenv("ANOMALY_CHECK_INTERVAL"); interval == "off" {
+		log.Println("Submission anomaly detection disabled")
+		return
+	} else if interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			log.Printf("Invalid ANOMALY_CHECK_INTERVAL %q, using %s", interval, config.Interval)
+		} else {
+			config.Interval = d
 		}
-		wsHub.BroadcastToForm(req.FormID, analyticsMessage)
 	}
 
-	return c.Status(201).JSON(fiber.Map{
-		"message": "Response submitted successfully",
-		"id":      response.ID.Hex(),
+	var notifier services.AlertNotifier = services.LogNotifier{}
+	if url := os.Getenv("ALERT_WEBHOOK_URL"); url != "" {
+		notifier = services.NewWebhookNotifier(url)
+	}
+
+	detector := services.NewAnomalyDetector(database, config, func(alert services.Alert) {
+		wsHub.BroadcastToForm(alert.FormID, ws.Message{
+			Type:      ws.MessageTypeAlert,
+			Timestamp: time.Now(),
+			FormID:    alert.FormID,
+			Data:      alert,
+		})
+		go func() {
+			if err := notifier.Notify(context.Background(), alert); err != nil {
+				log.Printf("Error sending alert notification: %v", err)
+			}
+		}()
+	})
+	go detector.Run(context.Background())
+}
+
+// startExportJobs runs the export workers in the background. Artifacts are
+// written to EXPORT_DIR and deleted after EXPORT_TTL.
+func startExportJobs() {
+	config := services.DefaultExportJobConfig()
+	if ttl := os.Getenv("EXPORT_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			log.Printf("Invalid EXPORT_TTL %q, using %s", ttl, config.TTL)
+		} else {
+			config.TTL = d
+		}
+	}
+
+	dir := os.Getenv("EXPORT_DIR")
+	if dir == "" {
+		dir = "exports"
+	}
+	store, err := blob.NewLocalStore(dir)
+	if err != nil {
+		log.Fatal("Failed to open export storage:", err)
+	}
+
+	exportJobService = services.NewExportJobService(database, responseService, analyticsService, store, config, func(job models.ExportJob) {
+		wsHub.BroadcastToForm(job.FormID.Hex(), ws.Message{
+			Type:      ws.MessageTypeExportProgress,
+			Timestamp: time.Now(),
+			FormID:    job.FormID.Hex(),
+			Data:      job,
+		})
+	})
+	if err := exportJobService.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Error creating export job indexes: %v", err)
+	}
+	go exportJobService.Run(context.Background())
+}
+
+// startTrash purges deleted forms in the background. FORM_RESTORE_WINDOW sets
+// how long a deleted form can be restored.
+func startTrash() {
+	config := services.DefaultTrashConfig()
+	if window := os.Getenv("FORM_RESTORE_WINDOW"); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			log.Printf("Invalid FORM_RESTORE_WINDOW %q, using %s", window, config.RestoreWindow)
+		} else {
+			config.RestoreWindow = d
+		}
+	}
+
+	trashService = services.NewTrashService(database, exportJobService, config)
+	if err := trashService.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Error creating trash indexes: %v", err)
+	}
+	go trashService.Run(context.Background())
+}
+
+// startRetention enforces the forms' retention policies in the background.
+// RETENTION_INTERVAL sets how often ("off" disables it).
+func startRetention() {
+	config := services.DefaultRetentionConfig()
+	if interval := os.Getenv("RETENTION_INTERVAL"); interval == "off" {
+		log.Println("Response retention disabled")
+		return
+	} else if interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			log.Printf("Invalid RETENTION_INTERVAL %q, using %s", interval, config.Interval)
+		} else {
+			config.Interval = d
+		}
+	}
+
+	retentionService = services.NewRetentionService(database, responseService, analyticsService, config, broadcastResponsesDeleted, func(result models.RetentionResult) {
+		scheduleAnalyticsBroadcast(result.FormID.Hex())
 	})
+	if err := retentionService.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Error creating retention indexes: %v", err)
+	}
+	go retentionService.Run(context.Background())
+}
+
+func savePartialResponse(c *fiber.Ctx) error {
+	var req models.SavePartialResponseRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.FormID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Form ID is required",
+		})
+	}
+
+	formObjID, err := primitive.ObjectIDFromHex(req.FormID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid form ID",
+		})
+	}
+
+	formsCollection := database.Collection("forms")
+	var form models.Form
+	err = formsCollection.FindOne(context.Background(), bson.M{
+		"_id":      formObjID,
+		"status":   "published",
+		"isActive": true,
+	}).Decode(&form)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			if isFormArchived(formObjID) {
+				return c.Status(410).JSON(fiber.Map{
+					"error": "This form has been archived and no longer accepts responses",
+				})
+			}
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found or not published",
+			})
+		}
+		log.Printf("Error finding form: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to verify form",
+		})
+	}
+
+	// Partial answers skip validation entirely; only keep answers to known fields
+	// so the final submit can run the full validateFormData on them
+	data := make(map[string]interface{})
+	for _, field := range form.Fields {
+		if value, exists := req.Data[field.ID]; exists {
+			data[field.ID] = value
+		}
+	}
+	if fieldID, forged := services.ForgedSealedAnswer(data); forged {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("%s has an invalid value", fieldID),
+		})
+	}
+
+	storedData, key, err := fieldCipher.Encrypt(context.Background(), &form, data)
+	if err != nil {
+		log.Printf("Error encrypting partial response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to save partial response",
+		})
+	}
+
+	partialsCollection := database.Collection("partial_responses")
+	now := time.Now()
+
+	if req.ResumeToken == "" {
+		partial := models.PartialResponse{
+			FormID:      formObjID,
+			ResumeToken: uuid.New().String(),
+			Data:        storedData,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			IPAddress:   c.IP(),
+			UserAgent:   c.Get("User-Agent"),
+			SessionID:   req.SessionID,
+			Encryption:  key,
+		}
+
+		result, err := partialsCollection.InsertOne(context.Background(), partial)
+		if err != nil {
+			log.Printf("Error saving partial response: %v", err)
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to save partial response",
+			})
+		}
+
+		partial.ID = result.InsertedID.(primitive.ObjectID)
+		partial.Data = data
+		return c.Status(201).JSON(partial)
+	}
+
+	update := bson.M{"$set": bson.M{
+		"data":      storedData,
+		"updatedAt": now,
+	}}
+	if key != nil {
+		update["$set"].(bson.M)["encryption"] = key
+	} else {
+		update["$unset"] = bson.M{"encryption": ""}
+	}
+
+	var partial models.PartialResponse
+	err = partialsCollection.FindOneAndUpdate(
+		context.Background(),
+		bson.M{
+			"formId":      formObjID,
+			"resumeToken": req.ResumeToken,
+			"completedAt": bson.M{"$exists": false},
+		},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&partial)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Partial response not found or already submitted",
+			})
+		}
+		log.Printf("Error updating partial response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to save partial response",
+		})
+	}
+
+	partial.Data = data
+	return c.JSON(partial)
+}
+
+func getPartialResponse(c *fiber.Ctx) error {
+	token := c.Params("token")
+
+	collection := database.Collection("partial_responses")
+	var partial models.PartialResponse
+	err := collection.FindOne(context.Background(), bson.M{
+		"resumeToken": token,
+		"completedAt": bson.M{"$exists": false},
+	}).Decode(&partial)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Partial response not found or already submitted",
+			})
+		}
+		log.Printf("Error fetching partial response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch partial response",
+		})
+	}
+
+	// The resume token is the respondent's access to their own answers
+	partial.Data, err = fieldCipher.Decrypt(context.Background(), partial.Data, partial.Encryption)
+	if err != nil {
+		log.Printf("Error decrypting partial response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch partial response",
+		})
+	}
+
+	return c.JSON(partial)
+}
+
+// completePartialResponse links a resumed partial submission to its final response
+func completePartialResponse(formID primitive.ObjectID, token string, responseID primitive.ObjectID) {
+	collection := database.Collection("partial_responses")
+	_, err := collection.UpdateOne(context.Background(), bson.M{
+		"formId":      formID,
+		"resumeToken": token,
+		"completedAt": bson.M{"$exists": false},
+	}, bson.M{"$set": bson.M{
+		"completedAt": time.Now(),
+		"responseId":  responseID,
+	}})
+	if err != nil {
+		log.Printf("Error completing partial response: %v", err)
+	}
 }
 
-func getDeviceFromUserAgent(userAgent string) string {
-	if containsAny(userAgent, []string{"Mobile", "Android", "iPhone"}) {
-		return "Mobile"
-	} else if containsAny(userAgent, []string{"Tablet", "iPad"}) {
-		return "Tablet"
+// maxAttributionLength bounds the client-supplied referrer and UTM values
+const maxAttributionLength = 500
+
+// responseAttribution resolves the referrer and campaign of a submission.
+// The renderer passes document.referrer and the utm_* parameters of its URL;
+// older renderers don't, so fall back to the Referer header, skipping it when
+// it is just the renderer's own page.
+func responseAttribution(c *fiber.Ctx, referrer string, utm models.UTMParams) (string, string, *models.UTMParams) {
+	header, _ := url.Parse(c.Get("Referer"))
+	if referrer == "" && header != nil && !isOwnOrigin(header) {
+		referrer = header.String()
+	}
+	if utm.IsEmpty() && header != nil {
+		query := header.Query()
+		utm = models.UTMParams{
+			Source:   query.Get("utm_source"),
+			Medium:   query.Get("utm_medium"),
+			Campaign: query.Get("utm_campaign"),
+			Term:     query.Get("utm_term"),
+			Content:  query.Get("utm_content"),
+		}
+	}
+
+	domain := ""
+	if u, err := url.Parse(referrer); err == nil && u.Host != "" && !isOwnOrigin(u) {
+		domain = strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	} else {
+		referrer = ""
+	}
+
+	utm = models.UTMParams{
+		Source:   truncate(strings.ToLower(strings.TrimSpace(utm.Source)), maxAttributionLength),
+		Medium:   truncate(strings.ToLower(strings.TrimSpace(utm.Medium)), maxAttributionLength),
+		Campaign: truncate(strings.TrimSpace(utm.Campaign), maxAttributionLength),
+		Term:     truncate(strings.TrimSpace(utm.Term), maxAttributionLength),
+		Content:  truncate(strings.TrimSpace(utm.Content), maxAttributionLength),
 	}
-	return "Desktop"
+	if utm.IsEmpty() {
+		return truncate(referrer, maxAttributionLength), domain, nil
+	}
+	return truncate(referrer, maxAttributionLength), domain, &utm
 }
 
-func containsAny(str string, substrings []string) bool {
-	for _, substr := range substrings {
-		if contains(str, substr) {
+// isOwnOrigin reports whether u points at one of the frontend's origins
+func isOwnOrigin(u *url.URL) bool {
+	origin := u.Scheme + "://" + u.Host
+	for _, allowed := range strings.Split(allowedOrigins, ",") {
+		if strings.EqualFold(strings.TrimSpace(allowed), origin) {
 			return true
 		}
 	}
 	return false
 }
 
-func contains(str, substr string) bool {
-	return len(str) >= len(substr) && (str[:len(substr)] == substr || 
-		   (len(str) > len(substr) && contains(str[1:], substr)))
+// truncate shortens s to at most max bytes without splitting a character
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
 }
 
 func validateFormData(data map[string]interface{}, fields []models.FormField) error {
@@ -520,6 +1523,15 @@ func validateFormData(data map[string]interface{}, fields []models.FormField) er
 		fieldMap[field.ID] = field
 	}
 
+	// Answers can't pass for encrypted ones
+	if fieldID, forged := services.ForgedSealedAnswer(data); forged {
+		label := fieldID
+		if field, exists := fieldMap[fieldID]; exists && field.Label != "" {
+			label = field.Label
+		}
+		return fmt.Errorf("%s has an invalid value", label)
+	}
+
 	// Check required fields
 	for _, field := range fields {
 		if field.Required {
@@ -608,7 +1620,7 @@ func validateFieldValue(value interface{}, field models.FormField) error {
 			}
 			if !matched {
 				if message, exists := validation["message"]; exists {
-					return fmt.Errorf(message.(string))
+					return errors.New(message.(string))
 				}
 				return fmt.Errorf("format is invalid")
 			}
@@ -642,6 +1654,11 @@ func getPublicForm(c *fiber.Ctx) error {
 	}).Decode(&form)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
+			if isFormArchived(objID) {
+				return c.Status(410).JSON(fiber.Map{
+					"error": "This form has been archived and no longer accepts responses",
+				})
+			}
 			return c.Status(404).JSON(fiber.Map{
 				"error": "Form not found or not published",
 			})
@@ -652,9 +1669,99 @@ func getPublicForm(c *fiber.Ctx) error {
 		})
 	}
 
+	// Saved analytics segments are for the form owner only
+	form.Segments = nil
+
 	return c.JSON(form)
 }
 
+// maxEventsPerRequest bounds the batch size accepted by trackFormEvents
+const maxEventsPerRequest = 100
+
+func trackFormEvents(c *fiber.Ctx) error {
+	id := c.Params("id")
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid form ID",
+		})
+	}
+
+	// Parse the raw body so navigator.sendBeacon payloads (sent as text/plain)
+	// are accepted as well as regular JSON requests
+	var req models.TrackEventsRequest
+	if err := json.Unmarshal(c.Body(), &req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if req.SessionID == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Session ID is required",
+		})
+	}
+
+	if len(req.Events) == 0 || len(req.Events) > maxEventsPerRequest {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("Between 1 and %d events are required", maxEventsPerRequest),
+		})
+	}
+
+	count, err := database.Collection("forms").CountDocuments(context.Background(), bson.M{
+		"_id":      objID,
+		"status":   "published",
+		"isActive": true,
+	})
+	if err != nil {
+		log.Printf("Error finding form: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to verify form",
+		})
+	}
+	if count == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Form not found or not published",
+		})
+	}
+
+	now := time.Now()
+	events := make([]interface{}, 0, len(req.Events))
+	for _, e := range req.Events {
+		if !models.IsValidEventType(e.Type) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("Unknown event type %q", e.Type),
+			})
+		}
+
+		// Trust client timestamps so batched events keep their real spacing,
+		// but never from the future or older than a day
+		createdAt := now
+		if e.Timestamp != nil && !e.Timestamp.After(now) && now.Sub(*e.Timestamp) < 24*time.Hour {
+			createdAt = *e.Timestamp
+		}
+
+		events = append(events, models.FormEvent{
+			FormID:    objID,
+			SessionID: req.SessionID,
+			Type:      e.Type,
+			FieldID:   e.FieldID,
+			CreatedAt: createdAt,
+		})
+	}
+
+	if _, err := database.Collection("form_events").InsertMany(context.Background(), events); err != nil {
+		log.Printf("Error recording form events: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to record events",
+		})
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"accepted": len(events),
+	})
+}
+
 func getResponsesByForm(c *fiber.Ctx) error {
 	formID := c.Params("formId")
 	
@@ -670,8 +1777,9 @@ func getResponsesByForm(c *fiber.Ctx) error {
 	formsCollection := database.Collection("forms")
 	var form models.Form
 	err = formsCollection.FindOne(context.Background(), bson.M{
-		"_id":    formObjID,
-		"userId": "default", // TODO: get from auth
+		"_id":       formObjID,
+		"userId":    "default", // TODO: get from auth
+		"deletedAt": nil,
 	}).Decode(&form)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
@@ -684,59 +1792,1190 @@ func getResponsesByForm(c *fiber.Ctx) error {
 		})
 	}
 
-	// Get responses
-	responsesCollection := database.Collection("responses")
-	cursor, err := responsesCollection.Find(
-		context.Background(), 
-		bson.M{"formId": formObjID},
-		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
-	)
+	query, _, err := responseListQueryFromRequest(c, &form)
 	if err != nil {
-		log.Printf("Error fetching responses: %v", err)
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch responses",
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
 		})
 	}
-	defer cursor.Close(context.Background())
+	query.Cursor = c.Query("cursor")
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > services.MaxPageSize {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("limit must be between 1 and %d", services.MaxPageSize),
+			})
+		}
+		query.Limit = n
+	}
 
-	var responses []models.FormResponse
-	if err := cursor.All(context.Background(), &responses); err != nil {
-		log.Printf("Error decoding responses: %v", err)
+	page, err := responseService.ListResponses(context.Background(), &form, query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuery) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		log.Printf("Error fetching responses: %v", err)
 		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to decode responses",
+			"error": "Failed to fetch responses",
 		})
 	}
 
-	if responses == nil {
-		responses = []models.FormResponse{}
+	for i := range page.Responses {
+		presentResponse(c, &form, &page.Responses[i])
 	}
 
-	return c.JSON(responses)
+	return c.JSON(page)
 }
 
-func getResponse(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{"message": "Get response endpoint"})
+// responseListQueryFromRequest reads the listing's filter, search and sort
+// parameters. Dates are read in ?tz=, or else the form's timezone, which is
+// returned with the query.
+func responseListQueryFromRequest(c *fiber.Ctx, form *models.Form) (models.ResponseListQuery, *time.Location, error) {
+	loc, err := services.LoadLocation(form.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	if tz := c.Query("tz"); tz != "" {
+		if loc, err = services.LoadLocation(tz); err != nil {
+			return models.ResponseListQuery{}, nil, fmt.Errorf("invalid timezone %q", tz)
+		}
+	}
+
+	query, err := services.NewResponseListQuery(form, responseFilterFromQuery(c), c.Query("sort"), c.Query("order"), loc)
+	if err != nil {
+		return models.ResponseListQuery{}, nil, err
+	}
+	query.Search = search.QueryTerms(c.Query("q"))
+	return query, loc, nil
 }
 
-func getFormAnalytics(c *fiber.Ctx) error {
-	formID := c.Params("formId")
-	
-	analytics, err := analyticsService.GetFormAnalytics(formID)
+// exportResponses streams the responses matching the listing's filters as a
+// CSV, XLSX, JSON or NDJSON download
+func exportResponses(c *fiber.Ctx) error {
+	formObjID, err := primitive.ObjectIDFromHex(c.Params("formId"))
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to get analytics",
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid form ID",
 		})
 	}
 
-	return c.JSON(analytics)
-}
+	format := c.Query("format", services.ExportCSV)
+	contentType, ok := services.ExportContentTypes[format]
+	if !ok || format == services.ExportPDF {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "format must be one of csv, xlsx, json or ndjson",
+		})
+	}
 
-func getRealTimeAnalytics(c *fiber.Ctx) error {
-	formID := c.Params("formId")
-	
-	// Get current analytics
-	analytics, err := analyticsService.GetFormAnalytics(formID)
+	var form models.Form
+	err = database.Collection("forms").FindOne(context.Background(), bson.M{
+		"_id":       formObjID,
+		"userId":    "default", // TODO: get from auth
+		"deletedAt": nil,
+	}).Decode(&form)
 	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to verify form",
+		})
+	}
+
+	query, loc, err := responseListQueryFromRequest(c, &form)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	reveal := canReadSensitive(c, &form)
+	filename := services.ExportFilename(&form, format, time.Now().In(loc))
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// Headers are already sent, so a failure can only cut the file short
+		written, err := responseService.ExportResponses(context.Background(), &form, query, format, loc, w, reveal, func(int64) {
+			w.Flush()
+		})
+		if err != nil {
+			log.Printf("Error exporting responses for form %s after %d rows: %v", formObjID.Hex(), written, err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// findOwnedForm loads a form by its hex ID, checking it belongs to the
+// user. It returns mongo.ErrNoDocuments when the form is missing or the ID
+// is malformed.
+func findOwnedForm(id string) (*models.Form, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	var form models.Form
+	err = database.Collection("forms").FindOne(context.Background(), bson.M{
+		"_id":       objID,
+		"userId":    "default", // TODO: get from auth
+		"deletedAt": nil,
+	}).Decode(&form)
+	if err != nil {
+		return nil, err
+	}
+	return &form, nil
+}
+
+func createExport(c *fiber.Ctx) error {
+	form, err := findOwnedForm(c.Params("id"))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to verify form",
+		})
+	}
+
+	var req models.CreateExportRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	job, err := exportJobService.Create(context.Background(), form, "default", canReadSensitive(c, form), req) // TODO: get from auth
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuery) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		log.Printf("Error creating export: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to create export",
+		})
+	}
+
+	return c.Status(202).JSON(job)
+}
+
+func getExports(c *fiber.Ctx) error {
+	form, err := findOwnedForm(c.Params("id"))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to verify form",
+		})
+	}
+
+	jobs, err := exportJobService.List(context.Background(), form.ID, 20)
+	if err != nil {
+		log.Printf("Error fetching exports: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch exports",
+		})
+	}
+
+	return c.JSON(jobs)
+}
+
+// getRetentionRuns lists the audit records of the retention runs that
+// covered a form
+func getRetentionRuns(c *fiber.Ctx) error {
+	form, err := findOwnedForm(c.Params("id"))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to verify form",
+		})
+	}
+
+	if retentionService == nil {
+		return c.JSON([]models.RetentionRun{})
+	}
+	limit := c.QueryInt("limit", 50)
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+	runs, err := retentionService.Runs(context.Background(), form.ID, int64(limit))
+	if err != nil {
+		log.Printf("Error fetching retention runs: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch retention runs",
+		})
+	}
+
+	return c.JSON(runs)
+}
+
+// findOwnedExport loads an export job of one of the user's forms
+func findOwnedExport(c *fiber.Ctx) (*models.ExportJob, error) {
+	form, err := findOwnedForm(c.Params("id"))
+	if err != nil {
+		return nil, err
+	}
+	jobID, err := primitive.ObjectIDFromHex(c.Params("jobId"))
+	if err != nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	return exportJobService.Get(context.Background(), form.ID, jobID)
+}
+
+func getExport(c *fiber.Ctx) error {
+	job, err := findOwnedExport(c)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Export not found",
+			})
+		}
+		log.Printf("Error fetching export: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch export",
+		})
+	}
+
+	return c.JSON(job)
+}
+
+func downloadExport(c *fiber.Ctx) error {
+	job, err := findOwnedExport(c)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Export not found",
+			})
+		}
+		log.Printf("Error fetching export: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch export",
+		})
+	}
+
+	artifact, size, err := exportJobService.Open(job)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrExportNotReady):
+			return c.Status(409).JSON(fiber.Map{
+				"error": "Export is not ready",
+			})
+		case errors.Is(err, services.ErrExportExpired), errors.Is(err, blob.ErrNotFound):
+			return c.Status(410).JSON(fiber.Map{
+				"error": "Export has expired",
+			})
+		}
+		log.Printf("Error opening export %s: %v", job.ID.Hex(), err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to download export",
+		})
+	}
+
+	c.Set(fiber.HeaderContentType, services.ExportContentTypes[job.Format])
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, job.Filename))
+	return c.SendStream(artifact, int(size))
+}
+
+func deleteExport(c *fiber.Ctx) error {
+	job, err := findOwnedExport(c)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Export not found",
+			})
+		}
+		log.Printf("Error fetching export: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch export",
+		})
+	}
+
+	if err := exportJobService.Delete(context.Background(), job); err != nil {
+		log.Printf("Error deleting export %s: %v", job.ID.Hex(), err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete export",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Export deleted successfully",
+	})
+}
+
+// findOwnedResponse loads a response and its form, checking the form belongs
+// to the user. It returns mongo.ErrNoDocuments when either is missing, so
+// other users' responses look like they don't exist.
+func findOwnedResponse(id primitive.ObjectID) (*models.FormResponse, *models.Form, error) {
+	var response models.FormResponse
+	err := database.Collection("responses").FindOne(context.Background(), bson.M{"_id": id}).Decode(&response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var form models.Form
+	err = database.Collection("forms").FindOne(context.Background(), bson.M{
+		"_id":       response.FormID,
+		"userId":    "default", // TODO: get from auth
+		"deletedAt": nil,
+	}).Decode(&form)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &response, &form, nil
+}
+
+// presentResponse decrypts a response's PII answers for a reader allowed to
+// see them, and masks them for anyone else
+func presentResponse(c *fiber.Ctx, form *models.Form, response *models.FormResponse) {
+	if !canReadSensitive(c, form) {
+		response.Data = services.MaskSensitive(form, response.Data)
+		return
+	}
+	if err := fieldCipher.Reveal(context.Background(), form, response); err != nil {
+		log.Printf("Error decrypting response %s: %v", response.ID.Hex(), err)
+	}
+}
+
+func getResponse(c *fiber.Ctx) error {
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid response ID",
+		})
+	}
+
+	response, form, err := findOwnedResponse(objID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Response not found",
+			})
+		}
+		log.Printf("Error fetching response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch response",
+		})
+	}
+
+	presentResponse(c, form, response)
+	return c.JSON(response)
+}
+
+func updateResponse(c *fiber.Ctx) error {
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid response ID",
+		})
+	}
+
+	var req models.UpdateResponseRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	_, form, err := findOwnedResponse(objID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Response not found",
+			})
+		}
+		log.Printf("Error fetching response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch response",
+		})
+	}
+
+	set := bson.M{}
+	update := bson.M{"$set": set}
+	var data map[string]interface{}
+	var key *models.WrappedKey
+	if req.Data != nil {
+		if err := validateFormData(*req.Data, form.Fields); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		data, key, err = fieldCipher.Encrypt(context.Background(), form, *req.Data)
+		if err != nil {
+			log.Printf("Error encrypting response: %v", err)
+			return c.Status(500).JSON(fiber.Map{
+				"error": "Failed to update response",
+			})
+		}
+		set["data"] = data
+		set["searchText"] = search.Text(form, data)
+		set["rollup"] = services.RollupModes(form, data)
+		if key != nil {
+			set["encryption"] = key
+		} else {
+			update["$unset"] = bson.M{"encryption": ""}
+		}
+	}
+	if req.Tags != nil {
+		set["tags"] = normalizeTags(*req.Tags)
+	}
+	if req.Status != nil {
+		if !isReviewStatus(*req.Status) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "status must be one of " + strings.Join(models.ReviewStatuses, ", "),
+			})
+		}
+		set["status"] = *req.Status
+	}
+	if req.Assignee != nil {
+		set["assignee"] = truncate(strings.TrimSpace(*req.Assignee), 100)
+	}
+	if len(set) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "No changes given",
+		})
+	}
+
+	// Swap the old answers for the new in the rollups using the document as
+	// it was just before this update
+	var previous models.FormResponse
+	err = database.Collection("responses").FindOneAndUpdate(context.Background(),
+		bson.M{"_id": objID},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&previous)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Response not found",
+			})
+		}
+		log.Printf("Error updating response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to update response",
+		})
+	}
+
+	updated := previous
+	if req.Data != nil {
+		updated.Data, updated.Encryption = data, key
+		updated.Rollup = set["rollup"].(map[string]string)
+		if err := analyticsService.RemoveResponses(context.Background(), form, []models.FormResponse{previous}); err != nil {
+			log.Printf("Error updating analytics rollups: %v", err)
+		} else if err := analyticsService.RecordResponse(context.Background(), form, &updated); err != nil {
+			log.Printf("Error updating analytics rollups: %v", err)
+		}
+		scheduleAnalyticsBroadcast(form.ID.Hex())
+	}
+	if req.Tags != nil {
+		updated.Tags = set["tags"].([]string)
+	}
+	if req.Status != nil {
+		updated.Status = *req.Status
+	}
+	if req.Assignee != nil {
+		updated.Assignee = set["assignee"].(string)
+	}
+	responseUpdated(&updated)
+
+	presentResponse(c, form, &updated)
+	return c.JSON(updated)
+}
+
+// isReviewStatus reports whether status is one of models.ReviewStatuses
+func isReviewStatus(status string) bool {
+	for _, s := range models.ReviewStatuses {
+		if status == s {
+			return true
+		}
+	}
+	return false
+}
+
+// responseUpdated tells the form's subscribers about a response's new tags
+// and review state
+func responseUpdated(response *models.FormResponse) {
+	formID := response.FormID.Hex()
+	status := response.Status
+	if status == "" {
+		status = models.ReviewNew
+	}
+	wsHub.BroadcastToForm(formID, ws.Message{
+		Type:      ws.MessageTypeResponseUpdated,
+		Timestamp: time.Now(),
+		FormID:    formID,
+		Data: fiber.Map{
+			"id":       response.ID.Hex(),
+			"formId":   formID,
+			"tags":     response.Tags,
+			"status":   status,
+			"assignee": response.Assignee,
+			"notes":    response.Notes,
+		},
+	})
+}
+
+// maxNoteLength is the longest note accepted, in bytes
+const maxNoteLength = 5000
+
+func addResponseNote(c *fiber.Ctx) error {
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid response ID",
+		})
+	}
+
+	var req models.AddResponseNoteRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Note text is required",
+		})
+	}
+	if len(text) > maxNoteLength {
+		return c.Status(400).JSON(fiber.Map{
+			"error": fmt.Sprintf("Notes can be at most %d characters", maxNoteLength),
+		})
+	}
+
+	if _, _, err := findOwnedResponse(objID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Response not found",
+			})
+		}
+		log.Printf("Error fetching response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch response",
+		})
+	}
+
+	note := models.ResponseNote{
+		ID:        primitive.NewObjectID(),
+		Author:    "default", // TODO: get from auth
+		Text:      text,
+		CreatedAt: time.Now(),
+	}
+
+	var updated models.FormResponse
+	err = database.Collection("responses").FindOneAndUpdate(context.Background(),
+		bson.M{"_id": objID},
+		bson.M{"$push": bson.M{"notes": note}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Response not found",
+			})
+		}
+		log.Printf("Error adding note: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to add note",
+		})
+	}
+	responseUpdated(&updated)
+
+	return c.Status(201).JSON(note)
+}
+
+func deleteResponseNote(c *fiber.Ctx) error {
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid response ID",
+		})
+	}
+	noteID, err := primitive.ObjectIDFromHex(c.Params("noteId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid note ID",
+		})
+	}
+
+	if _, _, err := findOwnedResponse(objID); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Response not found",
+			})
+		}
+		log.Printf("Error fetching response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch response",
+		})
+	}
+
+	var updated models.FormResponse
+	err = database.Collection("responses").FindOneAndUpdate(context.Background(),
+		bson.M{"_id": objID, "notes._id": noteID},
+		bson.M{"$pull": bson.M{"notes": bson.M{"_id": noteID}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Note not found",
+			})
+		}
+		log.Printf("Error deleting note: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete note",
+		})
+	}
+	responseUpdated(&updated)
+
+	return c.JSON(fiber.Map{
+		"message": "Note deleted successfully",
+	})
+}
+
+func deleteResponse(c *fiber.Ctx) error {
+	objID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid response ID",
+		})
+	}
+
+	response, form, err := findOwnedResponse(objID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Response not found",
+			})
+		}
+		log.Printf("Error fetching response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to fetch response",
+		})
+	}
+
+	deleted, err := responseService.DeleteResponses(context.Background(), bson.M{"_id": response.ID}, func(batch []models.FormResponse) {
+		responsesDeleted(form, batch)
+	})
+	if err != nil {
+		log.Printf("Error deleting response: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to delete response",
+		})
+	}
+	if deleted == 0 {
+		return c.Status(404).JSON(fiber.Map{
+			"error": "Response not found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Response deleted successfully",
+	})
+}
+
+// importResponses loads historical responses from an uploaded CSV or JSON
+// file. Rows keep their original submission times; rows that fail
+// validation are reported by number and skipped. Subscribers get a single
+// summary message rather than one per response.
+func importResponses(c *fiber.Ctx) error {
+	form, err := findOwnedForm(c.Params("formId"))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to verify form",
+		})
+	}
+
+	upload, err := readImportUpload(c)
+	if err != nil {
+		// The rest of the body may be unread
+		c.Context().SetConnectionClose()
+		if errors.Is(err, errBodyTooLarge) {
+			return c.Status(413).JSON(fiber.Map{
+				"error": fmt.Sprintf("Import files are limited to %d MB", importBodyLimit>>20),
+			})
+		}
+		return c.Status(400).JSON(fiber.Map{
+			"error": "An import file is required",
+		})
+	}
+	defer upload.RemoveAll()
+	if len(upload.File["file"]) == 0 {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "An import file is required",
+		})
+	}
+	header := upload.File["file"][0]
+	formValue := func(key string) string {
+		if values := upload.Value[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	format := formValue("format")
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		if format == "ndjson" {
+			format = services.ImportJSON
+		}
+	}
+
+	opts := services.ImportOptions{DryRun: formValue("dryRun") == "true"}
+	if mapping := formValue("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &opts.Mapping); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "mapping must be a JSON object of column names to field IDs",
+			})
+		}
+	}
+	// Timestamps without a zone are read in ?tz=, or else the form's timezone
+	opts.Location, err = services.LoadLocation(form.Timezone)
+	if err != nil {
+		opts.Location = time.UTC
+	}
+	if tz := formValue("tz"); tz != "" {
+		if opts.Location, err = services.LoadLocation(tz); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": fmt.Sprintf("invalid timezone %q", tz),
+			})
+		}
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		log.Printf("Error opening import file: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to read import file",
+		})
+	}
+	defer file.Close()
+
+	source, err := services.NewImportSource(format, file)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuery) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		log.Printf("Error reading import file: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to read import file",
+		})
+	}
+
+	validate := func(data map[string]interface{}) error {
+		return validateFormData(data, form.Fields)
+	}
+	recordRollups := func(batch []models.FormResponse) {
+		if err := analyticsService.RecordResponses(context.Background(), form, batch); err != nil {
+			log.Printf("Error updating analytics rollups: %v", err)
+		}
+	}
+	result, err := responseService.ImportResponses(context.Background(), form, source, opts, validate, recordRollups)
+
+	// Rows saved before a failure stay imported, so announce them either way
+	if result != nil && result.Imported > 0 && !result.DryRun {
+		wsHub.BroadcastToForm(form.ID.Hex(), ws.Message{
+			Type:      ws.MessageTypeResponsesImported,
+			Timestamp: time.Now(),
+			FormID:    form.ID.Hex(),
+			Data: fiber.Map{
+				"formId":   form.ID.Hex(),
+				"imported": result.Imported,
+			},
+		})
+		scheduleAnalyticsBroadcast(form.ID.Hex())
+	}
+
+	if err != nil {
+		status, message := 500, "Failed to import responses"
+		if errors.Is(err, services.ErrInvalidQuery) {
+			status, message = 400, err.Error()
+		} else {
+			log.Printf("Error importing responses: %v", err)
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":  message,
+			"result": result,
+		})
+	}
+
+	status := 201
+	if result.DryRun {
+		status = 200
+	}
+	return c.Status(status).JSON(result)
+}
+
+// bulkDeleteResponses deletes the responses of a form listed by ID, or all
+// those matching a filter and/or search. An empty request is refused rather
+// than deleting everything.
+func bulkDeleteResponses(c *fiber.Ctx) error {
+	formObjID, err := primitive.ObjectIDFromHex(c.Params("formId"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid form ID",
+		})
+	}
+
+	var req models.BulkDeleteResponsesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	var form models.Form
+	err = database.Collection("forms").FindOne(context.Background(), bson.M{
+		"_id":       formObjID,
+		"userId":    "default", // TODO: get from auth
+		"deletedAt": nil,
+	}).Decode(&form)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to verify form",
+		})
+	}
+
+	var match bson.M
+	terms := search.QueryTerms(req.Query)
+	switch {
+	case len(req.IDs) > 0:
+		if !req.Filter.IsEmpty() || len(terms) > 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error": "Give either ids or a filter, not both",
+			})
+		}
+		ids := make([]primitive.ObjectID, 0, len(req.IDs))
+		for _, id := range req.IDs {
+			objID, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"error": fmt.Sprintf("Invalid response ID %q", id),
+				})
+			}
+			ids = append(ids, objID)
+		}
+		match = bson.M{"formId": formObjID, "_id": bson.M{"$in": ids}}
+	case !req.Filter.IsEmpty() || len(terms) > 0:
+		loc, err := services.LoadLocation(form.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		criteria, err := services.ParseResponseFilter(&form, req.Filter, loc)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		match = services.ResponseMatch(formObjID, criteria, terms)
+	default:
+		return c.Status(400).JSON(fiber.Map{
+			"error": "Give the ids of the responses to delete, or a filter",
+		})
+	}
+
+	deleted, err := responseService.DeleteResponses(context.Background(), match, func(batch []models.FormResponse) {
+		responsesDeleted(&form, batch)
+	})
+	if err != nil {
+		log.Printf("Error deleting responses: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "Failed to delete responses",
+			"deleted": deleted,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Responses deleted successfully",
+		"deleted": deleted,
+	})
+}
+
+// responsesDeleted takes a batch of deleted responses out of the rollups and
+// tells the form's dashboards, with one message for the whole batch
+func responsesDeleted(form *models.Form, deleted []models.FormResponse) {
+	if err := analyticsService.RemoveResponses(context.Background(), form, deleted); err != nil {
+		log.Printf("Error updating analytics rollups: %v", err)
+	}
+	broadcastResponsesDeleted(form, deleted)
+	scheduleAnalyticsBroadcast(form.ID.Hex())
+}
+
+// broadcastResponsesDeleted tells the form's subscribers which responses
+// are gone
+func broadcastResponsesDeleted(form *models.Form, deleted []models.FormResponse) {
+	formID := form.ID.Hex()
+	ids := make([]string, len(deleted))
+	for i, response := range deleted {
+		ids[i] = response.ID.Hex()
+	}
+	wsHub.BroadcastToForm(formID, ws.Message{
+		Type:      ws.MessageTypeResponseDeleted,
+		Timestamp: time.Now(),
+		FormID:    formID,
+		Data: fiber.Map{
+			"formId": formID,
+			"ids":    ids,
+		},
+	})
+}
+
+// maxSubmittedTags is the most tags a submission can carry
+const maxSubmittedTags = 10
+
+// normalizeTags trims tags and drops empty and repeated ones
+func normalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = truncate(strings.TrimSpace(tag), 100)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// analyticsOptionsFromQuery reads the optional ?tz= IANA timezone and ?bins=
+// histogram size of an analytics request
+func analyticsOptionsFromQuery(c *fiber.Ctx) (services.AnalyticsOptions, error) {
+	opts := services.AnalyticsOptions{}
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := services.LoadLocation(tz)
+		if err != nil {
+			return opts, fmt.Errorf("invalid timezone %q", tz)
+		}
+		opts.Location = loc
+	}
+	if bins := c.Query("bins"); bins != "" {
+		n, err := strconv.Atoi(bins)
+		if err != nil || n < 1 || n > services.MaxHistogramBins {
+			return opts, fmt.Errorf("bins must be between 1 and %d", services.MaxHistogramBins)
+		}
+		opts.HistogramBins = n
+	}
+	opts.Segment = c.Query("segment")
+	opts.Filter = responseFilterFromQuery(c)
+	return opts, nil
+}
+
+// responseFilterFromQuery reads a response filter from the from, to, device,
+// tag (comma-separated), status and assignee query parameters, plus field
+// conditions:
+// field.<fieldId>=<value> for an answer equal to value,
+// field.<fieldId>.contains=<text> for one containing text, and
+// field.<fieldId>.min=<n> and/or field.<fieldId>.max=<n> for a numeric range
+func responseFilterFromQuery(c *fiber.Ctx) models.ResponseFilter {
+	filter := models.ResponseFilter{
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+		Device:   c.Query("device"),
+		Status:   c.Query("status"),
+		Assignee: c.Query("assignee"),
+	}
+	for _, tag := range strings.Split(c.Query("tag"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			filter.Tags = append(filter.Tags, tag)
+		}
+	}
+
+	// Sort the field conditions so the echoed filter is stable
+	var keys []string
+	queries := c.Queries()
+	for key := range queries {
+		if strings.HasPrefix(key, "field.") {
+			keys = append(keys, strings.TrimPrefix(key, "field."))
+		}
+	}
+	sort.Strings(keys)
+
+	ranges := make(map[string]int) // field ID to index of its range filter
+	for _, key := range keys {
+		value := queries["field."+key]
+		fieldID, op := key, ""
+		if i := strings.LastIndexByte(key, '.'); i >= 0 {
+			fieldID, op = key[:i], key[i+1:]
+		}
+		switch op {
+		case "":
+			filter.Fields = append(filter.Fields, models.FieldFilter{FieldID: fieldID, Value: value})
+		case models.FieldOpContains:
+			filter.Fields = append(filter.Fields, models.FieldFilter{FieldID: fieldID, Op: op, Value: value})
+		case "min", "max":
+			i, ok := ranges[fieldID]
+			if !ok {
+				i = len(filter.Fields)
+				ranges[fieldID] = i
+				filter.Fields = append(filter.Fields, models.FieldFilter{FieldID: fieldID, Op: models.FieldOpRange})
+			}
+			r := &filter.Fields[i]
+			if op == "min" {
+				r.Min = value
+			} else {
+				r.Max = value
+			}
+		default:
+			// Unknown operators fail validation as an unknown field
+			filter.Fields = append(filter.Fields, models.FieldFilter{FieldID: key, Value: value})
+		}
+	}
+	return filter
+}
+
+func getFormAnalytics(c *fiber.Ctx) error {
+	formID := c.Params("formId")
+
+	opts, err := analyticsOptionsFromQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	analytics, err := analyticsService.GetFormAnalytics(formID, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuery) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get analytics",
+		})
+	}
+
+	return c.JSON(analytics)
+}
+
+func getFormFunnel(c *fiber.Ctx) error {
+	formID := c.Params("formId")
+
+	funnel, err := analyticsService.GetFormFunnel(formID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		log.Printf("Error getting form funnel: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get funnel analytics",
+		})
+	}
+
+	return c.JSON(funnel)
+}
+
+func getResponseTrends(c *fiber.Ctx) error {
+	formID := c.Params("formId")
+
+	opts, err := analyticsOptionsFromQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// from and to select the trend's range rather than filtering it
+	opts.Filter.From, opts.Filter.To = "", ""
+
+	trends, err := analyticsService.GetResponseTrends(formID, services.TrendQuery{
+		AnalyticsOptions: opts,
+		From:             c.Query("from"),
+		To:               c.Query("to"),
+		Interval:         c.Query("interval"),
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuery) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		log.Printf("Error getting response trends: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get response trends",
+		})
+	}
+
+	return c.JSON(trends)
+}
+
+func getCrossTab(c *fiber.Ctx) error {
+	formID := c.Params("formId")
+
+	crossTab, err := analyticsService.GetCrossTab(formID, c.Query("row"), c.Query("column"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuery) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		if err == mongo.ErrNoDocuments {
+			return c.Status(404).JSON(fiber.Map{
+				"error": "Form not found",
+			})
+		}
+		log.Printf("Error getting cross-tab: %v", err)
+		return c.Status(500).JSON(fiber.Map{
+			"error": "Failed to get cross-tab",
+		})
+	}
+
+	return c.JSON(crossTab)
+}
+
+func getRealTimeAnalytics(c *fiber.Ctx) error {
+	formID := c.Params("formId")
+	
+	opts, err := analyticsOptionsFromQuery(c)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	// Get current analytics
+	analytics, err := analyticsService.GetFormAnalytics(formID, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidQuery) {
+			return c.Status(400).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
 		return c.Status(500).JSON(fiber.Map{
 			"error": "Failed to get analytics",
 		})
@@ -769,7 +3008,7 @@ func saveDraft(c *fiber.Ctx) error {
 	// First, get the current form to preserve its status
 	collection := database.Collection("forms")
 	var currentForm models.Form
-	err = collection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&currentForm)
+	err = collection.FindOne(context.Background(), bson.M{"_id": objID, "deletedAt": nil}).Decode(&currentForm)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return c.Status(404).JSON(fiber.Map{
@@ -795,11 +3034,13 @@ func saveDraft(c *fiber.Ctx) error {
 	if req.Description != nil {
 		update["$set"].(bson.M)["description"] = *req.Description
 	}
+	var newlyPII []string
 	if req.Fields != nil {
 		update["$set"].(bson.M)["fields"] = *req.Fields
+		newlyPII = newlyPIIFields(currentForm.Fields, *req.Fields)
 	}
 
-	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": objID}, update)
+	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": objID, "deletedAt": nil}, update)
 	if err != nil {
 		log.Printf("Error saving draft: %v", err)
 		return c.Status(500).JSON(fiber.Map{
@@ -812,6 +3053,7 @@ func saveDraft(c *fiber.Ctx) error {
 			"error": "Form not found",
 		})
 	}
+	forgetPIIAnswers(objID, newlyPII)
 
 	// Fetch and return the updated form
 	var updatedForm models.Form
@@ -847,7 +3089,7 @@ func unpublishForm(c *fiber.Ctx) error {
 	}
 
 	collection := database.Collection("forms")
-	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": objID}, update)
+	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": objID, "deletedAt": nil}, update)
 	if err != nil {
 		log.Printf("Error unpublishing form: %v", err)
 		return c.Status(500).JSON(fiber.Map{
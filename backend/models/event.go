@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Event types reported by the public form renderer
+const (
+	EventTypeView       = "view"
+	EventTypeStart      = "start"
+	EventTypeFieldFocus = "field_focus"
+	EventTypeSubmit     = "submit"
+)
+
+// FormEvent is a single respondent interaction with a public form
+type FormEvent struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	FormID    primitive.ObjectID `json:"formId" bson:"formId"`
+	SessionID string             `json:"sessionId" bson:"sessionId"`
+	Type      string             `json:"type" bson:"type"`
+	FieldID   string             `json:"fieldId,omitempty" bson:"fieldId,omitempty"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+type TrackEventsRequest struct {
+	SessionID string `json:"sessionId"`
+	Events    []struct {
+		Type      string     `json:"type"`
+		FieldID   string     `json:"fieldId,omitempty"`
+		Timestamp *time.Time `json:"timestamp,omitempty"`
+	} `json:"events"`
+}
+
+// IsValidEventType reports whether t is one of the known event types
+func IsValidEventType(t string) bool {
+	switch t {
+	case EventTypeView, EventTypeStart, EventTypeFieldFocus, EventTypeSubmit:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Export job statuses
+const (
+	ExportQueued    = "queued"
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+	ExportExpired   = "expired" // the artifact was deleted after its TTL
+)
+
+// ExportJob is a background export of a form's responses to a downloadable
+// file
+type ExportJob struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	FormID primitive.ObjectID `json:"formId" bson:"formId"`
+	UserID string             `json:"-" bson:"userId"`
+	Format string             `json:"format" bson:"format"` // csv, xlsx, json, ndjson or pdf
+	// The responses to export, as for the response listing
+	Filter   ResponseFilter `json:"filter" bson:"filter"`
+	Query    string         `json:"q,omitempty" bson:"q,omitempty"`
+	Sort     string         `json:"sort,omitempty" bson:"sort,omitempty"`
+	Order    string         `json:"order,omitempty" bson:"order,omitempty"`
+	Timezone string         `json:"tz,omitempty" bson:"tz,omitempty"`
+	// Reveal decrypts PII answers in the file instead of masking them
+	Reveal bool `json:"-" bson:"reveal,omitempty"`
+
+	Status string `json:"status" bson:"status"`
+	// Total is the number of matching responses when the job started,
+	// Processed how many have been written so far, and Progress the
+	// percentage done
+	Total       int64      `json:"total" bson:"total"`
+	Processed   int64      `json:"processed" bson:"processed"`
+	Progress    float64    `json:"progress" bson:"progress"`
+	Error       string     `json:"error,omitempty" bson:"error,omitempty"`
+	BlobKey     string     `json:"-" bson:"blobKey,omitempty"`
+	Filename    string     `json:"filename,omitempty" bson:"filename,omitempty"`
+	Size        int64      `json:"size,omitempty" bson:"size,omitempty"` // bytes
+	CreatedAt   time.Time  `json:"createdAt" bson:"createdAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty" bson:"startedAt,omitempty"`
+	HeartbeatAt *time.Time `json:"-" bson:"heartbeatAt,omitempty"` // when its worker last renewed the lease
+	CompletedAt *time.Time `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"` // when the artifact is deleted
+}
+
+// CreateExportRequest represents the request to start an export job
+type CreateExportRequest struct {
+	Format   string         `json:"format"`
+	Filter   ResponseFilter `json:"filter"`
+	Query    string         `json:"q"`
+	Sort     string         `json:"sort"`
+	Order    string         `json:"order"`
+	Timezone string         `json:"tz"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// ResponseFilter narrows a form's responses. From and To are RFC 3339
+// timestamps or YYYY-MM-DD dates in the form's timezone; a date-only To
+// includes that whole day. A response must match every condition given.
+type ResponseFilter struct {
+	From   string        `json:"from,omitempty" bson:"from,omitempty"`
+	To     string        `json:"to,omitempty" bson:"to,omitempty"`
+	Device string        `json:"device,omitempty" bson:"device,omitempty"` // Desktop, Mobile, Tablet or Other
+	Tags   []string      `json:"tags,omitempty" bson:"tags,omitempty"`     // response must carry all of them
+	Fields []FieldFilter `json:"fields,omitempty" bson:"fields,omitempty"`
+	// Status is a review status; Assignee is a reviewer, or "none" for
+	// unassigned responses
+	Status   string `json:"status,omitempty" bson:"status,omitempty"`
+	Assignee string `json:"assignee,omitempty" bson:"assignee,omitempty"`
+}
+
+// UnassignedFilter is the Assignee filter matching unassigned responses
+const UnassignedFilter = "none"
+
+// Field filter operators
+const (
+	FieldOpEquals   = "eq"
+	FieldOpContains = "contains"
+	FieldOpRange    = "range"
+)
+
+// FieldFilter matches responses by their answer to a field. With Op "eq"
+// (the default) the answer must equal Value; with "contains" it must contain
+// Value, ignoring case; with "range" it must be a number between Min and Max
+// inclusive, either of which may be empty. For multi-select answers it is
+// enough for one selected option to match.
+type FieldFilter struct {
+	FieldID string `json:"fieldId" bson:"fieldId"`
+	Op      string `json:"op,omitempty" bson:"op,omitempty"`
+	Value   string `json:"value" bson:"value"`
+	Min     string `json:"min,omitempty" bson:"min,omitempty"`
+	Max     string `json:"max,omitempty" bson:"max,omitempty"`
+}
+
+// IsEmpty reports whether the filter matches every response
+func (f ResponseFilter) IsEmpty() bool {
+	return f.From == "" && f.To == "" && f.Device == "" && len(f.Tags) == 0 && len(f.Fields) == 0 &&
+		f.Status == "" && f.Assignee == ""
+}
+
+// ResponseCriteria is a ResponseFilter with its dates and bounds parsed,
+// ready for a storage backend to apply
+type ResponseCriteria struct {
+	From   time.Time // createdAt >= From; zero means unbounded
+	To     time.Time // createdAt < To; zero means unbounded
+	Device string
+	Tags   []string
+	Fields []FieldCriterion
+	Status string // a review status; ReviewNew also matches responses without one
+	// Assignee is a reviewer, or UnassignedFilter
+	Assignee string
+}
+
+// FieldCriterion is a parsed FieldFilter
+type FieldCriterion struct {
+	FieldID string
+	Op      string
+	Value   string
+	Min     *float64
+	Max     *float64
+}
+
+// Segment is a named, saved response filter
+type Segment struct {
+	Name      string         `json:"name" bson:"name"`
+	Filter    ResponseFilter `json:"filter" bson:"filter"`
+	CreatedAt time.Time      `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt" bson:"updatedAt"`
+}
+
+type SaveSegmentRequest struct {
+	Filter ResponseFilter `json:"filter"`
+}
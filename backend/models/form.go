@@ -14,6 +14,8 @@ type FormField struct {
 	Options     []string               `json:"options,omitempty" bson:"options,omitempty"`
 	Validation  map[string]interface{} `json:"validation,omitempty" bson:"validation,omitempty"`
 	Placeholder string                 `json:"placeholder,omitempty" bson:"placeholder,omitempty"`
+	Page        int                    `json:"page,omitempty" bson:"page,omitempty"` // 1-based; 0 means the first page
+	PII         bool                   `json:"pii,omitempty" bson:"pii,omitempty"`   // answers identify the respondent
 }
 
 type Form struct {
@@ -26,14 +28,21 @@ type Form struct {
 	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
 	IsActive      bool               `json:"isActive" bson:"isActive"`
 	UserID        string             `json:"userId" bson:"userId"`
-	ResponseCount int                `json:"responseCount" bson:"-"` // Not stored in DB, calculated
+	Timezone      string             `json:"timezone,omitempty" bson:"timezone,omitempty"` // IANA name used for analytics
+	Segments      []Segment          `json:"segments,omitempty" bson:"segments,omitempty"`
+	Retention     *RetentionPolicy   `json:"retention,omitempty" bson:"retention,omitempty"`
+	DeletedAt     *time.Time         `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"` // set while the form is in the trash
+	PurgeAt       *time.Time         `json:"purgeAt,omitempty" bson:"purgeAt,omitempty"`     // when a deleted form and its data are removed for good
+	ResponseCount int                `json:"responseCount" bson:"-"`                         // Not stored in DB, calculated
 }
 
 type CreateFormRequest struct {
-	Title       string      `json:"title" validate:"required"`
-	Description string      `json:"description"`
-	Fields      []FormField `json:"fields"`
-	Status      string      `json:"status"`
+	Title       string           `json:"title" validate:"required"`
+	Description string           `json:"description"`
+	Fields      []FormField      `json:"fields"`
+	Status      string           `json:"status"`
+	Timezone    string           `json:"timezone"`
+	Retention   *RetentionPolicy `json:"retention"`
 }
 
 type UpdateFormRequest struct {
@@ -42,4 +51,7 @@ type UpdateFormRequest struct {
 	Fields      *[]FormField `json:"fields,omitempty"`
 	Status      *string      `json:"status,omitempty"`
 	IsActive    *bool        `json:"isActive,omitempty"`
-}
\ No newline at end of file
+	Timezone    *string      `json:"timezone,omitempty"`
+	// Retention replaces the form's policy; zero days removes it
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+}
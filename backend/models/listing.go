@@ -0,0 +1,86 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SortByCreatedAt orders responses by submission time; any other sort key
+// is a field ID
+const SortByCreatedAt = "createdAt"
+
+// ResponseListQuery selects one page of a form's responses
+type ResponseListQuery struct {
+	Criteria   ResponseCriteria
+	Search     []string // terms every response must contain, from search.QueryTerms
+	Sort       string   // SortByCreatedAt or a field ID
+	Descending bool
+	Cursor     string // NextCursor of the previous page
+	Limit      int
+}
+
+// ResponsePage is one page of a response listing
+type ResponsePage struct {
+	Responses  []FormResponse `json:"responses"`
+	Total      int64          `json:"total"` // responses matching the filters, across all pages
+	NextCursor string         `json:"nextCursor,omitempty"`
+	HasMore    bool           `json:"hasMore"`
+	// Highlights holds snippets of the answers that matched a search,
+	// keyed by response ID
+	Highlights map[string][]Snippet `json:"highlights,omitempty"`
+}
+
+// Snippet is an excerpt of an answer with the words a search matched marked
+type Snippet struct {
+	FieldID string        `json:"fieldId"`
+	Parts   []SnippetPart `json:"parts"`
+}
+
+// SnippetPart is a run of snippet text that did or didn't match
+type SnippetPart struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// ErrInvalidCursor is returned for a cursor that is malformed or was issued
+// for a different sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ResponseCursor is the position after the last response of a page: its sort
+// value and ID, which breaks ties. Sort and Descending guard against reusing
+// a cursor with another ordering.
+type ResponseCursor struct {
+	Sort       string             `bson:"s"`
+	Descending bool               `bson:"d,omitempty"`
+	Value      interface{}        `bson:"v"`
+	ID         primitive.ObjectID `bson:"id"`
+}
+
+// Encode returns the cursor as an opaque URL-safe string
+func (c ResponseCursor) Encode() string {
+	b, err := bson.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeResponseCursor parses a cursor from Encode, checking it was issued
+// for the query's ordering
+func DecodeResponseCursor(s string, query ResponseListQuery) (ResponseCursor, error) {
+	var c ResponseCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := bson.Unmarshal(b, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.Sort != query.Sort || c.Descending != query.Descending {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
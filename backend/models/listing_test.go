@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestResponseCursorRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	submitted := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query ResponseListQuery
+		value interface{}
+		want  interface{}
+	}{
+		{"created at", ResponseListQuery{Sort: SortByCreatedAt, Descending: true}, submitted, primitive.NewDateTimeFromTime(submitted)},
+		{"string answer", ResponseListQuery{Sort: "field_1"}, "Berlin", "Berlin"},
+		{"number answer", ResponseListQuery{Sort: "field_2", Descending: true}, 4.5, 4.5},
+		{"missing answer", ResponseListQuery{Sort: "field_3"}, nil, nil},
+		{"bool answer", ResponseListQuery{Sort: "field_4"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded := ResponseCursor{Sort: tt.query.Sort, Descending: tt.query.Descending, Value: tt.value, ID: id}.Encode()
+			if encoded == "" {
+				t.Fatal("Encode returned an empty cursor")
+			}
+			decoded, err := DecodeResponseCursor(encoded, tt.query)
+			if err != nil {
+				t.Fatalf("DecodeResponseCursor: %v", err)
+			}
+			if decoded.ID != id {
+				t.Errorf("ID = %s, want %s", decoded.ID.Hex(), id.Hex())
+			}
+			if decoded.Value != tt.want {
+				t.Errorf("Value = %#v, want %#v", decoded.Value, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeResponseCursorRejects(t *testing.T) {
+	query := ResponseListQuery{Sort: "field_1", Descending: true}
+	valid := ResponseCursor{Sort: query.Sort, Descending: query.Descending, Value: "a", ID: primitive.NewObjectID()}.Encode()
+
+	tests := []struct {
+		name   string
+		cursor string
+		query  ResponseListQuery
+	}{
+		{"not base64", "!!!", query},
+		{"not bson", "aGVsbG8", query},
+		{"other sort", valid, ResponseListQuery{Sort: SortByCreatedAt, Descending: true}},
+		{"other order", valid, ResponseListQuery{Sort: "field_1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeResponseCursor(tt.cursor, tt.query); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("err = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
@@ -13,4 +13,131 @@ type FormResponse struct {
 	CreatedAt time.Time              `json:"createdAt" bson:"createdAt"`
 	IPAddress string                 `json:"ipAddress" bson:"ipAddress"`
 	UserAgent string                 `json:"userAgent" bson:"userAgent"`
-}
\ No newline at end of file
+	Device    string                 `json:"device,omitempty" bson:"device,omitempty"`
+	Browser   string                 `json:"browser,omitempty" bson:"browser,omitempty"`
+	OS        string                 `json:"os,omitempty" bson:"os,omitempty"`
+	Bot       bool                   `json:"bot,omitempty" bson:"bot,omitempty"`         // crawlers and scripts are left out of analytics
+	Country   string                 `json:"country,omitempty" bson:"country,omitempty"` // ISO 3166-1 alpha-2, from GeoIP
+	Region    string                 `json:"region,omitempty" bson:"region,omitempty"`
+	// Referrer is the page that linked to the form; ReferrerDomain is its
+	// host without "www.", empty for direct traffic
+	Referrer       string     `json:"referrer,omitempty" bson:"referrer,omitempty"`
+	ReferrerDomain string     `json:"referrerDomain,omitempty" bson:"referrerDomain,omitempty"`
+	UTM            *UTMParams `json:"utm,omitempty" bson:"utm,omitempty"`
+	SessionID      string     `json:"sessionId,omitempty" bson:"sessionId,omitempty"`
+	Tags           []string   `json:"tags,omitempty" bson:"tags,omitempty"`
+	// Source is ResponseSourceImport for responses imported from a file,
+	// and empty for submissions
+	Source string `json:"source,omitempty" bson:"source,omitempty"`
+	// Review state: Status is one of the Review* values, with an empty
+	// status meaning ReviewNew
+	Status   string         `json:"status,omitempty" bson:"status,omitempty"`
+	Assignee string         `json:"assignee,omitempty" bson:"assignee,omitempty"`
+	Notes    []ResponseNote `json:"notes,omitempty" bson:"notes,omitempty"`
+	// AnonymizedAt is set once a retention policy has stripped the
+	// response's IP address, user agent and PII answers
+	AnonymizedAt *time.Time `json:"anonymizedAt,omitempty" bson:"anonymizedAt,omitempty"`
+	// Rollup records how each answer was counted in the analytics rollups
+	// when it was stored, as one of the Rollup* values keyed by escaped
+	// field ID, so it is taken out the same way after the form changes
+	Rollup map[string]string `json:"-" bson:"rollup,omitempty"`
+	// Encryption is the data key the answers to PII fields are encrypted
+	// with, when field encryption is on
+	Encryption *WrappedKey `json:"-" bson:"encryption,omitempty"`
+	// SearchText is the answers joined for the full-text index; a single
+	// field keeps IPs and user agents out of a wildcard text index
+	SearchText string `json:"-" bson:"searchText,omitempty"`
+}
+
+// How an answer is counted in the analytics rollups
+const (
+	RollupValues   = "values"   // by value
+	RollupText     = "text"     // by words, phrases and sentiment
+	RollupAnswered = "answered" // only as answered
+)
+
+// ResponseSourceImport marks a response imported from a file
+const ResponseSourceImport = "import"
+
+// Review statuses
+const (
+	ReviewNew      = "new"
+	ReviewInReview = "in_review"
+	ReviewResolved = "resolved"
+)
+
+// ReviewStatuses lists the valid review statuses
+var ReviewStatuses = []string{ReviewNew, ReviewInReview, ReviewResolved}
+
+// ResponseNote is an internal note left on a response by a reviewer
+type ResponseNote struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Author    string             `json:"author" bson:"author"`
+	Text      string             `json:"text" bson:"text"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// UTMParams are the utm_* campaign parameters the form was opened with
+type UTMParams struct {
+	Source   string `json:"source,omitempty" bson:"source,omitempty"`
+	Medium   string `json:"medium,omitempty" bson:"medium,omitempty"`
+	Campaign string `json:"campaign,omitempty" bson:"campaign,omitempty"`
+	Term     string `json:"term,omitempty" bson:"term,omitempty"`
+	Content  string `json:"content,omitempty" bson:"content,omitempty"`
+}
+
+// IsEmpty reports whether no campaign parameters were given
+func (u UTMParams) IsEmpty() bool {
+	return u.Source == "" && u.Medium == "" && u.Campaign == "" && u.Term == "" && u.Content == ""
+}
+
+// PartialResponse is an unfinished submission that can be resumed with its token
+type PartialResponse struct {
+	ID          primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	FormID      primitive.ObjectID     `json:"formId" bson:"formId"`
+	ResumeToken string                 `json:"resumeToken" bson:"resumeToken"`
+	Data        map[string]interface{} `json:"data" bson:"data"`
+	CreatedAt   time.Time              `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt" bson:"updatedAt"`
+	CompletedAt *time.Time             `json:"completedAt,omitempty" bson:"completedAt,omitempty"`
+	ResponseID  *primitive.ObjectID    `json:"responseId,omitempty" bson:"responseId,omitempty"`
+	IPAddress   string                 `json:"ipAddress" bson:"ipAddress"`
+	UserAgent   string                 `json:"userAgent" bson:"userAgent"`
+	SessionID   string                 `json:"sessionId,omitempty" bson:"sessionId,omitempty"`
+	Encryption  *WrappedKey            `json:"-" bson:"encryption,omitempty"`
+}
+
+// WrappedKey is a record's data key, encrypted by the master key KeyID
+type WrappedKey struct {
+	KeyID string `bson:"keyId"`
+	Key   []byte `bson:"key"`
+}
+
+type SavePartialResponseRequest struct {
+	FormID      string                 `json:"formId"`
+	ResumeToken string                 `json:"resumeToken,omitempty"`
+	SessionID   string                 `json:"sessionId,omitempty"`
+	Data        map[string]interface{} `json:"data"`
+}
+
+// UpdateResponseRequest changes a stored response; omitted fields are kept.
+// Data replaces all of the answers and is validated like a submission.
+type UpdateResponseRequest struct {
+	Data     *map[string]interface{} `json:"data,omitempty"`
+	Tags     *[]string               `json:"tags,omitempty"`
+	Status   *string                 `json:"status,omitempty"`
+	Assignee *string                 `json:"assignee,omitempty"` // "" unassigns
+}
+
+// AddResponseNoteRequest adds a note to a response
+type AddResponseNoteRequest struct {
+	Text string `json:"text"`
+}
+
+// BulkDeleteResponsesRequest deletes a form's responses by ID, or every
+// response matching a listing filter and search
+type BulkDeleteResponsesRequest struct {
+	IDs    []string       `json:"ids,omitempty"`
+	Filter ResponseFilter `json:"filter,omitempty"`
+	Query  string         `json:"q,omitempty"`
+}
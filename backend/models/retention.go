@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Retention actions
+const (
+	RetentionDelete    = "delete"
+	RetentionAnonymize = "anonymize"
+)
+
+// RetentionPolicy deletes or anonymizes a form's responses once they are
+// older than Days
+type RetentionPolicy struct {
+	Days   int    `json:"days" bson:"days"`
+	Action string `json:"action" bson:"action"` // RetentionDelete or RetentionAnonymize
+}
+
+// RetentionRun is the audit record of one pass of the retention worker
+type RetentionRun struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	StartedAt   time.Time          `json:"startedAt" bson:"startedAt"`
+	CompletedAt time.Time          `json:"completedAt" bson:"completedAt"`
+	// Forms has an entry for every form the run changed or failed on
+	Forms []RetentionResult `json:"forms" bson:"forms"`
+}
+
+// RetentionResult is what a retention run did to one form
+type RetentionResult struct {
+	FormID primitive.ObjectID `json:"formId" bson:"formId"`
+	Action string             `json:"action" bson:"action"`
+	Days   int                `json:"days" bson:"days"`
+	// Cutoff is the submission time before which responses were affected
+	Cutoff     time.Time `json:"cutoff" bson:"cutoff"`
+	Deleted    int64     `json:"deleted" bson:"deleted"`
+	Anonymized int64     `json:"anonymized" bson:"anonymized"`
+	// PartialsDeleted counts unfinished submissions last saved before the cutoff
+	PartialsDeleted int64  `json:"partialsDeleted" bson:"partialsDeleted"`
+	Error           string `json:"error,omitempty" bson:"error,omitempty"`
+}
@@ -0,0 +1,198 @@
+// Package pdf lays out plain text reports as a PDF document. It supports
+// headings, paragraphs and indented lines in the standard Helvetica fonts,
+// wraps long lines and breaks pages automatically. Text outside Latin-1 is
+// replaced with "?", since the standard fonts carry no other glyphs.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Page geometry, in points (A4)
+const (
+	PageWidth  = 595.0
+	PageHeight = 842.0
+	Margin     = 50.0
+)
+
+// Font sizes
+const (
+	TitleSize   = 18.0
+	HeadingSize = 13.0
+	BodySize    = 10.0
+)
+
+// averageGlyphWidth is the average Helvetica character width as a fraction
+// of the font size, used to wrap lines
+const averageGlyphWidth = 0.5
+
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+// Document is a PDF under construction. The zero value is not usable; call
+// New.
+type Document struct {
+	pages [][]byte
+	page  *bytes.Buffer
+	y     float64
+}
+
+// New starts a document with one empty page
+func New() *Document {
+	d := &Document{}
+	d.newPage()
+	return d
+}
+
+// Title writes a large bold line
+func (d *Document) Title(text string) {
+	d.write(text, fontBold, TitleSize, 0)
+	d.Space(6)
+}
+
+// Heading writes a bold line, keeping at least a few lines of room below it
+// on the page
+func (d *Document) Heading(text string) {
+	d.Space(8)
+	if d.y-4*BodySize*1.4 < Margin {
+		d.newPage()
+	}
+	d.write(text, fontBold, HeadingSize, 0)
+	d.Space(2)
+}
+
+// Text writes a paragraph, wrapped to the page width
+func (d *Document) Text(text string) {
+	d.write(text, fontRegular, BodySize, 0)
+}
+
+// Indented writes a paragraph indented by level steps
+func (d *Document) Indented(level int, text string) {
+	d.write(text, fontRegular, BodySize, float64(level)*14)
+}
+
+// Space leaves a vertical gap of points
+func (d *Document) Space(points float64) {
+	d.y -= points
+}
+
+func (d *Document) newPage() {
+	if d.page != nil {
+		d.pages = append(d.pages, d.page.Bytes())
+	}
+	d.page = &bytes.Buffer{}
+	d.y = PageHeight - Margin
+}
+
+func (d *Document) write(text, font string, size, indent float64) {
+	width := PageWidth - 2*Margin - indent
+	maxChars := int(width / (size * averageGlyphWidth))
+	lineHeight := size * 1.4
+	for _, line := range wrap(text, maxChars) {
+		if d.y-lineHeight < Margin {
+			d.newPage()
+		}
+		d.y -= lineHeight
+		fmt.Fprintf(d.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, Margin+indent, d.y, escape(line))
+	}
+}
+
+// wrap breaks text into lines of at most maxChars characters, at spaces
+// where possible
+func wrap(text string, maxChars int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			for len([]rune(word)) > maxChars {
+				if line != "" {
+					lines = append(lines, line)
+					line = ""
+				}
+				r := []rune(word)
+				lines = append(lines, string(r[:maxChars]))
+				word = string(r[maxChars:])
+			}
+			switch {
+			case line == "":
+				line = word
+			case len([]rune(line))+1+len([]rune(word)) <= maxChars:
+				line += " " + word
+			default:
+				lines = append(lines, line)
+				line = word
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// escape encodes text as a PDF string body in Latin-1
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r < 0x20 || (r >= 0x7f && r < 0xa0):
+			b.WriteByte(' ')
+		case r < 0x7f:
+			b.WriteRune(r)
+		case r < 0x100:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// WriteTo writes the finished document to w. The document can't be added
+// to afterwards.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	pages := append(d.pages, d.page.Bytes())
+
+	// Objects: 1 catalog, 2 page tree, 3 and 4 fonts, then a page and its
+	// content stream for each page
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	)
+	for i, content := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+				PageWidth, PageHeight, fontRegular, fontBold, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return out.WriteTo(w)
+}
@@ -0,0 +1,108 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		text     string
+		maxChars int
+		want     []string
+	}{
+		{"", 10, []string{""}},
+		{"short line", 10, []string{"short line"}},
+		{"the quick brown fox", 10, []string{"the quick", "brown fox"}},
+		{"  extra   spaces  ", 20, []string{"extra spaces"}},
+		{"one\ntwo", 10, []string{"one", "two"}},
+		{"a\n\nb", 10, []string{"a", "", "b"}},
+		{"abcdefghijkl", 5, []string{"abcde", "fghij", "kl"}},
+		{"go abcdefghijkl", 5, []string{"go", "abcde", "fghij", "kl"}},
+		{"ééééé é", 5, []string{"ééééé", "é"}},
+	}
+	for _, tt := range tests {
+		if got := wrap(tt.text, tt.maxChars); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("wrap(%q, %d) = %q, want %q", tt.text, tt.maxChars, got, tt.want)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", "plain"},
+		{`(a) \ b`, `\(a\) \\ b`},
+		{"tab\there", "tab here"},
+		{"café", `caf\351`},
+		{"東京", "??"},
+	}
+	for _, tt := range tests {
+		if got := escape(tt.text); got != tt.want {
+			t.Errorf("escape(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+// xrefEntry matches an in-use entry of the cross-reference table
+var xrefEntry = regexp.MustCompile(`(?m)^(\d{10}) 00000 n $`)
+
+func TestWriteTo(t *testing.T) {
+	d := New()
+	d.Title("Survey (summary)")
+	d.Heading("Responses")
+	for i := 0; i < 100; i++ {
+		d.Indented(1, fmt.Sprintf("Answer %d", i))
+	}
+
+	var buf bytes.Buffer
+	n, err := d.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo reported %d bytes, wrote %d", n, buf.Len())
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("missing PDF header or trailer:\n%s", out)
+	}
+	if !strings.Contains(out, `(Survey \(summary\)) Tj`) {
+		t.Error("title not written or not escaped")
+	}
+	if !strings.Contains(out, "/Count 2 >>") {
+		t.Error("100 lines should break onto a second page")
+	}
+
+	// Every object sits at the offset the cross-reference table gives
+	offsets := xrefEntry.FindAllStringSubmatch(out, -1)
+	if len(offsets) != 4+2*2 {
+		t.Fatalf("got %d objects, want 8", len(offsets))
+	}
+	for i, m := range offsets {
+		offset, _ := strconv.Atoi(m[1])
+		if want := fmt.Sprintf("%d 0 obj\n", i+1); !strings.HasPrefix(out[offset:], want) {
+			t.Errorf("object %d is not at offset %d", i+1, offset)
+		}
+	}
+	start := strings.LastIndex(out, "startxref\n") + len("startxref\n")
+	xref, _ := strconv.Atoi(strings.TrimSuffix(out[start:], "\n%%EOF\n"))
+	if !strings.HasPrefix(out[xref:], "xref\n") {
+		t.Errorf("startxref %d does not point at the cross-reference table", xref)
+	}
+
+	// Stream lengths match their contents
+	for _, m := range regexp.MustCompile(`<< /Length (\d+) >>\nstream\n`).FindAllStringSubmatchIndex(out, -1) {
+		length, _ := strconv.Atoi(out[m[2]:m[3]])
+		if !strings.HasPrefix(out[m[1]+length:], "endstream") {
+			t.Errorf("stream at %d is not %d bytes long", m[1], length)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Package search breaks response answers into the terms full-text search
+// matches on, and builds highlighted snippets of the matching answers. Terms
+// are lowercase runs of letters and digits, with no stemming or stop words,
+// the same way Mongo's text index tokenizes with default_language "none".
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"form-builder-backend/envelope"
+	"form-builder-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxTerms caps how many terms of a query are searched for
+const MaxTerms = 10
+
+// Snippet sizes, in bytes
+const (
+	snippetLead   = 60  // text kept before the first match
+	snippetLength = 200 // longest snippet before it is cut off
+)
+
+// Text joins every answer that can be searched: strings, numbers and the
+// options of multi-select answers, in field ID order. Answers to the form's
+// PII fields are left out, so they can't be found or shown in snippets.
+func Text(form *models.Form, data map[string]interface{}) string {
+	var b strings.Builder
+	for _, fieldID := range searchableKeys(form, data) {
+		if text := answerText(data[fieldID]); text != "" {
+			if b.Len() > 0 {
+				b.WriteByte('\n')
+			}
+			b.WriteString(text)
+		}
+	}
+	return b.String()
+}
+
+// Terms splits text into distinct lowercase terms, in order of appearance
+func Terms(text string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, token := range tokenize(text) {
+		term := strings.ToLower(text[token.start:token.end])
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// QueryTerms parses a search query into the terms a response must contain
+// all of, keeping the first MaxTerms
+func QueryTerms(query string) []string {
+	terms := Terms(query)
+	if len(terms) > MaxTerms {
+		terms = terms[:MaxTerms]
+	}
+	return terms
+}
+
+// MongoSearch builds the $search string of a Mongo $text query requiring
+// every term. Quoting each term as a phrase makes Mongo AND them instead of
+// its default OR.
+func MongoSearch(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = strconv.Quote(term)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// Highlight returns a snippet of each answer in data that contains one of
+// terms, with the matching words marked, in field ID order. Like Text, it
+// skips the form's PII fields.
+func Highlight(form *models.Form, data map[string]interface{}, terms []string) []models.Snippet {
+	want := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		want[term] = true
+	}
+
+	var snippets []models.Snippet
+	for _, fieldID := range searchableKeys(form, data) {
+		text := answerText(data[fieldID])
+		var matches []token
+		for _, t := range tokenize(text) {
+			if want[strings.ToLower(text[t.start:t.end])] {
+				matches = append(matches, t)
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		snippets = append(snippets, models.Snippet{
+			FieldID: fieldID,
+			Parts:   snippetParts(text, matches),
+		})
+	}
+	return snippets
+}
+
+// snippetParts cuts a window of text around the first match and splits it
+// into plain and matching parts
+func snippetParts(text string, matches []token) []models.SnippetPart {
+	start := 0
+	if matches[0].start > snippetLead {
+		start = wordStart(text, matches[0].start-snippetLead)
+	}
+	end := len(text)
+	if end-start > snippetLength {
+		end = wordEnd(text, start+snippetLength)
+	}
+
+	var parts []models.SnippetPart
+	if start > 0 {
+		parts = append(parts, models.SnippetPart{Text: "…"})
+	}
+	pos := start
+	for _, m := range matches {
+		if m.end > end {
+			break
+		}
+		if m.start > pos {
+			parts = appendPlain(parts, text[pos:m.start])
+		}
+		parts = append(parts, models.SnippetPart{Text: text[m.start:m.end], Match: true})
+		pos = m.end
+	}
+	if pos < end {
+		parts = appendPlain(parts, text[pos:end])
+	}
+	if end < len(text) {
+		parts = appendPlain(parts, "…")
+	}
+	return parts
+}
+
+func appendPlain(parts []models.SnippetPart, text string) []models.SnippetPart {
+	if n := len(parts); n > 0 && !parts[n-1].Match {
+		parts[n-1].Text += text
+		return parts
+	}
+	return append(parts, models.SnippetPart{Text: text})
+}
+
+// wordStart moves i forward to the start of a word, so a snippet doesn't
+// open mid-word or mid-character
+func wordStart(text string, i int) int {
+	for i < len(text) && (!utf8.RuneStart(text[i]) || isWordAt(text, i-1)) {
+		i++
+	}
+	return i
+}
+
+// wordEnd moves i back to the end of a word
+func wordEnd(text string, i int) int {
+	for i > 0 && (!utf8.RuneStart(text[i]) || isWordAt(text, i)) {
+		i--
+	}
+	if i == 0 {
+		return len(text)
+	}
+	return i
+}
+
+// isWordAt reports whether the rune ending or starting at byte i is part of
+// a word
+func isWordAt(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return false
+	}
+	for !utf8.RuneStart(text[i]) && i > 0 {
+		i--
+	}
+	r, _ := utf8.DecodeRuneInString(text[i:])
+	return isWordRune(r)
+}
+
+type token struct {
+	start, end int // byte offsets
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			tokens = append(tokens, token{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{start, len(text)})
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)
+}
+
+// answerText returns the searchable text of an answer
+func answerText(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		if envelope.IsSealed(val) {
+			// Encrypted answers aren't searchable
+			return ""
+		}
+		return val
+	case float64, float32, int, int32, int64:
+		return fmt.Sprint(val)
+	case []interface{}:
+		return joinAnswers(val)
+	case primitive.A:
+		return joinAnswers(val)
+	case []string:
+		return strings.Join(val, ", ")
+	}
+	return ""
+}
+
+func joinAnswers(values []interface{}) string {
+	texts := make([]string, 0, len(values))
+	for _, v := range values {
+		if text := answerText(v); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, ", ")
+}
+
+// searchableKeys returns the field IDs of data in order, leaving out the
+// form's PII fields
+func searchableKeys(form *models.Form, data map[string]interface{}) []string {
+	pii := make(map[string]bool)
+	if form != nil {
+		for _, field := range form.Fields {
+			if field.PII {
+				pii[field.ID] = true
+			}
+		}
+	}
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		if !pii[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,145 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"form-builder-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"  ,.;  ", nil},
+		{"Hello, world!", []string{"hello", "world"}},
+		{"Invoice #4411 (paid)", []string{"invoice", "4411", "paid"}},
+		{"the cat THE Cat", []string{"the", "cat"}},
+		{"jane.doe@example.com", []string{"jane", "doe", "example", "com"}},
+		{"Straße Café naïve", []string{"straße", "café", "naïve"}},
+		{"東京 tower", []string{"東京", "tower"}},
+		{"étude", []string{"étude"}}, // combining accent stays in the word
+		{"don't", []string{"don", "t"}},
+	}
+	for _, tt := range tests {
+		if got := Terms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTermsCapsTerms(t *testing.T) {
+	query := "a b c d e f g h i j k l"
+	got := QueryTerms(query)
+	if len(got) != MaxTerms {
+		t.Fatalf("len(QueryTerms) = %d, want %d", len(got), MaxTerms)
+	}
+	if got[0] != "a" || got[MaxTerms-1] != "j" {
+		t.Errorf("QueryTerms kept %q, want the first %d terms", got, MaxTerms)
+	}
+}
+
+func TestMongoSearchQuotesEachTerm(t *testing.T) {
+	if got, want := MongoSearch([]string{"invoice", "4411"}), `"invoice" "4411"`; got != want {
+		t.Errorf("MongoSearch = %s, want %s", got, want)
+	}
+}
+
+func TestText(t *testing.T) {
+	data := map[string]interface{}{
+		"b_city":   "Berlin",
+		"a_amount": 12.5,
+		"c_tags":   primitive.A{"red", "", "blue"},
+		"d_secret": "enc:v1:AAAA",
+		"e_agree":  true,
+		"f_email":  "jane@example.com",
+	}
+	form := &models.Form{Fields: []models.FormField{{ID: "b_city"}, {ID: "f_email", PII: true}}}
+	if got, want := Text(form, data), "12.5\nBerlin\nred, blue"; got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	data := map[string]interface{}{
+		"name":  "Jane Doe",
+		"notes": "Paid invoice 4411, then asked about invoice 4412",
+		"city":  "Berlin",
+	}
+	got := Highlight(nil, data, []string{"invoice", "jane"})
+	want := []models.Snippet{
+		{FieldID: "name", Parts: []models.SnippetPart{
+			{Text: "Jane", Match: true},
+			{Text: " Doe"},
+		}},
+		{FieldID: "notes", Parts: []models.SnippetPart{
+			{Text: "Paid "},
+			{Text: "invoice", Match: true},
+			{Text: " 4411, then asked about "},
+			{Text: "invoice", Match: true},
+			{Text: " 4412"},
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Highlight = %+v, want %+v", got, want)
+	}
+}
+
+func TestHighlightSkipsPIIFields(t *testing.T) {
+	data := map[string]interface{}{"name": "Jane Doe", "notes": "Jane called"}
+	form := &models.Form{Fields: []models.FormField{{ID: "name", PII: true}, {ID: "notes"}}}
+	got := Highlight(form, data, []string{"jane"})
+	if len(got) != 1 || got[0].FieldID != "notes" {
+		t.Errorf("Highlight = %+v, want only the notes snippet", got)
+	}
+}
+
+func TestHighlightMatchesWholeWordsOnly(t *testing.T) {
+	data := map[string]interface{}{"notes": "invoices are due"}
+	if got := Highlight(nil, data, []string{"invoice"}); len(got) != 0 {
+		t.Errorf("Highlight = %+v, want no snippets", got)
+	}
+}
+
+func TestHighlightTrimsLongAnswers(t *testing.T) {
+	text := strings.Repeat("lorem ipsum ", 20) + "needle " + strings.Repeat("dolor sit ", 40)
+	snippets := Highlight(nil, map[string]interface{}{"notes": text}, []string{"needle"})
+	if len(snippets) != 1 {
+		t.Fatalf("got %d snippets, want 1", len(snippets))
+	}
+
+	var joined strings.Builder
+	matches := 0
+	for _, part := range snippets[0].Parts {
+		joined.WriteString(part.Text)
+		if part.Match {
+			matches++
+			if part.Text != "needle" {
+				t.Errorf("matched %q, want needle", part.Text)
+			}
+		}
+	}
+	if matches != 1 {
+		t.Errorf("got %d matches, want 1", matches)
+	}
+
+	snippet := joined.String()
+	if !strings.HasPrefix(snippet, "…") || !strings.HasSuffix(snippet, "…") {
+		t.Fatalf("long answer should be cut on both sides, got %q", snippet)
+	}
+	excerpt := strings.TrimSuffix(strings.TrimPrefix(snippet, "…"), "…")
+	if len(excerpt) > snippetLength {
+		t.Errorf("excerpt is %d bytes, want at most %d", len(excerpt), snippetLength)
+	}
+	start := strings.Index(text, excerpt)
+	if start < 0 {
+		t.Fatalf("excerpt %q is not a slice of the answer", excerpt)
+	}
+	if end := start + len(excerpt); isWordAt(text, start-1) && isWordAt(text, start) || isWordAt(text, end-1) && isWordAt(text, end) {
+		t.Errorf("excerpt %q cuts a word", excerpt)
+	}
+}
@@ -3,41 +3,61 @@ package services
 import (
 	"context"
 	"log"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/envelope"
+	"form-builder-backend/models"
+	"form-builder-backend/useragent"
 )
 
 // AnalyticsData represents analytics data for a form
 type AnalyticsData struct {
-	FormID          string                   `json:"formId"`
-	TotalResponses  int64                    `json:"totalResponses"`
-	TodayResponses  int64                    `json:"todayResponses"`
-	WeekResponses   int64                    `json:"weekResponses"`
-	MonthResponses  int64                    `json:"monthResponses"`
-	CompletionRate  float64                  `json:"completionRate"`
-	AverageTime     float64                  `json:"averageTime"`
-	DeviceStats     map[string]int64         `json:"deviceStats"`
-	FieldAnalytics  map[string]FieldStats    `json:"fieldAnalytics"`
-	ResponseTrends  []TrendPoint             `json:"responseTrends"`
-	RecentResponses []ResponseSummary        `json:"recentResponses"`
-	LastUpdated     time.Time                `json:"lastUpdated"`
-	PeakHour        int                      `json:"peakHour"`
-	TopReferrer     string                   `json:"topReferrer"`
+	FormID           string                `json:"formId"`
+	TotalResponses   int64                 `json:"totalResponses"`
+	TodayResponses   int64                 `json:"todayResponses"`
+	WeekResponses    int64                 `json:"weekResponses"`
+	MonthResponses   int64                 `json:"monthResponses"`
+	CompletionRate   float64               `json:"completionRate"`
+	PartialResponses int64                 `json:"partialResponses"`
+	AverageTime      float64               `json:"averageTime"` // median seconds from start to submit
+	Conversion       ConversionStats       `json:"conversion"`
+	DeviceStats      map[string]int64      `json:"deviceStats"`
+	BrowserStats     map[string]int64      `json:"browserStats"`
+	OSStats          map[string]int64      `json:"osStats"`
+	Geography        GeographyStats        `json:"geography"`
+	FieldAnalytics   map[string]FieldStats `json:"fieldAnalytics"`
+	ResponseTrends   []TrendPoint          `json:"responseTrends"`
+	RecentResponses  []ResponseSummary     `json:"recentResponses"`
+	LastUpdated      time.Time             `json:"lastUpdated"`
+	PeakHour         int                   `json:"peakHour"`
+	TopReferrer      string                `json:"topReferrer"`
+	ReferrerStats    map[string]int64      `json:"referrerStats"`
+	Campaigns        CampaignStats         `json:"campaigns"`
+	// Segment and Filter echo the restriction the figures were computed under
+	Segment string                 `json:"segment,omitempty"`
+	Filter  *models.ResponseFilter `json:"filter,omitempty"`
 }
 
 // FieldStats represents statistics for a form field
 type FieldStats struct {
-	FieldID        string                 `json:"fieldId"`
-	FieldLabel     string                 `json:"fieldLabel"`
-	ResponseCount  int64                  `json:"responseCount"`
-	SkipCount      int64                  `json:"skipCount"`
-	TopValues      map[string]int64       `json:"topValues"`
-	AverageValue   float64                `json:"averageValue,omitempty"`
-	Distribution   map[string]interface{} `json:"distribution,omitempty"`
+	FieldID       string           `json:"fieldId"`
+	FieldLabel    string           `json:"fieldLabel"`
+	ResponseCount int64            `json:"responseCount"`
+	SkipCount     int64            `json:"skipCount"`
+	TopValues     map[string]int64 `json:"topValues"`
+	// ValuePercentages is the share of respondents giving each value; for
+	// multi-select fields these can add up to more than 100
+	ValuePercentages map[string]float64     `json:"valuePercentages,omitempty"`
+	AverageValue     float64                `json:"averageValue,omitempty"`
+	Distribution     map[string]interface{} `json:"distribution,omitempty"`
+	Numeric          *NumericStats          `json:"numeric,omitempty"`
+	Text             *TextStats             `json:"text,omitempty"`
 }
 
 // TrendPoint represents a point in the response trend
@@ -47,6 +67,17 @@ type TrendPoint struct {
 	Label string    `json:"label"`
 }
 
+// ConversionStats follows renderer sessions through view, start and submit
+type ConversionStats struct {
+	Views                int64   `json:"views"`
+	Starts               int64   `json:"starts"`
+	Submits              int64   `json:"submits"`
+	ViewToStart          float64 `json:"viewToStart"`
+	StartToSubmit        float64 `json:"startToSubmit"`
+	ViewToSubmit         float64 `json:"viewToSubmit"`
+	MedianTimeToComplete float64 `json:"medianTimeToComplete"` // seconds
+}
+
 // ResponseSummary represents a summary of a response
 type ResponseSummary struct {
 	ID           string                 `json:"id"`
@@ -66,146 +97,385 @@ func NewAnalyticsService(db *mongo.Database) *AnalyticsService {
 	return &AnalyticsService{db: db}
 }
 
+// EnsureIndexes creates the indexes the analytics pipelines and response
+// listing rely on
+func (s *AnalyticsService) EnsureIndexes(ctx context.Context) error {
+	indexes := map[string][]mongo.IndexModel{
+		"responses": {
+			{Keys: bson.D{{Key: "formId", Value: 1}, {Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+			{Keys: bson.D{{Key: "formId", Value: 1}, {Key: "device", Value: 1}}},
+			{Keys: bson.D{{Key: "formId", Value: 1}, {Key: "tags", Value: 1}}},
+			{Keys: bson.D{{Key: "formId", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "formId", Value: 1}, {Key: "assignee", Value: 1}}},
+			// Full-text search within a form; "none" keeps every word
+			// unstemmed, as the memory store's index does
+			{
+				Keys:    bson.D{{Key: "formId", Value: 1}, {Key: "searchText", Value: "text"}},
+				Options: options.Index().SetDefaultLanguage("none").SetName("formId_searchText_text"),
+			},
+		},
+		"partial_responses": {
+			{Keys: bson.D{{Key: "formId", Value: 1}, {Key: "completedAt", Value: 1}}},
+		},
+		"form_events": {
+			{Keys: bson.D{{Key: "formId", Value: 1}, {Key: "sessionId", Value: 1}}},
+		},
+		"analytics_rollups": {
+			{Keys: bson.D{{Key: "formId", Value: 1}, {Key: "granularity", Value: 1}, {Key: "bucket", Value: 1}}},
+		},
+	}
+
+	for collection, models := range indexes {
+		if _, err := s.db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AnalyticsOptions customizes how GetFormAnalytics computes its figures
+type AnalyticsOptions struct {
+	// Location days and hours are bucketed in; defaults to the form's
+	// timezone, then UTC
+	Location *time.Location
+
+	// HistogramBins is the number of bins for numeric field histograms;
+	// defaults to DefaultHistogramBins
+	HistogramBins int
+
+	// Segment names a saved segment of the form to restrict the figures to
+	Segment string
+
+	// Filter restricts the figures to matching responses, on top of Segment
+	Filter models.ResponseFilter
+}
+
 // GetFormAnalytics retrieves analytics data for a form
-func (s *AnalyticsService) GetFormAnalytics(formID string) (*AnalyticsData, error) {
+func (s *AnalyticsService) GetFormAnalytics(formID string, opts AnalyticsOptions) (*AnalyticsData, error) {
 	objID, err := primitive.ObjectIDFromHex(formID)
 	if err != nil {
 		return nil, err
 	}
 
 	ctx := context.Background()
-	responsesCollection := s.db.Collection("responses")
-	
-	// Get total responses
-	totalResponses, err := responsesCollection.CountDocuments(ctx, bson.M{"formId": objID})
-	if err != nil {
-		log.Printf("Error counting total responses: %v", err)
-		return nil, err
+	now := time.Now()
+
+	// Get the form for its fields and reporting timezone
+	var form models.Form
+	if err := s.db.Collection("forms").FindOne(ctx, bson.M{"_id": objID, "deletedAt": nil}).Decode(&form); err != nil {
+		log.Printf("Error getting form for analytics: %v", err)
 	}
 
-	// Get today's responses
-	todayStart := time.Now().Truncate(24 * time.Hour)
-	todayResponses, err := responsesCollection.CountDocuments(ctx, bson.M{
-		"formId":    objID,
-		"createdAt": bson.M{"$gte": todayStart},
-	})
-	if err != nil {
-		log.Printf("Error counting today's responses: %v", err)
+	loc := opts.Location
+	if loc == nil {
+		if loc, err = LoadLocation(form.Timezone); err != nil {
+			log.Printf("Invalid timezone %q on form %s: %v", form.Timezone, formID, err)
+			loc = time.UTC
+		}
 	}
 
-	// Get week's responses
-	weekStart := time.Now().AddDate(0, 0, -7)
-	weekResponses, err := responsesCollection.CountDocuments(ctx, bson.M{
-		"formId":    objID,
-		"createdAt": bson.M{"$gte": weekStart},
-	})
+	filter, err := resolveFilter(&form, opts, loc)
 	if err != nil {
-		log.Printf("Error counting week's responses: %v", err)
+		return nil, err
 	}
 
-	// Get month's responses
-	monthStart := time.Now().AddDate(0, -1, 0)
-	monthResponses, err := responsesCollection.CountDocuments(ctx, bson.M{
-		"formId":    objID,
-		"createdAt": bson.M{"$gte": monthStart},
-	})
+	// Read the pre-aggregated rollups. Forms whose rollups were never built
+	// fall back to aggregating their raw responses in a single pass, as do
+	// segments, which the rollups can't be broken down by.
+	var summary *rollupSummary
+	useRollups := filter == nil
+	if useRollups {
+		summary, err = s.readRollups(ctx, objID)
+		if err != nil {
+			log.Printf("Error reading analytics rollups: %v", err)
+			return nil, err
+		}
+	}
+	if filter != nil {
+		facets, err := s.aggregateResponses(ctx, objID, piiFieldIDs(&form), filter)
+		if err != nil {
+			log.Printf("Error aggregating responses: %v", err)
+			return nil, err
+		}
+		summary = facets.summary()
+	} else if summary.total == 0 {
+		rawCount, err := s.db.Collection("responses").CountDocuments(ctx, humanResponses(objID))
+		if err != nil {
+			log.Printf("Error counting total responses: %v", err)
+			return nil, err
+		}
+		if rawCount > 0 {
+			log.Printf("No analytics rollups for form %s, aggregating raw responses; run cmd/rebuild-rollups to backfill", formID)
+			facets, err := s.aggregateResponses(ctx, objID, piiFieldIDs(&form), nil)
+			if err != nil {
+				log.Printf("Error aggregating responses: %v", err)
+				return nil, err
+			}
+			summary = facets.summary()
+			useRollups = false
+		}
+	}
+
+	totalResponses := summary.total
+
+	// Get today's, week's and month's responses in the requested timezone.
+	// The rolling windows start on the local hour, which lines up with the
+	// rollups wherever the zone's offset is whole hours.
+	todayStart := startOfDay(now, loc)
+	weekStart := alignInterval(now.In(loc).AddDate(0, 0, -7), IntervalHour, loc)
+	monthStart := alignInterval(now.In(loc).AddDate(0, -1, 0), IntervalHour, loc)
+	periodEnd := alignInterval(now, IntervalHour, loc).Add(time.Hour)
+	periodCounts, err := s.countBuckets(ctx, objID, filter, []time.Time{monthStart, weekStart, todayStart, periodEnd}, loc, useRollups)
 	if err != nil {
-		log.Printf("Error counting month's responses: %v", err)
+		log.Printf("Error counting recent responses: %v", err)
+		periodCounts = make([]int64, 3)
 	}
 
 	// Get response trends (last 7 days, hourly for today, daily for the rest)
-	trends := s.getResponseTrends(ctx, responsesCollection, objID)
+	trendBoundaries := responseTrendBoundaries(now, loc)
+	trendCounts, err := s.countBuckets(ctx, objID, filter, trendBoundaries, loc, useRollups)
+	if err != nil {
+		log.Printf("Error getting response trends: %v", err)
+		trendCounts = make([]int64, len(trendBoundaries)-1)
+	}
+	trends := buildResponseTrends(trendBoundaries, trendCounts, loc)
 
 	// Get recent responses
-	recentResponses := s.getRecentResponses(ctx, responsesCollection, objID, 10)
+	recentResponses := s.getRecentResponses(ctx, &form, filter, analyticsRecentLimit)
 
 	// Get device statistics
-	deviceStats := s.getDeviceStats(ctx, responsesCollection, objID)
+	deviceStats := map[string]int64{
+		"Desktop": 0,
+		"Mobile":  0,
+		"Tablet":  0,
+		"Other":   0,
+	}
+	for device, n := range summary.devices {
+		deviceStats[device] += n
+	}
+	browserStats := make(map[string]int64)
+	for browser, n := range summary.browsers {
+		browserStats[browser] += n
+	}
+	osStats := make(map[string]int64)
+	for os, n := range summary.oses {
+		osStats[os] += n
+	}
 
 	// Get field analytics
-	fieldAnalytics := s.getFieldAnalytics(ctx, responsesCollection, objID)
-
-	// Calculate completion rate (simplified - you might want to track incomplete submissions)
-	completionRate := 100.0 // Assuming all stored responses are complete
+	fieldAnalytics := buildFieldAnalytics(form, totalResponses, summary.fields, opts.HistogramBins)
+
+	// Calculate completion rate from submissions that were started but never
+	// finished. Unfinished sessions have no device, tags or submitted answers
+	// to filter by, so conversion always covers the whole form.
+	partialResponses, err := s.db.Collection("partial_responses").CountDocuments(ctx, bson.M{
+		"formId":      objID,
+		"completedAt": bson.M{"$exists": false},
+	})
+	if err != nil {
+		log.Printf("Error counting partial responses: %v", err)
+	}
+	completionRate := percentage(totalResponses, totalResponses+partialResponses)
 
-	// Calculate average time (simplified - would need to track session time)
-	averageTime := 120.0 // Default 2 minutes
+	// Prefer the renderer's session events for completion rate and timing
+	conversion := s.getConversion(ctx, objID)
+	if conversion.Starts > 0 {
+		completionRate = conversion.StartToSubmit
+	}
+	averageTime := conversion.MedianTimeToComplete
 
 	// Get peak hour
-	peakHour := s.getPeakHour(ctx, responsesCollection, objID)
+	peakHour := 14 // Default to 2 PM
+	if hour, ok, err := s.getPeakHour(ctx, objID, filter, loc, now, useRollups); err != nil {
+		log.Printf("Error getting peak hour: %v", err)
+	} else if ok {
+		peakHour = hour
+	}
 
 	analytics := &AnalyticsData{
-		FormID:          formID,
-		TotalResponses:  totalResponses,
-		TodayResponses:  todayResponses,
-		WeekResponses:   weekResponses,
-		MonthResponses:  monthResponses,
-		CompletionRate:  completionRate,
-		AverageTime:     averageTime,
-		DeviceStats:     deviceStats,
-		FieldAnalytics:  fieldAnalytics,
-		ResponseTrends:  trends,
-		RecentResponses: recentResponses,
-		LastUpdated:     time.Now(),
-		PeakHour:        peakHour,
-		TopReferrer:     "Direct",
+		FormID:           formID,
+		TotalResponses:   totalResponses,
+		TodayResponses:   periodCounts[2],
+		WeekResponses:    periodCounts[1] + periodCounts[2],
+		MonthResponses:   periodCounts[0] + periodCounts[1] + periodCounts[2],
+		CompletionRate:   completionRate,
+		PartialResponses: partialResponses,
+		AverageTime:      averageTime,
+		Conversion:       conversion,
+		DeviceStats:      deviceStats,
+		BrowserStats:     browserStats,
+		OSStats:          osStats,
+		Geography:        summary.geo,
+		FieldAnalytics:   fieldAnalytics,
+		ResponseTrends:   trends,
+		RecentResponses:  recentResponses,
+		LastUpdated:      now,
+		PeakHour:         peakHour,
+		TopReferrer:      topReferrer(summary.referrers),
+		ReferrerStats:    topValues(summary.referrers, maxTopValues),
+		Campaigns:        summary.campaigns.top(),
+		Segment:          opts.Segment,
+	}
+	if !opts.Filter.IsEmpty() {
+		analytics.Filter = &opts.Filter
 	}
 
 	return analytics, nil
 }
 
-// getResponseTrends gets the response trends for the last 7 days
-func (s *AnalyticsService) getResponseTrends(ctx context.Context, collection *mongo.Collection, formID primitive.ObjectID) []TrendPoint {
-	trends := []TrendPoint{}
-	now := time.Now()
+// analyticsRecentLimit is the number of responses in RecentResponses
+const analyticsRecentLimit = 10
 
-	// For today, get hourly trends
-	todayStart := now.Truncate(24 * time.Hour)
-	for hour := 0; hour <= now.Hour(); hour++ {
-		hourStart := todayStart.Add(time.Duration(hour) * time.Hour)
-		hourEnd := hourStart.Add(time.Hour)
-		
-		count, _ := collection.CountDocuments(ctx, bson.M{
-			"formId":    formID,
-			"createdAt": bson.M{
-				"$gte": hourStart,
-				"$lt":  hourEnd,
-			},
-		})
-		
-		trends = append(trends, TrendPoint{
-			Time:  hourStart,
-			Count: count,
-			Label: hourStart.Format("3:04 PM"),
-		})
-	}
-
-	// For the last 6 days, get daily trends
-	for days := 1; days < 7; days++ {
-		dayStart := now.AddDate(0, 0, -days).Truncate(24 * time.Hour)
-		dayEnd := dayStart.Add(24 * time.Hour)
-		
-		count, _ := collection.CountDocuments(ctx, bson.M{
-			"formId":    formID,
-			"createdAt": bson.M{
-				"$gte": dayStart,
-				"$lt":  dayEnd,
+// humanResponses matches a form's responses that weren't submitted by a bot
+func humanResponses(formID primitive.ObjectID) bson.M {
+	return bson.M{"formId": formID, "bot": bson.M{"$ne": true}}
+}
+
+// bucketCount is a response count for a truncated time bucket
+type bucketCount struct {
+	Time  time.Time `bson:"_id"`
+	Count int64     `bson:"count"`
+}
+
+// responseFacets is the result of the aggregateResponses $facet pipeline
+type responseFacets struct {
+	Counts []struct {
+		Total int64 `bson:"total"`
+	} `bson:"counts"`
+	UserAgents []struct {
+		UserAgent string `bson:"_id"`
+		Count     int64  `bson:"count"`
+	} `bson:"userAgents"`
+	Locations []struct {
+		Key struct {
+			Country string `bson:"country"`
+			Region  string `bson:"region"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	} `bson:"locations"`
+	Traffic []struct {
+		Key struct {
+			Referrer string            `bson:"referrer"`
+			UTM      *models.UTMParams `bson:"utm"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	} `bson:"traffic"`
+	FieldCounts []struct {
+		FieldID string `bson:"_id"`
+		Count   int64  `bson:"count"`
+	} `bson:"fieldCounts"`
+	FieldValues []struct {
+		FieldID string `bson:"_id"`
+		Values  []struct {
+			Value interface{} `bson:"v"`
+			Count int64       `bson:"count"`
+		} `bson:"values"`
+	} `bson:"fieldValues"`
+}
+
+// maxFacetGroups caps the groups each $facet branch returns. All branches
+// share one output document, which Mongo limits to 16 MB, so the long tail
+// of user agents, referrers and locations is left out.
+const maxFacetGroups = 1000
+
+// aggregateResponses runs one $facet pipeline over a form's responses. The
+// leading $match is served by the {formId, createdAt} index, so each response
+// is read once no matter how many sections are computed from it. The answers
+// to the pii fields are only counted, as in the rollups.
+func (s *AnalyticsService) aggregateResponses(ctx context.Context, formID primitive.ObjectID, pii []string, filter bson.M) (*responseFacets, error) {
+	mostCommon := bson.A{
+		bson.M{"$sort": bson.M{"count": -1}},
+		bson.M{"$limit": maxFacetGroups},
+	}
+	answers := bson.A{
+		bson.M{"$project": bson.M{"answers": bson.M{"$objectToArray": "$data"}}},
+		bson.M{"$unwind": "$answers"},
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: withFilter(humanResponses(formID), filter)}},
+		{{Key: "$facet", Value: bson.M{
+			"counts": bson.A{
+				bson.M{"$group": bson.M{"_id": nil, "total": bson.M{"$sum": 1}}},
 			},
-		})
-		
-		trends = append([]TrendPoint{{
-			Time:  dayStart,
-			Count: count,
-			Label: dayStart.Format("Jan 2"),
-		}}, trends...)
+			"userAgents": append(bson.A{
+				bson.M{"$group": bson.M{"_id": "$userAgent", "count": bson.M{"$sum": 1}}},
+			}, mostCommon...),
+			"traffic": append(bson.A{
+				bson.M{"$group": bson.M{
+					"_id": bson.M{
+						"referrer": "$referrerDomain",
+						"utm": bson.M{
+							"source":   "$utm.source",
+							"medium":   "$utm.medium",
+							"campaign": "$utm.campaign",
+						},
+					},
+					"count": bson.M{"$sum": 1},
+				}},
+			}, mostCommon...),
+			"locations": append(bson.A{
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"country": "$country", "region": "$region"},
+					"count": bson.M{"$sum": 1},
+				}},
+			}, mostCommon...),
+			"fieldCounts": append(append(bson.A{}, answers...),
+				bson.M{"$match": bson.M{"answers.v": bson.M{"$nin": bson.A{nil, "", bson.A{}}}}},
+				bson.M{"$group": bson.M{"_id": "$answers.k", "count": bson.M{"$sum": 1}}},
+			),
+			// Each selected option of a multi-select answer is counted once,
+			// and each field keeps its maxRollupKeys most common values, as
+			// the rollups do
+			"fieldValues": append(append(bson.A{}, answers...),
+				bson.M{"$set": bson.M{"answers.v": bson.M{"$cond": bson.A{
+					bson.M{"$isArray": "$answers.v"}, bson.M{"$setUnion": bson.A{"$answers.v"}}, "$answers.v",
+				}}}},
+				bson.M{"$unwind": "$answers.v"},
+				bson.M{"$match": bson.M{
+					"answers.k": bson.M{"$nin": pii},
+					"answers.v": bson.M{
+						"$type": bson.A{"string", "bool", "number"},
+						"$ne":   "",
+						// Encrypted answers are all distinct and say nothing
+						"$not": primitive.Regex{Pattern: envelope.SealedPattern},
+					},
+				}},
+				bson.M{"$group": bson.M{
+					"_id":   bson.M{"k": "$answers.k", "v": "$answers.v"},
+					"count": bson.M{"$sum": 1},
+				}},
+				bson.M{"$group": bson.M{
+					"_id": "$_id.k",
+					"values": bson.M{"$topN": bson.M{
+						"n":      maxRollupKeys,
+						"sortBy": bson.M{"count": -1},
+						"output": bson.M{"v": "$_id.v", "count": "$count"},
+					}},
+				}},
+			),
+		}}},
+	}
+
+	cursor, err := s.db.Collection("responses").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
 	}
+	defer cursor.Close(ctx)
 
-	return trends
+	facets := &responseFacets{}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(facets); err != nil {
+			return nil, err
+		}
+	}
+	return facets, cursor.Err()
 }
 
-// getRecentResponses gets the most recent responses
-func (s *AnalyticsService) getRecentResponses(ctx context.Context, collection *mongo.Collection, formID primitive.ObjectID, limit int) []ResponseSummary {
-	cursor, err := collection.Find(ctx, bson.M{"formId": formID},
+// getRecentResponses gets the most recent responses, with the answers to PII
+// fields masked since they are broadcast to every dashboard
+func (s *AnalyticsService) getRecentResponses(ctx context.Context, form *models.Form, filter bson.M, limit int) []ResponseSummary {
+	cursor, err := s.db.Collection("responses").Find(ctx, withFilter(humanResponses(form.ID), filter),
 		options.Find().
 			SetSort(bson.D{{Key: "createdAt", Value: -1}}).
 			SetLimit(int64(limit)))
@@ -223,14 +493,10 @@ func (s *AnalyticsService) getRecentResponses(ctx context.Context, collection *m
 		}
 
 		// Parse device from user agent
-		device := "Desktop"
-		if userAgent, ok := doc["userAgent"].(string); ok {
-			if containsAny(userAgent, []string{"Mobile", "Android", "iPhone"}) {
-				device = "Mobile"
-			} else if containsAny(userAgent, []string{"Tablet", "iPad"}) {
-				device = "Tablet"
-			}
-		}
+		userAgent, _ := doc["userAgent"].(string)
+		device := useragent.Parse(userAgent).Device
+		country, _ := doc["country"].(string)
+		region, _ := doc["region"].(string)
 
 		responseData := make(map[string]interface{})
 		if data, ok := doc["data"].(bson.M); ok {
@@ -243,8 +509,8 @@ func (s *AnalyticsService) getRecentResponses(ctx context.Context, collection *m
 			ID:           doc["_id"].(primitive.ObjectID).Hex(),
 			SubmittedAt:  doc["createdAt"].(primitive.DateTime).Time(),
 			Device:       device,
-			Location:     "Unknown", // You might want to implement IP geolocation
-			ResponseData: responseData,
+			Location:     FormatLocation(country, region),
+			ResponseData: MaskSensitive(form, responseData),
 		}
 		responses = append(responses, summary)
 	}
@@ -252,177 +518,252 @@ func (s *AnalyticsService) getRecentResponses(ctx context.Context, collection *m
 	return responses
 }
 
-// getDeviceStats gets device statistics
-func (s *AnalyticsService) getDeviceStats(ctx context.Context, collection *mongo.Collection, formID primitive.ObjectID) map[string]int64 {
-	stats := map[string]int64{
-		"Desktop": 0,
-		"Mobile":  0,
-		"Tablet":  0,
-		"Other":   0,
-	}
+// summary converts the raw $facet results into the same shape readRollups returns
+func (f *responseFacets) summary() *rollupSummary {
+	summary := newRollupSummary()
 
-	cursor, err := collection.Find(ctx, bson.M{"formId": formID})
-	if err != nil {
-		log.Printf("Error getting device stats: %v", err)
-		return stats
+	if len(f.Counts) > 0 {
+		summary.total = f.Counts[0].Total
 	}
-	defer cursor.Close(ctx)
 
-	for cursor.Next(ctx) {
-		var doc bson.M
-		if err := cursor.Decode(&doc); err != nil {
+	for _, ua := range f.UserAgents {
+		info := useragent.Parse(ua.UserAgent)
+		if info.Bot {
 			continue
 		}
+		summary.devices[info.Device] += ua.Count
+		summary.browsers[info.Browser] += ua.Count
+		summary.oses[info.OS] += ua.Count
+	}
+
+	for _, t := range f.Traffic {
+		source, medium, campaign := campaignKeys(t.Key.UTM)
+		summary.referrers[referrerOrDirect(t.Key.Referrer)] += t.Count
+		summary.campaigns.Sources[source] += t.Count
+		summary.campaigns.Mediums[medium] += t.Count
+		summary.campaigns.Campaigns[campaign] += t.Count
+	}
 
-		device := "Other"
-		if userAgent, ok := doc["userAgent"].(string); ok {
-			if containsAny(userAgent, []string{"Mobile", "Android", "iPhone"}) {
-				device = "Mobile"
-			} else if containsAny(userAgent, []string{"Tablet", "iPad"}) {
-				device = "Tablet"
-			} else if userAgent != "" {
-				device = "Desktop"
+	for _, l := range f.Locations {
+		summary.geo.Countries[countryOrUnknown(l.Key.Country)] += l.Count
+		if l.Key.Region != "" {
+			summary.geo.Regions[regionKey(l.Key.Country, l.Key.Region)] += l.Count
+		}
+	}
+
+	for _, c := range f.FieldCounts {
+		field := summary.fields[c.FieldID]
+		field.Answered = c.Count
+		summary.fields[c.FieldID] = field
+	}
+	for _, f := range f.FieldValues {
+		field := summary.fields[f.FieldID]
+		for _, v := range f.Values {
+			valueStr := toString(v.Value)
+			if valueStr == "" {
+				continue
+			}
+			if field.Values == nil {
+				field.Values = make(map[string]int64)
 			}
+			field.Values[valueStr] += v.Count
 		}
-		stats[device]++
+		summary.fields[f.FieldID] = field
 	}
 
-	return stats
+	return summary
 }
 
-// getFieldAnalytics gets analytics for each field
-func (s *AnalyticsService) getFieldAnalytics(ctx context.Context, collection *mongo.Collection, formID primitive.ObjectID) map[string]FieldStats {
+// buildFieldAnalytics builds the per-field statistics from the summed answer counts
+func buildFieldAnalytics(form models.Form, totalResponses int64, fields map[string]rollupField, histogramBins int) map[string]FieldStats {
 	fieldStats := make(map[string]FieldStats)
 
-	// Get the form to get field information
-	formsCollection := s.db.Collection("forms")
-	var form bson.M
-	err := formsCollection.FindOne(ctx, bson.M{"_id": formID}).Decode(&form)
-	if err != nil {
-		log.Printf("Error getting form for field analytics: %v", err)
-		return fieldStats
-	}
-
-	fields, ok := form["fields"].(primitive.A)
-	if !ok {
-		return fieldStats
-	}
-
-	// Initialize field stats
-	for _, f := range fields {
-		field, ok := f.(bson.M)
-		if !ok {
-			continue
-		}
-		
-		fieldID, _ := field["id"].(string)
-		fieldLabel, _ := field["label"].(string)
-		
-		fieldStats[fieldID] = FieldStats{
-			FieldID:       fieldID,
-			FieldLabel:    fieldLabel,
-			ResponseCount: 0,
-			SkipCount:     0,
+	for _, field := range form.Fields {
+		counts := fields[field.ID]
+		stats := FieldStats{
+			FieldID:       field.ID,
+			FieldLabel:    field.Label,
+			ResponseCount: counts.Answered,
+			SkipCount:     totalResponses - counts.Answered,
 			TopValues:     make(map[string]int64),
 		}
-	}
-
-	// Analyze responses
-	cursor, err := collection.Find(ctx, bson.M{"formId": formID})
-	if err != nil {
-		log.Printf("Error analyzing field responses: %v", err)
-		return fieldStats
-	}
-	defer cursor.Close(ctx)
 
-	totalResponses := int64(0)
-	for cursor.Next(ctx) {
-		var doc bson.M
-		if err := cursor.Decode(&doc); err != nil {
+		// Free text is summarized by words, phrases and sentiment. Raw
+		// aggregation still yields verbatim answers, so analyze those here.
+		if isTextField(field.Type) {
+			if counts.Words == nil {
+				for value, n := range counts.Values {
+					counts.addText(analyzeText(value), n)
+				}
+			}
+			stats.Text = buildTextStats(counts)
+			fieldStats[field.ID] = stats
 			continue
 		}
-		totalResponses++
 
-		data, ok := doc["data"].(bson.M)
-		if !ok {
-			continue
+		// Track the most common values (for select, radio, checkbox fields)
+		stats.TopValues = topValues(counts.Values, maxTopValues)
+		if len(stats.TopValues) > 0 {
+			stats.ValuePercentages = make(map[string]float64, len(stats.TopValues))
+			for value, n := range stats.TopValues {
+				stats.ValuePercentages[value] = percentage(n, counts.Answered)
+			}
 		}
 
-		// Update field stats
-		for fieldID, stats := range fieldStats {
-			if value, exists := data[fieldID]; exists && value != nil && value != "" {
-				stats.ResponseCount++
-				
-				// Track top values (for select, radio, checkbox fields)
-				valueStr := toString(value)
-				if valueStr != "" {
-					if stats.TopValues[valueStr] == 0 {
-						stats.TopValues[valueStr] = 0
-					}
-					stats.TopValues[valueStr]++
+		if isNumericField(field.Type) {
+			if numeric := computeNumericStats(counts.Values, histogramBins); numeric != nil {
+				stats.Numeric = numeric
+				stats.AverageValue = numeric.Mean
+				stats.Distribution = make(map[string]interface{}, len(numeric.Histogram))
+				for _, bin := range numeric.Histogram {
+					stats.Distribution[bin.Label] = bin.Count
 				}
-			} else {
-				stats.SkipCount++
 			}
-			fieldStats[fieldID] = stats
 		}
+		fieldStats[field.ID] = stats
 	}
 
 	return fieldStats
 }
 
-// getPeakHour gets the hour with most responses
-func (s *AnalyticsService) getPeakHour(ctx context.Context, collection *mongo.Collection, formID primitive.ObjectID) int {
+// getConversion computes the view → start → submit conversion of renderer sessions
+func (s *AnalyticsService) getConversion(ctx context.Context, formID primitive.ObjectID) ConversionStats {
+	stats := ConversionStats{}
+
 	pipeline := mongo.Pipeline{
 		{{Key: "$match", Value: bson.M{"formId": formID}}},
+		// Any event means the form was viewed; interacting with it means it was started
 		{{Key: "$group", Value: bson.M{
-			"_id":   bson.M{"$hour": "$createdAt"},
-			"count": bson.M{"$sum": 1},
+			"_id":    "$sessionId",
+			"viewed": bson.M{"$max": 1},
+			"started": bson.M{"$max": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{"$type", bson.A{"start", "field_focus", "submit"}}}, 1, 0,
+			}}},
+			"submitted": bson.M{"$max": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$type", "submit"}}, 1, 0,
+			}}},
+			"startedAt": bson.M{"$min": bson.M{"$cond": bson.A{
+				bson.M{"$in": bson.A{"$type", bson.A{"start", "field_focus"}}}, "$createdAt", nil,
+			}}},
+			"submittedAt": bson.M{"$min": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$type", "submit"}}, "$createdAt", nil,
+			}}},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"viewed":    1,
+			"started":   1,
+			"submitted": 1,
+			"duration": bson.M{"$cond": bson.A{
+				bson.M{"$and": bson.A{
+					bson.M{"$ne": bson.A{"$startedAt", nil}},
+					bson.M{"$ne": bson.A{"$submittedAt", nil}},
+					bson.M{"$gte": bson.A{"$submittedAt", "$startedAt"}},
+				}},
+				bson.M{"$subtract": bson.A{"$submittedAt", "$startedAt"}},
+				nil,
+			}},
+		}}},
+		// The median is taken in the group rather than by pushing every
+		// duration into one document, which could pass the 16 MB limit.
+		// Sessions without a duration are null and ignored.
+		{{Key: "$group", Value: bson.M{
+			"_id":            nil,
+			"views":          bson.M{"$sum": "$viewed"},
+			"starts":         bson.M{"$sum": "$started"},
+			"submits":        bson.M{"$sum": "$submitted"},
+			"medianDuration": bson.M{"$median": bson.M{"input": "$duration", "method": "approximate"}},
 		}}},
-		{{Key: "$sort", Value: bson.M{"count": -1}}},
-		{{Key: "$limit", Value: 1}},
 	}
 
-	cursor, err := collection.Aggregate(ctx, pipeline)
+	cursor, err := s.db.Collection("form_events").Aggregate(ctx, pipeline)
 	if err != nil {
-		log.Printf("Error getting peak hour: %v", err)
-		return 14 // Default to 2 PM
+		log.Printf("Error getting conversion stats: %v", err)
+		return stats
 	}
 	defer cursor.Close(ctx)
 
-	var result bson.M
-	if cursor.Next(ctx) {
-		if err := cursor.Decode(&result); err == nil {
-			if hour, ok := result["_id"].(int32); ok {
-				return int(hour)
-			}
-		}
+	var result struct {
+		Views          int64   `bson:"views"`
+		Starts         int64   `bson:"starts"`
+		Submits        int64   `bson:"submits"`
+		MedianDuration float64 `bson:"medianDuration"` // milliseconds
 	}
+	if !cursor.Next(ctx) {
+		return stats
+	}
+	if err := cursor.Decode(&result); err != nil {
+		log.Printf("Error decoding conversion stats: %v", err)
+		return stats
+	}
+
+	stats.Views = result.Views
+	stats.Starts = result.Starts
+	stats.Submits = result.Submits
+	stats.ViewToStart = percentage(result.Starts, result.Views)
+	stats.StartToSubmit = percentage(result.Submits, result.Starts)
+	stats.ViewToSubmit = percentage(result.Submits, result.Views)
+
+	stats.MedianTimeToComplete = result.MedianDuration / 1000
 
-	return 14 // Default to 2 PM
+	return stats
 }
 
 // Helper functions
-func containsAny(str string, substrings []string) bool {
-	for _, substr := range substrings {
-		if contains(str, substr) {
-			return true
-		}
+
+// percentage returns part as a percentage of whole, or 0 when whole is empty
+func percentage(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
 	}
-	return false
+	return float64(part) / float64(whole) * 100
 }
 
-func contains(str, substr string) bool {
-	return len(str) >= len(substr) && str[:len(substr)] == substr || 
-		   len(str) >= len(substr) && contains(str[1:], substr)
+// answerValues returns the distinct values counted for an answer: every
+// selected option of a multi-select answer, or the single scalar value
+func answerValues(v interface{}) []string {
+	var items []interface{}
+	switch val := v.(type) {
+	case primitive.A:
+		items = val
+	case []interface{}:
+		items = val
+	case []string:
+		for _, item := range val {
+			items = append(items, item)
+		}
+	default:
+		if valueStr := toString(v); valueStr != "" {
+			return []string{valueStr}
+		}
+		return nil
+	}
+
+	values := make([]string, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		valueStr := toString(item)
+		if valueStr != "" && !seen[valueStr] {
+			seen[valueStr] = true
+			values = append(values, valueStr)
+		}
+	}
+	return values
 }
 
 func toString(v interface{}) string {
 	switch val := v.(type) {
 	case string:
 		return val
-	case int, int32, int64, float32, float64:
-		return ""  // Don't convert numbers to strings for top values
+	case int:
+		return strconv.Itoa(val)
+	case int32:
+		return strconv.FormatInt(int64(val), 10)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case float32:
+		return formatNumber(float64(val))
+	case float64:
+		return formatNumber(val)
 	case bool:
 		if val {
 			return "true"
@@ -432,4 +773,3 @@ func toString(v interface{}) string {
 		return ""
 	}
 }
-
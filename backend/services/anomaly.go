@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/models"
+)
+
+// Alert kinds
+const (
+	AlertSpike = "spike" // far more submissions than usual, often spam
+	AlertDrop  = "drop"  // submissions stopped, often a broken embed
+)
+
+// Alert reports a form whose recent submission volume is out of line with
+// the same time of day on previous days
+type Alert struct {
+	FormID       string    `json:"formId"`
+	FormTitle    string    `json:"formTitle"`
+	Kind         string    `json:"kind"`
+	Message      string    `json:"message"`
+	WindowStart  time.Time `json:"windowStart"`
+	WindowEnd    time.Time `json:"windowEnd"`
+	Observed     int64     `json:"observed"`
+	Expected     float64   `json:"expected"` // baseline mean for the window
+	BaselineDays int       `json:"baselineDays"`
+	DetectedAt   time.Time `json:"detectedAt"`
+}
+
+// AnomalyConfig tunes the submission volume checks
+type AnomalyConfig struct {
+	// Interval between checks
+	Interval time.Duration
+	// Window is the number of whole hours of recent submissions compared
+	Window int
+	// BaselineDays is how many previous days supply the baseline; each
+	// contributes the same hours of the day as the window
+	BaselineDays int
+	// SpikeFactor and SpikeDeviations: a spike is more than SpikeFactor times
+	// the baseline mean and more than SpikeDeviations standard deviations
+	// above it, and at least MinSpike submissions
+	SpikeFactor     float64
+	SpikeDeviations float64
+	MinSpike        int64
+	// DropFactor: a drop is fewer than DropFactor times the baseline mean,
+	// for forms whose baseline mean is at least MinDropBaseline
+	DropFactor      float64
+	MinDropBaseline float64
+	// Cooldown suppresses repeat alerts of the same kind for a form
+	Cooldown time.Duration
+}
+
+// DefaultAnomalyConfig returns the settings used when none are given
+func DefaultAnomalyConfig() AnomalyConfig {
+	return AnomalyConfig{
+		Interval:        15 * time.Minute,
+		Window:          1,
+		BaselineDays:    14,
+		SpikeFactor:     3,
+		SpikeDeviations: 3,
+		MinSpike:        20,
+		DropFactor:      0.1,
+		MinDropBaseline: 5,
+		Cooldown:        6 * time.Hour,
+	}
+}
+
+// AnomalyDetector periodically compares each published form's recent
+// submissions with its hourly rollups and reports anomalies to its handlers
+type AnomalyDetector struct {
+	db       *mongo.Database
+	config   AnomalyConfig
+	handlers []func(Alert)
+
+	mu        sync.Mutex
+	lastAlert map[string]time.Time // keyed by form ID and alert kind
+}
+
+// NewAnomalyDetector creates a detector that calls each handler for every alert
+func NewAnomalyDetector(db *mongo.Database, config AnomalyConfig, handlers ...func(Alert)) *AnomalyDetector {
+	return &AnomalyDetector{
+		db:        db,
+		config:    config,
+		handlers:  handlers,
+		lastAlert: make(map[string]time.Time),
+	}
+}
+
+// Run checks every Interval until ctx is cancelled
+func (d *AnomalyDetector) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			alerts, err := d.Check(ctx, now)
+			if err != nil {
+				log.Printf("Error checking submission anomalies: %v", err)
+			}
+			for _, alert := range alerts {
+				for _, handle := range d.handlers {
+					handle(alert)
+				}
+			}
+		}
+	}
+}
+
+// Check compares the last Window complete hours before now with the same
+// hours on each of the previous BaselineDays days, for every published form
+func (d *AnomalyDetector) Check(ctx context.Context, now time.Time) ([]Alert, error) {
+	cfg := d.config
+	windowEnd := now.UTC().Truncate(time.Hour)
+	window := time.Duration(cfg.Window) * time.Hour
+	windowStart := windowEnd.Add(-window)
+	baselineStart := windowStart.AddDate(0, 0, -cfg.BaselineDays)
+
+	forms, err := d.publishedForms(ctx)
+	if err != nil || len(forms) == 0 {
+		return nil, err
+	}
+
+	formIDs := make([]primitive.ObjectID, 0, len(forms))
+	for id := range forms {
+		formIDs = append(formIDs, id)
+	}
+
+	cursor, err := d.db.Collection("analytics_rollups").Find(ctx,
+		bson.M{
+			"formId":      bson.M{"$in": formIDs},
+			"granularity": rollupHour,
+			"bucket":      bson.M{"$gte": baselineStart, "$lt": windowEnd},
+		},
+		options.Find().SetProjection(bson.M{"formId": 1, "bucket": 1, "count": 1, "imported": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	// counts[form][0] is the recent window; counts[form][i] the window i days earlier
+	counts := make(map[primitive.ObjectID][]int64, len(forms))
+	for id := range forms {
+		counts[id] = make([]int64, cfg.BaselineDays+1)
+	}
+	for cursor.Next(ctx) {
+		var r rollup
+		if err := cursor.Decode(&r); err != nil {
+			continue
+		}
+		daysBack := int(windowEnd.Sub(r.Bucket.Add(time.Hour)).Hours()) / 24
+		start := windowStart.AddDate(0, 0, -daysBack)
+		if r.Bucket.Before(start) || !r.Bucket.Before(start.Add(window)) {
+			continue // outside that day's window
+		}
+		// Imports land all at once, or at their import time when the file
+		// has no timestamps, and would look like a spike
+		if days := counts[r.FormID]; days != nil && daysBack <= cfg.BaselineDays {
+			days[daysBack] += r.Count - r.Imported
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	var alerts []Alert
+	for id, days := range counts {
+		form := forms[id]
+
+		// Only days the form existed for count towards its baseline
+		var baseline []float64
+		for i := 1; i <= cfg.BaselineDays; i++ {
+			if !windowStart.AddDate(0, 0, -i).Before(form.CreatedAt) {
+				baseline = append(baseline, float64(days[i]))
+			}
+		}
+		if len(baseline) == 0 {
+			continue
+		}
+
+		kind := classifyVolume(cfg, days[0], baseline)
+		if kind == "" || !d.shouldAlert(id.Hex()+":"+kind, now) {
+			continue
+		}
+
+		mean, _ := meanStdDev(baseline)
+		alert := Alert{
+			FormID:       id.Hex(),
+			FormTitle:    form.Title,
+			Kind:         kind,
+			WindowStart:  windowStart,
+			WindowEnd:    windowEnd,
+			Observed:     days[0],
+			Expected:     mean,
+			BaselineDays: len(baseline),
+			DetectedAt:   now,
+		}
+		if kind == AlertSpike {
+			alert.Message = "Unusually many submissions, which may be spam"
+		} else {
+			alert.Message = "Submissions have dropped off, check that the form is still reachable"
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, nil
+}
+
+// classifyVolume decides whether observed is a spike or a drop against the
+// baseline samples, returning "" when it is within the usual range
+func classifyVolume(cfg AnomalyConfig, observed int64, baseline []float64) string {
+	mean, stdDev := meanStdDev(baseline)
+	value := float64(observed)
+
+	if observed >= cfg.MinSpike && value > mean*cfg.SpikeFactor && value > mean+cfg.SpikeDeviations*stdDev {
+		return AlertSpike
+	}
+	if mean >= cfg.MinDropBaseline && value < mean*cfg.DropFactor {
+		return AlertDrop
+	}
+	return ""
+}
+
+// shouldAlert records an alert for key unless one was sent within the cooldown
+func (d *AnomalyDetector) shouldAlert(key string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if last, ok := d.lastAlert[key]; ok && now.Sub(last) < d.config.Cooldown {
+		return false
+	}
+	d.lastAlert[key] = now
+	return true
+}
+
+// publishedForms loads the forms accepting submissions, keyed by ID
+func (d *AnomalyDetector) publishedForms(ctx context.Context) (map[primitive.ObjectID]models.Form, error) {
+	cursor, err := d.db.Collection("forms").Find(ctx,
+		bson.M{"status": "published", "isActive": true},
+		options.Find().SetProjection(bson.M{"title": 1, "createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	forms := make(map[primitive.ObjectID]models.Form)
+	for cursor.Next(ctx) {
+		var form models.Form
+		if err := cursor.Decode(&form); err != nil {
+			continue
+		}
+		forms[form.ID] = form
+	}
+	return forms, cursor.Err()
+}
+
+// meanStdDev returns the mean and population standard deviation of values
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
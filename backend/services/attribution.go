@@ -0,0 +1,65 @@
+package services
+
+import "form-builder-backend/models"
+
+// directTraffic is the referrer of responses that arrived without one
+const directTraffic = "Direct"
+
+// notTagged counts responses without a given utm_* parameter
+const notTagged = "(none)"
+
+// CampaignStats breaks responses down by their utm_source, utm_medium and
+// utm_campaign, each capped to the most common values
+type CampaignStats struct {
+	Sources   map[string]int64 `json:"sources"`
+	Mediums   map[string]int64 `json:"mediums"`
+	Campaigns map[string]int64 `json:"campaigns"`
+}
+
+func newCampaignStats() CampaignStats {
+	return CampaignStats{
+		Sources:   make(map[string]int64),
+		Mediums:   make(map[string]int64),
+		Campaigns: make(map[string]int64),
+	}
+}
+
+// top caps each breakdown to its most common values
+func (c CampaignStats) top() CampaignStats {
+	return CampaignStats{
+		Sources:   topValues(c.Sources, maxTopValues),
+		Mediums:   topValues(c.Mediums, maxTopValues),
+		Campaigns: topValues(c.Campaigns, maxTopValues),
+	}
+}
+
+// referrerOrDirect is the key a response's referrer is counted under
+func referrerOrDirect(domain string) string {
+	if domain == "" {
+		return directTraffic
+	}
+	return domain
+}
+
+// campaignKeys returns the source, medium and campaign a response is counted under
+func campaignKeys(utm *models.UTMParams) (source, medium, campaign string) {
+	if utm == nil {
+		utm = &models.UTMParams{}
+	}
+	return orNotTagged(utm.Source), orNotTagged(utm.Medium), orNotTagged(utm.Campaign)
+}
+
+func orNotTagged(value string) string {
+	if value == "" {
+		return notTagged
+	}
+	return value
+}
+
+// topReferrer is the most common referrer, or Direct when there are none
+func topReferrer(referrers map[string]int64) string {
+	if top := topTerms(referrers, 1, 1); len(top) > 0 {
+		return top[0].Term
+	}
+	return directTraffic
+}
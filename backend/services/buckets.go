@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoadLocation resolves an IANA timezone name, treating "" as UTC
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// startOfDay returns local midnight of t's day in loc. Going through
+// time.Date keeps days that are 23 or 25 hours long across DST changes.
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// alignedTo reports whether every boundary starts a rollup of duration d.
+// The check is made on the wall clock of loc: a boundary has to fall on a
+// multiple of d there, and the rollups are bucketed in UTC, so loc's offset
+// at that moment has to be a multiple of d too. Local midnight in a UTC+5:30
+// zone is on the hour locally but starts no hourly rollup.
+func alignedTo(boundaries []time.Time, d time.Duration, loc *time.Location) bool {
+	for _, b := range boundaries {
+		local := b.In(loc)
+		_, offset := local.Zone()
+		clock := time.Duration(local.Hour())*time.Hour +
+			time.Duration(local.Minute())*time.Minute +
+			time.Duration(local.Second())*time.Second +
+			time.Duration(local.Nanosecond())
+		if clock%d != 0 || time.Duration(offset)*time.Second%d != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// countBuckets counts a form's responses in the consecutive ranges
+// [boundaries[i], boundaries[i+1]). Day- or hour-aligned ranges are summed
+// from the daily or hourly rollups; others, such as days in a UTC+5:30 zone,
+// and filtered counts fall back to a single $bucket aggregation over the raw
+// responses.
+func (s *AnalyticsService) countBuckets(ctx context.Context, formID primitive.ObjectID, filter bson.M, boundaries []time.Time, loc *time.Location, useRollups bool) ([]int64, error) {
+	if len(boundaries) < 2 {
+		return []int64{}, nil
+	}
+	counts := make([]int64, len(boundaries)-1)
+	first, last := boundaries[0], boundaries[len(boundaries)-1]
+
+	// bucketIndex finds the range t falls in
+	bucketIndex := func(t time.Time) int {
+		return sort.Search(len(boundaries), func(i int) bool { return boundaries[i].After(t) }) - 1
+	}
+
+	granularity := ""
+	if alignedTo(boundaries, 24*time.Hour, loc) {
+		granularity = rollupDay
+	} else if alignedTo(boundaries, time.Hour, loc) {
+		granularity = rollupHour
+	}
+
+	if useRollups && filter == nil && granularity != "" {
+		cursor, err := s.db.Collection("analytics_rollups").Find(ctx,
+			bson.M{
+				"formId":      formID,
+				"granularity": granularity,
+				"bucket":      bson.M{"$gte": first, "$lt": last},
+			},
+			options.Find().SetProjection(bson.M{"bucket": 1, "count": 1}))
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var r rollup
+			if err := cursor.Decode(&r); err != nil {
+				continue
+			}
+			if i := bucketIndex(r.Bucket); i >= 0 && i < len(counts) {
+				counts[i] += r.Count
+			}
+		}
+		return counts, cursor.Err()
+	}
+
+	bucketBoundaries := bson.A{}
+	for _, b := range boundaries {
+		bucketBoundaries = append(bucketBoundaries, b)
+	}
+
+	cursor, err := s.db.Collection("responses").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: withFilter(bson.M{
+			"formId":    formID,
+			"bot":       bson.M{"$ne": true},
+			"createdAt": bson.M{"$gte": first, "$lt": last},
+		}, filter)}},
+		{{Key: "$bucket", Value: bson.M{
+			"groupBy":    "$createdAt",
+			"boundaries": bucketBoundaries,
+			"output":     bson.M{"count": bson.M{"$sum": 1}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var b bucketCount
+		if err := cursor.Decode(&b); err != nil {
+			continue
+		}
+		if i := bucketIndex(b.Time); i >= 0 && i < len(counts) {
+			counts[i] += b.Count
+		}
+	}
+	return counts, cursor.Err()
+}
+
+// getPeakHour gets the local hour of day with the most responses. Hourly
+// rollups map onto local hours only when the zone's offset is whole hours.
+func (s *AnalyticsService) getPeakHour(ctx context.Context, formID primitive.ObjectID, filter bson.M, loc *time.Location, now time.Time, useRollups bool) (int, bool, error) {
+	_, offset := now.In(loc).Zone()
+
+	var collection *mongo.Collection
+	var match bson.M
+	var sum interface{}
+	var date string
+	if useRollups && filter == nil && offset%3600 == 0 {
+		collection = s.db.Collection("analytics_rollups")
+		match = bson.M{"formId": formID, "granularity": rollupHour}
+		sum, date = "$count", "$bucket"
+	} else {
+		collection = s.db.Collection("responses")
+		match = withFilter(humanResponses(formID), filter)
+		sum, date = 1, "$createdAt"
+	}
+
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"$hour": bson.M{"date": date, "timezone": loc.String()}},
+			"count": bson.M{"$sum": sum},
+		}}},
+		{{Key: "$sort", Value: bson.M{"count": -1}}},
+		{{Key: "$limit", Value: 1}},
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var peak struct {
+		Hour  int   `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&peak); err != nil {
+			return 0, false, err
+		}
+	}
+	return peak.Hour, peak.Count > 0, cursor.Err()
+}
+
+// responseTrendBoundaries returns the range boundaries of the default trend:
+// the 6 local days before today, then each local hour of today so far
+func responseTrendBoundaries(now time.Time, loc *time.Location) []time.Time {
+	todayStart := startOfDay(now, loc)
+
+	boundaries := []time.Time{}
+	for days := 6; days >= 1; days-- {
+		y, m, d := todayStart.Date()
+		boundaries = append(boundaries, time.Date(y, m, d-days, 0, 0, 0, 0, loc))
+	}
+
+	// Step in absolute hours so DST days get 23 or 25 hourly buckets
+	for hour := todayStart; !hour.After(now); hour = hour.Add(time.Hour) {
+		boundaries = append(boundaries, hour)
+	}
+	return append(boundaries, boundaries[len(boundaries)-1].Add(time.Hour))
+}
+
+// buildResponseTrends labels the counts of responseTrendBoundaries
+func buildResponseTrends(boundaries []time.Time, counts []int64, loc *time.Location) []TrendPoint {
+	trends := []TrendPoint{}
+	for i, count := range counts {
+		start := boundaries[i].In(loc)
+		label := start.Format("3:04 PM")
+		if boundaries[i+1].Sub(boundaries[i]) > time.Hour {
+			label = start.Format("Jan 2")
+		}
+		trends = append(trends, TrendPoint{
+			Time:  start,
+			Count: count,
+			Label: label,
+		})
+	}
+	return trends
+}
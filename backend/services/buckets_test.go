@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlignedTo(t *testing.T) {
+	zone := func(name string) *time.Location {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loc
+	}
+	tests := []struct {
+		name           string
+		loc            *time.Location
+		boundary       time.Time
+		wantDay, wantH bool
+	}{
+		{"UTC midnight", time.UTC, time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), true, true},
+		{"UTC hour", time.UTC, time.Date(2024, 3, 4, 13, 0, 0, 0, time.UTC), false, true},
+		{"UTC half hour", time.UTC, time.Date(2024, 3, 4, 13, 30, 0, 0, time.UTC), false, false},
+		{"Berlin midnight", zone("Europe/Berlin"), time.Date(2024, 3, 4, 0, 0, 0, 0, zone("Europe/Berlin")), false, true},
+		{"Berlin hour after DST", zone("Europe/Berlin"), time.Date(2024, 3, 31, 3, 0, 0, 0, zone("Europe/Berlin")), false, true},
+		{"Kolkata midnight", zone("Asia/Kolkata"), time.Date(2024, 3, 4, 0, 0, 0, 0, zone("Asia/Kolkata")), false, false},
+		{"Kolkata hour", zone("Asia/Kolkata"), time.Date(2024, 3, 4, 13, 0, 0, 0, zone("Asia/Kolkata")), false, false},
+		// On the UTC hour, but half past on the local clock
+		{"Kolkata half hour", zone("Asia/Kolkata"), time.Date(2024, 3, 4, 7, 0, 0, 0, time.UTC), false, false},
+		{"Kathmandu hour", zone("Asia/Kathmandu"), time.Date(2024, 3, 4, 13, 0, 0, 0, zone("Asia/Kathmandu")), false, false},
+		// Lord Howe moves its clocks by half an hour, so its whole-hour offset
+		// in summer becomes a half-hour one in winter
+		{"Lord Howe summer hour", zone("Australia/Lord_Howe"), time.Date(2024, 1, 10, 13, 0, 0, 0, zone("Australia/Lord_Howe")), false, true},
+		{"Lord Howe winter hour", zone("Australia/Lord_Howe"), time.Date(2024, 7, 10, 13, 0, 0, 0, zone("Australia/Lord_Howe")), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boundaries := []time.Time{tt.boundary}
+			if got := alignedTo(boundaries, 24*time.Hour, tt.loc); got != tt.wantDay {
+				t.Errorf("aligned to days = %v, want %v", got, tt.wantDay)
+			}
+			if got := alignedTo(boundaries, time.Hour, tt.loc); got != tt.wantH {
+				t.Errorf("aligned to hours = %v, want %v", got, tt.wantH)
+			}
+			// Whatever the zone, aligned boundaries start a UTC rollup
+			if alignedTo(boundaries, time.Hour, tt.loc) && !tt.boundary.Equal(rollupBucket(rollupHour, tt.boundary)) {
+				t.Errorf("%v is aligned but starts no hourly rollup", tt.boundary)
+			}
+		})
+	}
+}
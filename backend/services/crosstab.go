@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/models"
+)
+
+// CrossTab breaks one field's answers down by another field's answers
+type CrossTab struct {
+	FormID       string           `json:"formId"`
+	RowField     CrossTabField    `json:"rowField"`
+	ColumnField  CrossTabField    `json:"columnField"`
+	Rows         []string         `json:"rows"`
+	Columns      []string         `json:"columns"`
+	Total        int64            `json:"total"` // responses that answered both fields
+	RowTotals    map[string]int64 `json:"rowTotals"`
+	ColumnTotals map[string]int64 `json:"columnTotals"`
+	Cells        []CrossTabCell   `json:"cells"`
+}
+
+// CrossTabField identifies a field in a cross-tabulation
+type CrossTabField struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// CrossTabCell counts the responses answering Row to the row field and
+// Column to the column field
+type CrossTabCell struct {
+	Row           string  `json:"row"`
+	Column        string  `json:"column"`
+	Count         int64   `json:"count"`
+	RowPercent    float64 `json:"rowPercent"`
+	ColumnPercent float64 `json:"columnPercent"`
+	TotalPercent  float64 `json:"totalPercent"`
+}
+
+// GetCrossTab cross-tabulates two fields over the responses that answered
+// both. Multi-select answers contribute one count per selected option, so
+// row and column totals count responses rather than summing cells.
+func (s *AnalyticsService) GetCrossTab(formID, rowFieldID, columnFieldID string) (*CrossTab, error) {
+	objID, err := primitive.ObjectIDFromHex(formID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	var form models.Form
+	if err := s.db.Collection("forms").FindOne(ctx, bson.M{"_id": objID, "deletedAt": nil}).Decode(&form); err != nil {
+		return nil, err
+	}
+
+	rowField, ok := findField(form.Fields, rowFieldID)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown row field %q", ErrInvalidQuery, rowFieldID)
+	}
+	columnField, ok := findField(form.Fields, columnFieldID)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown column field %q", ErrInvalidQuery, columnFieldID)
+	}
+	if rowField.ID == columnField.ID {
+		return nil, fmt.Errorf("%w: row and column fields must differ", ErrInvalidQuery)
+	}
+	if rowField.PII || columnField.PII {
+		return nil, fmt.Errorf("%w: PII fields cannot be cross-tabulated", ErrInvalidQuery)
+	}
+	if !isQueryableFieldID(rowField.ID) || !isQueryableFieldID(columnField.ID) {
+		return nil, fmt.Errorf("%w: field IDs containing '.' or starting with '$' cannot be cross-tabulated", ErrInvalidQuery)
+	}
+
+	// Normalize both answers to de-duplicated arrays of non-empty values
+	asValues := func(path string) bson.M {
+		return bson.M{"$filter": bson.M{
+			"input": bson.M{"$cond": bson.A{
+				bson.M{"$isArray": path}, bson.M{"$setUnion": bson.A{path}}, bson.A{path},
+			}},
+			"cond": bson.M{"$not": bson.A{bson.M{"$in": bson.A{"$$this", bson.A{nil, ""}}}}},
+		}}
+	}
+	countBy := func(keys bson.M) bson.A {
+		return bson.A{bson.M{"$group": bson.M{"_id": keys, "count": bson.M{"$sum": 1}}}}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: humanResponses(objID)}},
+		{{Key: "$project", Value: bson.M{
+			"r": asValues("$data." + rowField.ID),
+			"c": asValues("$data." + columnField.ID),
+		}}},
+		{{Key: "$match", Value: bson.M{"r.0": bson.M{"$exists": true}, "c.0": bson.M{"$exists": true}}}},
+		{{Key: "$facet", Value: bson.M{
+			"total": bson.A{bson.M{"$count": "count"}},
+			"rows":  append(bson.A{bson.M{"$unwind": "$r"}}, countBy(bson.M{"r": "$r"})...),
+			"columns": append(bson.A{bson.M{"$unwind": "$c"}},
+				countBy(bson.M{"c": "$c"})...),
+			"cells": append(bson.A{bson.M{"$unwind": "$r"}, bson.M{"$unwind": "$c"}},
+				countBy(bson.M{"r": "$r", "c": "$c"})...),
+		}}},
+	}
+
+	cursor, err := s.db.Collection("responses").Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	type valueCount struct {
+		Key struct {
+			Row    interface{} `bson:"r"`
+			Column interface{} `bson:"c"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	var result struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Rows    []valueCount `bson:"rows"`
+		Columns []valueCount `bson:"columns"`
+		Cells   []valueCount `bson:"cells"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+	}
+
+	crossTab := &CrossTab{
+		FormID:       formID,
+		RowField:     CrossTabField{ID: rowField.ID, Label: rowField.Label},
+		ColumnField:  CrossTabField{ID: columnField.ID, Label: columnField.Label},
+		RowTotals:    make(map[string]int64),
+		ColumnTotals: make(map[string]int64),
+		Cells:        []CrossTabCell{},
+	}
+	if len(result.Total) > 0 {
+		crossTab.Total = result.Total[0].Count
+	}
+
+	// Values are merged by their string form so 5 and 5.0 share a row
+	for _, r := range result.Rows {
+		if key := toString(r.Key.Row); key != "" {
+			crossTab.RowTotals[key] += r.Count
+		}
+	}
+	for _, c := range result.Columns {
+		if key := toString(c.Key.Column); key != "" {
+			crossTab.ColumnTotals[key] += c.Count
+		}
+	}
+	cells := make(map[[2]string]int64)
+	for _, cell := range result.Cells {
+		row, column := toString(cell.Key.Row), toString(cell.Key.Column)
+		if row != "" && column != "" {
+			cells[[2]string{row, column}] += cell.Count
+		}
+	}
+
+	crossTab.Rows = orderedValues(rowField, crossTab.RowTotals)
+	crossTab.Columns = orderedValues(columnField, crossTab.ColumnTotals)
+	for _, row := range crossTab.Rows {
+		for _, column := range crossTab.Columns {
+			count := cells[[2]string{row, column}]
+			crossTab.Cells = append(crossTab.Cells, CrossTabCell{
+				Row:           row,
+				Column:        column,
+				Count:         count,
+				RowPercent:    percentage(count, crossTab.RowTotals[row]),
+				ColumnPercent: percentage(count, crossTab.ColumnTotals[column]),
+				TotalPercent:  percentage(count, crossTab.Total),
+			})
+		}
+	}
+
+	return crossTab, nil
+}
+
+// orderedValues lists the answered values of a field: its configured options
+// first, in form order, then any other values numerically or alphabetically
+func orderedValues(field models.FormField, totals map[string]int64) []string {
+	values := make([]string, 0, len(totals))
+	listed := make(map[string]bool, len(field.Options))
+	for _, option := range field.Options {
+		if _, ok := totals[option]; ok && !listed[option] {
+			values = append(values, option)
+			listed[option] = true
+		}
+	}
+
+	var rest []string
+	for value := range totals {
+		if !listed[value] {
+			rest = append(rest, value)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		a, errA := strconv.ParseFloat(rest[i], 64)
+		b, errB := strconv.ParseFloat(rest[j], 64)
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return rest[i] < rest[j]
+	})
+
+	return append(values, rest...)
+}
+
+// findField looks up a form field by ID
+func findField(fields []models.FormField, id string) (models.FormField, bool) {
+	for _, field := range fields {
+		if field.ID == id {
+			return field, true
+		}
+	}
+	return models.FormField{}, false
+}
+
+// isQueryableFieldID reports whether a field ID can be used in a "data.<id>" path
+func isQueryableFieldID(id string) bool {
+	return id != "" && !strings.Contains(id, ".") && !strings.HasPrefix(id, "$")
+}
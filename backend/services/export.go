@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"form-builder-backend/models"
+	"form-builder-backend/xlsx"
+)
+
+// Export formats. All but ExportPDF list the responses themselves; a PDF is
+// an analytics summary.
+const (
+	ExportCSV    = "csv"
+	ExportXLSX   = "xlsx"
+	ExportJSON   = "json"
+	ExportNDJSON = "ndjson"
+	ExportPDF    = "pdf"
+)
+
+// ExportContentTypes maps each export format to its MIME type
+var ExportContentTypes = map[string]string{
+	ExportCSV:    "text/csv; charset=utf-8",
+	ExportXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	ExportJSON:   "application/json",
+	ExportNDJSON: "application/x-ndjson",
+	ExportPDF:    "application/pdf",
+}
+
+// exportFlushEvery is how many responses are buffered before they are
+// flushed to the client
+const exportFlushEvery = 500
+
+// exportIncomplete ends a CSV or NDJSON export that failed partway, so a
+// file cut short after the headers were sent can't pass for a complete one
+const exportIncomplete = "EXPORT INCOMPLETE: an error stopped the export before every response was written"
+
+// ExportHeader returns the column headings of an export: submission time,
+// device and IP address, then each field's label in form order. Times are
+// given in loc.
+func ExportHeader(form *models.Form, loc *time.Location) []string {
+	header := []string{fmt.Sprintf("Submitted At (%s)", loc), "Device", "IP Address"}
+	for _, field := range form.Fields {
+		label := field.Label
+		if label == "" {
+			label = field.ID
+		}
+		header = append(header, label)
+	}
+	return header
+}
+
+// ExportRow returns a response's cells in ExportHeader order. Numeric
+// answers are float64 so spreadsheets can calculate with them; everything
+// else is a string, with multi-select answers joined by "; ".
+func ExportRow(form *models.Form, response *models.FormResponse, loc *time.Location) []interface{} {
+	row := []interface{}{
+		response.CreatedAt.In(loc).Format("2006-01-02 15:04:05"),
+		response.Device,
+		response.IPAddress,
+	}
+	for _, field := range form.Fields {
+		row = append(row, exportCell(response.Data[field.ID]))
+	}
+	return row
+}
+
+func exportCell(v interface{}) interface{} {
+	switch val := v.(type) {
+	case float64:
+		return val
+	case float32:
+		return float64(val)
+	case int:
+		return float64(val)
+	case int32:
+		return float64(val)
+	case int64:
+		return float64(val)
+	case primitive.A, []interface{}, []string:
+		return strings.Join(answerValues(val), "; ")
+	}
+	return toString(v)
+}
+
+// rowWriter is the part of a spreadsheet format the exporter uses
+type rowWriter interface {
+	WriteHeader(cells []string) error
+	WriteRow(cells []interface{}) error
+	Flush() error
+	Close() error
+}
+
+// csvRowWriter writes rows as CSV
+type csvRowWriter struct {
+	w *csv.Writer
+}
+
+func (c csvRowWriter) WriteHeader(cells []string) error {
+	return c.w.Write(cells)
+}
+
+// WriteRow quotes text that a spreadsheet would run as a formula
+func (c csvRowWriter) WriteRow(cells []interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case float64:
+			record[i] = formatNumber(v)
+		case string:
+			if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+				v = "'" + v
+			}
+			record[i] = v
+		}
+	}
+	return c.w.Write(record)
+}
+
+func (c csvRowWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c csvRowWriter) Close() error {
+	return c.Flush()
+}
+
+// spreadsheetEncoder writes each response as a row of ExportRow cells
+type spreadsheetEncoder struct {
+	rows rowWriter
+	form *models.Form
+	loc  *time.Location
+}
+
+func (e spreadsheetEncoder) Encode(response *models.FormResponse) error {
+	return e.rows.WriteRow(ExportRow(e.form, response, e.loc))
+}
+
+func (e spreadsheetEncoder) Flush() error {
+	return e.rows.Flush()
+}
+
+func (e spreadsheetEncoder) Close() error {
+	return e.rows.Close()
+}
+
+// Abort marks a CSV as incomplete. An XLSX is left without its closing zip
+// directory, which spreadsheet apps refuse to open.
+func (e spreadsheetEncoder) Abort() error {
+	if _, ok := e.rows.(csvRowWriter); !ok {
+		return nil
+	}
+	if err := e.rows.WriteRow([]interface{}{exportIncomplete}); err != nil {
+		return err
+	}
+	return e.rows.Flush()
+}
+
+// jsonEncoder writes responses as a JSON array, or with lines set, as
+// newline-delimited JSON
+type jsonEncoder struct {
+	w     io.Writer
+	lines bool
+	n     int
+}
+
+func (e *jsonEncoder) Encode(response *models.FormResponse) error {
+	b, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	switch {
+	case e.lines:
+		b = append(b, '\n')
+	case e.n == 0:
+		b = append([]byte("[\n"), b...)
+	default:
+		b = append([]byte(",\n"), b...)
+	}
+	e.n++
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *jsonEncoder) Flush() error {
+	return nil
+}
+
+// Abort marks NDJSON as incomplete with a final error line. A JSON array is
+// left unterminated, which no parser accepts.
+func (e *jsonEncoder) Abort() error {
+	if !e.lines {
+		return nil
+	}
+	b, err := json.Marshal(map[string]string{"error": exportIncomplete})
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(b, '\n'))
+	return err
+}
+
+func (e *jsonEncoder) Close() error {
+	if e.lines {
+		return nil
+	}
+	end := "\n]\n"
+	if e.n == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// responseEncoder writes exported responses in one format
+type responseEncoder interface {
+	Encode(response *models.FormResponse) error
+	Flush() error
+	Close() error
+	// Abort ends an export that failed partway so that it reads as broken
+	Abort() error
+}
+
+func newResponseEncoder(format string, form *models.Form, loc *time.Location, w io.Writer) (responseEncoder, error) {
+	var rows rowWriter
+	switch format {
+	case ExportCSV:
+		// A byte order mark makes Excel read the file as UTF-8
+		if _, err := io.WriteString(w, "\ufeff"); err != nil {
+			return nil, err
+		}
+		rows = csvRowWriter{w: csv.NewWriter(w)}
+	case ExportXLSX:
+		sheet, err := xlsx.NewWriter(w, "Responses")
+		if err != nil {
+			return nil, err
+		}
+		rows = sheet
+	case ExportJSON:
+		return &jsonEncoder{w: w}, nil
+	case ExportNDJSON:
+		return &jsonEncoder{w: w, lines: true}, nil
+	default:
+		return nil, fmt.Errorf("%w: format must be one of csv, xlsx, json or ndjson", ErrInvalidQuery)
+	}
+	if err := rows.WriteHeader(ExportHeader(form, loc)); err != nil {
+		return nil, err
+	}
+	return spreadsheetEncoder{rows: rows, form: form, loc: loc}, nil
+}
+
+// ExportResponses streams every response of form matching query to w in
+// format, in the query's order, and returns how many were written. The
+// cursor and limit of query are ignored. Encrypted answers are decrypted
+// when reveal is set and masked otherwise. When progress is set it is called
+// with the running count every exportFlushEvery responses. An export that
+// fails after it has started writing is ended so it can't be mistaken for a
+// complete file.
+func (s *ResponseService) ExportResponses(ctx context.Context, form *models.Form, query models.ResponseListQuery, format string, loc *time.Location, w io.Writer, reveal bool, progress func(written int64)) (int64, error) {
+	out, err := newResponseEncoder(format, form, loc, w)
+	if err != nil {
+		return 0, err
+	}
+	written, err := s.exportResponses(ctx, form, query, out, reveal, progress)
+	if err != nil {
+		if abortErr := out.Abort(); abortErr != nil {
+			log.Printf("Error marking export incomplete: %v", abortErr)
+		}
+		return written, err
+	}
+	return written, out.Close()
+}
+
+func (s *ResponseService) exportResponses(ctx context.Context, form *models.Form, query models.ResponseListQuery, out responseEncoder, reveal bool, progress func(written int64)) (int64, error) {
+	collection := s.db.Collection("responses")
+	cursor, err := findSorted(ctx, collection, ResponseMatch(form.ID, query.Criteria, query.Search), query, nil, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	written := int64(0)
+	for cursor.Next(ctx) {
+		var response models.FormResponse
+		if err := cursor.Decode(&response); err != nil {
+			return written, err
+		}
+		if !reveal {
+			response.Data = MaskSensitive(form, response.Data)
+		} else if err := s.cipher.Reveal(ctx, form, &response); err != nil {
+			log.Printf("Error decrypting response %s: %v", response.ID.Hex(), err)
+		}
+		if err := out.Encode(&response); err != nil {
+			return written, err
+		}
+		written++
+		if written%exportFlushEvery == 0 {
+			if err := out.Flush(); err != nil {
+				return written, err
+			}
+			if progress != nil {
+				progress(written)
+			}
+		}
+	}
+	return written, cursor.Err()
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"form-builder-backend/models"
+)
+
+func exportTestForm() *models.Form {
+	return &models.Form{Fields: []models.FormField{
+		{ID: "name", Label: "Name"},
+		{ID: "score", Label: "Score"},
+		{ID: "colors", Label: "Colors"},
+	}}
+}
+
+func exportTestResponse() *models.FormResponse {
+	return &models.FormResponse{
+		CreatedAt: time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC),
+		Device:    "Desktop",
+		IPAddress: "192.0.2.1",
+		Data: map[string]interface{}{
+			"name":   "=HYPERLINK(\"x\")",
+			"score":  int32(4),
+			"colors": []interface{}{"red", "blue"},
+		},
+	}
+}
+
+func TestCSVExport(t *testing.T) {
+	var buf bytes.Buffer
+	out, err := newResponseEncoder(ExportCSV, exportTestForm(), time.UTC, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Encode(exportTestResponse()); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(buf.String(), "\ufeff") {
+		t.Error("CSV should start with a byte order mark")
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(buf.String(), "\ufeff"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Submitted At (UTC)", "Device", "IP Address", "Name", "Score", "Colors"},
+		{"2024-05-01 09:30:00", "Desktop", "192.0.2.1", "'=HYPERLINK(\"x\")", "4", "red; blue"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %q", len(records), len(want), records)
+	}
+	for i := range want {
+		if strings.Join(records[i], "|") != strings.Join(want[i], "|") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestAbortMarksExportIncomplete(t *testing.T) {
+	for _, format := range []string{ExportCSV, ExportNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			out, err := newResponseEncoder(format, exportTestForm(), time.UTC, &buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := out.Encode(exportTestResponse()); err != nil {
+				t.Fatal(err)
+			}
+			if err := out.Abort(); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if last := lines[len(lines)-1]; !strings.Contains(last, exportIncomplete) {
+				t.Errorf("last line = %q, want the incomplete marker", last)
+			}
+		})
+	}
+}
+
+func TestAbortLeavesJSONArrayInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	out, err := newResponseEncoder(ExportJSON, exportTestForm(), time.UTC, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Encode(exportTestResponse()); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if json.Valid(buf.Bytes()) {
+		t.Errorf("an aborted JSON export parsed as valid: %s", buf.String())
+	}
+}
@@ -0,0 +1,549 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/blob"
+	"form-builder-backend/models"
+	"form-builder-backend/search"
+)
+
+// ErrExportNotReady is returned when downloading a job that hasn't completed
+var ErrExportNotReady = errors.New("export is not ready")
+
+// ErrExportExpired is returned when downloading a job whose artifact was
+// deleted after its TTL
+var ErrExportExpired = errors.New("export has expired")
+
+// ExportJobConfig tunes the export workers
+type ExportJobConfig struct {
+	// Workers is how many jobs run at once
+	Workers int
+	// TTL is how long a finished artifact can be downloaded
+	TTL time.Duration
+	// SweepInterval is how often expired artifacts are deleted and queued
+	// jobs that weren't picked up are retried
+	SweepInterval time.Duration
+	// ProgressInterval is the least time between progress updates of a job
+	ProgressInterval time.Duration
+	// Lease is how long a running job can go without a heartbeat before the
+	// sweep assumes its worker died and queues it again
+	Lease time.Duration
+}
+
+// DefaultExportJobConfig returns the default export settings
+func DefaultExportJobConfig() ExportJobConfig {
+	return ExportJobConfig{
+		Workers:          2,
+		TTL:              24 * time.Hour,
+		SweepInterval:    time.Minute,
+		ProgressInterval: time.Second,
+		Lease:            5 * time.Minute,
+	}
+}
+
+// exportQueueSize is how many jobs can wait for a worker before new ones are
+// left for the sweep to pick up
+const exportQueueSize = 100
+
+// ExportJobService runs exports in the background and keeps their artifacts
+// in a blob store until they expire
+type ExportJobService struct {
+	db        *mongo.Database
+	responses *ResponseService
+	analytics *AnalyticsService
+	store     blob.Store
+	config    ExportJobConfig
+	queue     chan primitive.ObjectID
+	handlers  []func(models.ExportJob)
+}
+
+// NewExportJobService creates an export job service. Each handler is called
+// whenever a job is created, changes status or makes progress.
+func NewExportJobService(db *mongo.Database, responses *ResponseService, analytics *AnalyticsService, store blob.Store, config ExportJobConfig, handlers ...func(models.ExportJob)) *ExportJobService {
+	return &ExportJobService{
+		db:        db,
+		responses: responses,
+		analytics: analytics,
+		store:     store,
+		config:    config,
+		queue:     make(chan primitive.ObjectID, exportQueueSize),
+		handlers:  handlers,
+	}
+}
+
+// EnsureIndexes creates the indexes for listing a form's jobs and finding
+// expired ones
+func (s *ExportJobService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.db.Collection("export_jobs").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "formId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresAt", Value: 1}}},
+	})
+	return err
+}
+
+// Create validates an export request and queues the job. reveal decrypts
+// the answers to PII fields in the file rather than masking them.
+func (s *ExportJobService) Create(ctx context.Context, form *models.Form, userID string, reveal bool, req models.CreateExportRequest) (*models.ExportJob, error) {
+	if req.Format == "" {
+		req.Format = ExportCSV
+	}
+	if _, ok := ExportContentTypes[req.Format]; !ok {
+		return nil, fmt.Errorf("%w: format must be one of csv, xlsx, json, ndjson or pdf", ErrInvalidQuery)
+	}
+	if req.Format == ExportPDF && strings.TrimSpace(req.Query) != "" {
+		return nil, fmt.Errorf("%w: pdf summaries can't be narrowed by a search", ErrInvalidQuery)
+	}
+
+	job := &models.ExportJob{
+		FormID:    form.ID,
+		UserID:    userID,
+		Format:    req.Format,
+		Filter:    req.Filter,
+		Query:     req.Query,
+		Sort:      req.Sort,
+		Order:     req.Order,
+		Timezone:  req.Timezone,
+		Reveal:    reveal,
+		Status:    models.ExportQueued,
+		CreatedAt: time.Now(),
+	}
+	if _, _, err := exportQuery(form, job); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Collection("export_jobs").InsertOne(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	job.ID = result.InsertedID.(primitive.ObjectID)
+
+	s.notify(*job)
+	s.enqueue(job.ID)
+	return job, nil
+}
+
+// Get returns one of a form's jobs
+func (s *ExportJobService) Get(ctx context.Context, formID, id primitive.ObjectID) (*models.ExportJob, error) {
+	var job models.ExportJob
+	err := s.db.Collection("export_jobs").FindOne(ctx, bson.M{"_id": id, "formId": formID}).Decode(&job)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// List returns a form's most recent jobs, newest first
+func (s *ExportJobService) List(ctx context.Context, formID primitive.ObjectID, limit int64) ([]models.ExportJob, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit)
+	cursor, err := s.db.Collection("export_jobs").Find(ctx, bson.M{"formId": formID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	jobs := []models.ExportJob{}
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// Open returns a completed job's artifact and its size
+func (s *ExportJobService) Open(job *models.ExportJob) (io.ReadCloser, int64, error) {
+	switch job.Status {
+	case models.ExportCompleted:
+	case models.ExportExpired:
+		return nil, 0, ErrExportExpired
+	default:
+		return nil, 0, ErrExportNotReady
+	}
+	if job.ExpiresAt != nil && time.Now().After(*job.ExpiresAt) {
+		return nil, 0, ErrExportExpired
+	}
+	return s.store.Open(job.BlobKey)
+}
+
+// Delete removes a job and its artifact. A job that is still running
+// discards its artifact when it finishes.
+func (s *ExportJobService) Delete(ctx context.Context, job *models.ExportJob) error {
+	if _, err := s.db.Collection("export_jobs").DeleteOne(ctx, bson.M{"_id": job.ID}); err != nil {
+		return err
+	}
+	if job.BlobKey != "" {
+		return s.store.Delete(job.BlobKey)
+	}
+	return nil
+}
+
+// DeleteForm removes all of a form's jobs and their artifacts
+func (s *ExportJobService) DeleteForm(ctx context.Context, formID primitive.ObjectID) error {
+	jobs, err := s.List(ctx, formID, 0)
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		if err := s.Delete(ctx, &jobs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run starts the workers and sweeps expired artifacts until ctx is done
+func (s *ExportJobService) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-s.queue:
+					s.process(ctx, id)
+				}
+			}
+		}()
+	}
+
+	s.sweep(ctx, time.Now(), true)
+	ticker := time.NewTicker(s.config.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case now := <-ticker.C:
+			s.sweep(ctx, now, false)
+		}
+	}
+}
+
+func (s *ExportJobService) enqueue(id primitive.ObjectID) {
+	select {
+	case s.queue <- id:
+	default:
+		// The sweep picks the job up once the queue has room
+	}
+}
+
+func (s *ExportJobService) notify(job models.ExportJob) {
+	for _, handle := range s.handlers {
+		handle(job)
+	}
+}
+
+// sweep deletes artifacts past their TTL, requeues running jobs whose lease
+// ran out, and queues jobs that have waited longer than a sweep interval, or
+// on startup, every queued job
+func (s *ExportJobService) sweep(ctx context.Context, now time.Time, startup bool) {
+	collection := s.db.Collection("export_jobs")
+
+	// A job's worker may still be running in another process, so only jobs
+	// that stopped sending heartbeats are taken back. Jobs claimed before
+	// heartbeats were recorded fall back to when they started.
+	stale := now.Add(-s.config.Lease)
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"status": models.ExportRunning, "$or": bson.A{
+			bson.M{"heartbeatAt": bson.M{"$lte": stale}},
+			bson.M{"heartbeatAt": bson.M{"$exists": false}, "startedAt": bson.M{"$lte": stale}},
+		}},
+		bson.M{
+			"$set":   bson.M{"status": models.ExportQueued, "processed": 0, "progress": 0},
+			"$unset": bson.M{"heartbeatAt": ""},
+		},
+	)
+	if err != nil {
+		log.Printf("Error requeueing interrupted export jobs: %v", err)
+	}
+
+	cursor, err := collection.Find(ctx, bson.M{
+		"status":    models.ExportCompleted,
+		"expiresAt": bson.M{"$lte": now},
+	})
+	if err != nil {
+		log.Printf("Error finding expired exports: %v", err)
+	} else {
+		var expired []models.ExportJob
+		if err := cursor.All(ctx, &expired); err != nil {
+			log.Printf("Error reading expired exports: %v", err)
+		}
+		for _, job := range expired {
+			if err := s.store.Delete(job.BlobKey); err != nil {
+				log.Printf("Error deleting export %s: %v", job.ID.Hex(), err)
+				continue
+			}
+			_, err := collection.UpdateOne(ctx, bson.M{"_id": job.ID}, bson.M{
+				"$set":   bson.M{"status": models.ExportExpired},
+				"$unset": bson.M{"blobKey": ""},
+			})
+			if err != nil {
+				log.Printf("Error expiring export %s: %v", job.ID.Hex(), err)
+				continue
+			}
+			job.Status = models.ExportExpired
+			job.BlobKey = ""
+			s.notify(job)
+		}
+	}
+
+	waiting := bson.M{"status": models.ExportQueued}
+	if !startup {
+		waiting["createdAt"] = bson.M{"$lte": now.Add(-s.config.SweepInterval)}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetProjection(bson.M{"_id": 1}).SetLimit(exportQueueSize)
+	cursor, err = collection.Find(ctx, waiting, opts)
+	if err != nil {
+		log.Printf("Error finding queued exports: %v", err)
+		return
+	}
+	var queued []models.ExportJob
+	if err := cursor.All(ctx, &queued); err != nil {
+		log.Printf("Error reading queued exports: %v", err)
+	}
+	for _, job := range queued {
+		s.enqueue(job.ID)
+	}
+}
+
+// process runs a queued job. Claiming it by status means a job queued twice
+// only runs once.
+func (s *ExportJobService) process(ctx context.Context, id primitive.ObjectID) {
+	collection := s.db.Collection("export_jobs")
+	now := time.Now()
+	var job models.ExportJob
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": models.ExportQueued},
+		bson.M{"$set": bson.M{"status": models.ExportRunning, "startedAt": now, "heartbeatAt": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&job)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error claiming export %s: %v", id.Hex(), err)
+		}
+		return
+	}
+	s.notify(job)
+
+	stop := s.heartbeat(ctx, id)
+	err = s.export(ctx, &job)
+	stop()
+	if err != nil {
+		log.Printf("Error running export %s: %v", id.Hex(), err)
+		message := "export failed"
+		if errors.Is(err, ErrInvalidQuery) {
+			message = err.Error()
+		}
+		job.Status = models.ExportFailed
+		job.Error = message
+		_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+			"status": job.Status,
+			"error":  job.Error,
+		}})
+		if err != nil {
+			log.Printf("Error recording failed export %s: %v", id.Hex(), err)
+		}
+		s.notify(job)
+	}
+}
+
+// heartbeat renews a running job's lease until the returned stop function
+// is called
+func (s *ExportJobService) heartbeat(ctx context.Context, id primitive.ObjectID) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.config.Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				_, err := s.db.Collection("export_jobs").UpdateOne(ctx,
+					bson.M{"_id": id, "status": models.ExportRunning},
+					bson.M{"$set": bson.M{"heartbeatAt": now}},
+				)
+				if err != nil && ctx.Err() == nil {
+					log.Printf("Error renewing export %s: %v", id.Hex(), err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// export writes a running job's artifact and marks it completed
+func (s *ExportJobService) export(ctx context.Context, job *models.ExportJob) error {
+	collection := s.db.Collection("export_jobs")
+
+	var form models.Form
+	if err := s.db.Collection("forms").FindOne(ctx, bson.M{"_id": job.FormID, "deletedAt": nil}).Decode(&form); err != nil {
+		return err
+	}
+	query, loc, err := exportQuery(&form, job)
+	if err != nil {
+		return err
+	}
+
+	key := job.ID.Hex() + "." + job.Format
+	out, err := s.store.Create(key)
+	if err != nil {
+		return err
+	}
+	counter := &countingWriter{w: out}
+	buf := bufio.NewWriterSize(counter, 64*1024)
+
+	var written int64
+	if job.Format == ExportPDF {
+		written, err = s.writeSummary(&form, job, loc, buf)
+	} else {
+		job.Total, err = s.db.Collection("responses").CountDocuments(ctx, ResponseMatch(form.ID, query.Criteria, query.Search))
+		if err != nil {
+			out.Abort()
+			return err
+		}
+		s.progress(ctx, job, 0)
+
+		lastUpdate := time.Now()
+		written, err = s.responses.ExportResponses(ctx, &form, query, job.Format, loc, buf, job.Reveal, func(n int64) {
+			if time.Since(lastUpdate) >= s.config.ProgressInterval {
+				lastUpdate = time.Now()
+				s.progress(ctx, job, n)
+			}
+		})
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err != nil {
+		out.Abort()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.config.TTL)
+	job.Status = models.ExportCompleted
+	job.Processed = written
+	job.Progress = 100
+	if job.Total < written {
+		job.Total = written
+	}
+	job.BlobKey = key
+	job.Filename = ExportFilename(&form, job.Format, now.In(loc))
+	job.Size = counter.n
+	job.CompletedAt = &now
+	job.ExpiresAt = &expiresAt
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": job.ID}, bson.M{"$set": bson.M{
+		"status":      job.Status,
+		"total":       job.Total,
+		"processed":   job.Processed,
+		"progress":    job.Progress,
+		"blobKey":     job.BlobKey,
+		"filename":    job.Filename,
+		"size":        job.Size,
+		"completedAt": job.CompletedAt,
+		"expiresAt":   job.ExpiresAt,
+	}})
+	if err != nil {
+		s.store.Delete(key)
+		return err
+	}
+	if result.MatchedCount == 0 {
+		// The job was deleted while it ran
+		return s.store.Delete(key)
+	}
+	s.notify(*job)
+	return nil
+}
+
+// progress records how many responses a running job has written
+func (s *ExportJobService) progress(ctx context.Context, job *models.ExportJob, processed int64) {
+	job.Processed = processed
+	job.Progress = 0
+	if job.Total > 0 {
+		job.Progress = percentage(processed, job.Total)
+	}
+	_, err := s.db.Collection("export_jobs").UpdateOne(ctx, bson.M{"_id": job.ID}, bson.M{"$set": bson.M{
+		"total":     job.Total,
+		"processed": job.Processed,
+		"progress":  job.Progress,
+	}})
+	if err != nil {
+		log.Printf("Error recording export progress %s: %v", job.ID.Hex(), err)
+	}
+	s.notify(*job)
+}
+
+// exportQuery builds the response query of a job, and the location its
+// dates are read in: the job's timezone, or else the form's
+func exportQuery(form *models.Form, job *models.ExportJob) (models.ResponseListQuery, *time.Location, error) {
+	loc, err := LoadLocation(form.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	if job.Timezone != "" {
+		if loc, err = LoadLocation(job.Timezone); err != nil {
+			return models.ResponseListQuery{}, nil, fmt.Errorf("%w: invalid timezone %q", ErrInvalidQuery, job.Timezone)
+		}
+	}
+
+	query, err := NewResponseListQuery(form, job.Filter, job.Sort, job.Order, loc)
+	if err != nil {
+		return query, nil, err
+	}
+	query.Search = search.QueryTerms(job.Query)
+	return query, loc, nil
+}
+
+// exportFilenameChars matches characters left out of export filenames
+var exportFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ExportFilename names an export of form's responses made on date
+func ExportFilename(form *models.Form, format string, date time.Time) string {
+	name := strings.Trim(exportFilenameChars.ReplaceAllString(form.Title, "-"), "-.")
+	if name == "" {
+		name = "form"
+	}
+	kind := "responses"
+	if format == ExportPDF {
+		kind = "summary"
+	}
+	return fmt.Sprintf("%s-%s-%s.%s", name, kind, date.Format("2006-01-02"), format)
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
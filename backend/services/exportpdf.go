@@ -0,0 +1,127 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"form-builder-backend/models"
+	"form-builder-backend/pdf"
+)
+
+// summaryTopValues is the number of most common answers listed per field
+const summaryTopValues = 10
+
+// writeSummary writes a PDF of the form's analytics for the job's filter
+// and returns the number of responses it covers
+func (s *ExportJobService) writeSummary(form *models.Form, job *models.ExportJob, loc *time.Location, w io.Writer) (int64, error) {
+	analytics, err := s.analytics.GetFormAnalytics(form.ID.Hex(), AnalyticsOptions{
+		Location: loc,
+		Filter:   job.Filter,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	doc := pdf.New()
+	doc.Title(form.Title)
+	doc.Text(fmt.Sprintf("Response summary generated %s", time.Now().In(loc).Format("2 Jan 2006 15:04 MST")))
+	if !job.Filter.IsEmpty() {
+		doc.Text("Filtered to " + describeFilter(form, job.Filter))
+	}
+
+	doc.Heading("Overview")
+	doc.Indented(1, fmt.Sprintf("Responses: %d (today %d, last 7 days %d, last 30 days %d)",
+		analytics.TotalResponses, analytics.TodayResponses, analytics.WeekResponses, analytics.MonthResponses))
+	doc.Indented(1, fmt.Sprintf("Completion rate: %.1f%%", analytics.CompletionRate))
+	if analytics.AverageTime > 0 {
+		doc.Indented(1, fmt.Sprintf("Median time to complete: %s", time.Duration(analytics.AverageTime*float64(time.Second)).Round(time.Second)))
+	}
+	doc.Indented(1, fmt.Sprintf("Busiest hour: %02d:00", analytics.PeakHour))
+
+	doc.Heading("Devices")
+	for _, device := range topTerms(analytics.DeviceStats, len(analytics.DeviceStats), 1) {
+		doc.Indented(1, fmt.Sprintf("%s: %d (%.1f%%)", device.Term, device.Count, percentage(device.Count, analytics.TotalResponses)))
+	}
+
+	for _, field := range form.Fields {
+		stats, ok := analytics.FieldAnalytics[field.ID]
+		if !ok {
+			continue
+		}
+		label := field.Label
+		if label == "" {
+			label = field.ID
+		}
+		doc.Heading(label)
+		doc.Indented(1, fmt.Sprintf("%d answered, %d skipped", stats.ResponseCount, stats.SkipCount))
+		if stats.Numeric != nil && stats.Numeric.Count > 0 {
+			doc.Indented(1, fmt.Sprintf("Mean %s, median %s, min %s, max %s",
+				formatNumber(stats.Numeric.Mean), formatNumber(stats.Numeric.Median),
+				formatNumber(stats.Numeric.Min), formatNumber(stats.Numeric.Max)))
+		}
+		if stats.Text != nil {
+			doc.Indented(1, fmt.Sprintf("Average length %.0f characters", stats.Text.AverageLength))
+			words := make([]string, len(stats.Text.TopWords))
+			for i, word := range stats.Text.TopWords {
+				words[i] = fmt.Sprintf("%s (%d)", word.Term, word.Count)
+			}
+			if len(words) > 0 {
+				doc.Indented(1, "Common words: "+strings.Join(words, ", "))
+			}
+			continue
+		}
+		for _, value := range topTerms(stats.TopValues, summaryTopValues, 1) {
+			doc.Indented(2, fmt.Sprintf("%s: %d (%.1f%%)", value.Term, value.Count, percentage(value.Count, stats.ResponseCount)))
+		}
+	}
+
+	if _, err := doc.WriteTo(w); err != nil {
+		return 0, err
+	}
+	return analytics.TotalResponses, nil
+}
+
+// describeFilter summarizes a response filter for a report
+func describeFilter(form *models.Form, filter models.ResponseFilter) string {
+	var parts []string
+	if filter.From != "" {
+		parts = append(parts, "from "+filter.From)
+	}
+	if filter.To != "" {
+		parts = append(parts, "to "+filter.To)
+	}
+	if filter.Device != "" {
+		parts = append(parts, "device "+filter.Device)
+	}
+	if len(filter.Tags) > 0 {
+		parts = append(parts, "tagged "+strings.Join(filter.Tags, ", "))
+	}
+	labels := make(map[string]string, len(form.Fields))
+	for _, field := range form.Fields {
+		labels[field.ID] = field.Label
+	}
+	for _, f := range filter.Fields {
+		label := labels[f.FieldID]
+		if label == "" {
+			label = f.FieldID
+		}
+		switch f.Op {
+		case models.FieldOpContains:
+			parts = append(parts, fmt.Sprintf("%s contains %q", label, f.Value))
+		case models.FieldOpRange:
+			parts = append(parts, fmt.Sprintf("%s between %s and %s", label, orAny(f.Min), orAny(f.Max)))
+		default:
+			parts = append(parts, fmt.Sprintf("%s is %q", label, f.Value))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func orAny(bound string) string {
+	if bound == "" {
+		return "any"
+	}
+	return bound
+}
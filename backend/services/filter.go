@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"form-builder-backend/models"
+	"form-builder-backend/useragent"
+)
+
+// Device classes a response can be filtered by
+var deviceClasses = []string{useragent.DeviceDesktop, useragent.DeviceMobile, useragent.DeviceTablet, useragent.DeviceOther}
+
+// ResponseFilterMatch builds the conditions a response must meet to pass
+// filter, to be combined with a {formId} match. Dates are read in loc.
+func ResponseFilterMatch(form *models.Form, filter models.ResponseFilter, loc *time.Location) (bson.M, error) {
+	criteria, err := ParseResponseFilter(form, filter, loc)
+	if err != nil {
+		return nil, err
+	}
+	return CriteriaMatch(criteria), nil
+}
+
+// ParseResponseFilter checks filter against form and parses its dates, read
+// in loc, and numeric bounds
+func ParseResponseFilter(form *models.Form, filter models.ResponseFilter, loc *time.Location) (models.ResponseCriteria, error) {
+	criteria := models.ResponseCriteria{Tags: filter.Tags}
+
+	if filter.From != "" {
+		from, err := parseTrendTime(filter.From, loc, false)
+		if err != nil {
+			return criteria, err
+		}
+		criteria.From = from
+	}
+	if filter.To != "" {
+		to, err := parseTrendTime(filter.To, loc, true)
+		if err != nil {
+			return criteria, err
+		}
+		criteria.To = to
+	}
+
+	if filter.Device != "" {
+		for _, class := range deviceClasses {
+			if strings.EqualFold(filter.Device, class) {
+				criteria.Device = class
+			}
+		}
+		if criteria.Device == "" {
+			return criteria, fmt.Errorf("%w: device must be one of %s", ErrInvalidQuery, strings.Join(deviceClasses, ", "))
+		}
+	}
+
+	if filter.Status != "" {
+		for _, status := range models.ReviewStatuses {
+			if filter.Status == status {
+				criteria.Status = status
+			}
+		}
+		if criteria.Status == "" {
+			return criteria, fmt.Errorf("%w: status must be one of %s", ErrInvalidQuery, strings.Join(models.ReviewStatuses, ", "))
+		}
+	}
+	criteria.Assignee = strings.TrimSpace(filter.Assignee)
+
+	for _, f := range filter.Fields {
+		field, ok := findField(form.Fields, f.FieldID)
+		if !ok || !isQueryableFieldID(f.FieldID) {
+			return criteria, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, f.FieldID)
+		}
+		// PII answers may be encrypted, and ciphertext matches nothing
+		// meaningful
+		if field.PII {
+			return criteria, fmt.Errorf("%w: can't filter by PII field %q", ErrInvalidQuery, f.FieldID)
+		}
+		criterion := models.FieldCriterion{FieldID: f.FieldID, Op: f.Op, Value: f.Value}
+		switch f.Op {
+		case "":
+			criterion.Op = models.FieldOpEquals
+		case models.FieldOpEquals, models.FieldOpContains:
+		case models.FieldOpRange:
+			var err error
+			if criterion.Min, err = parseBound(f.Min); err != nil {
+				return criteria, err
+			}
+			if criterion.Max, err = parseBound(f.Max); err != nil {
+				return criteria, err
+			}
+			if criterion.Min == nil && criterion.Max == nil {
+				return criteria, fmt.Errorf("%w: range filter on %q needs a min or max", ErrInvalidQuery, f.FieldID)
+			}
+		default:
+			return criteria, fmt.Errorf("%w: unknown field filter %q", ErrInvalidQuery, f.Op)
+		}
+		criteria.Fields = append(criteria.Fields, criterion)
+	}
+
+	return criteria, nil
+}
+
+// parseBound parses an optional range bound
+func parseBound(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(n) {
+		return nil, fmt.Errorf("%w: %q is not a number", ErrInvalidQuery, value)
+	}
+	return &n, nil
+}
+
+// CriteriaMatch builds the Mongo conditions for criteria, nil when it
+// matches every response. MemoryStore.ListResponses in the store package
+// applies the same rules in Go.
+func CriteriaMatch(criteria models.ResponseCriteria) bson.M {
+	var conditions bson.A
+
+	createdAt := bson.M{}
+	if !criteria.From.IsZero() {
+		createdAt["$gte"] = criteria.From
+	}
+	if !criteria.To.IsZero() {
+		createdAt["$lt"] = criteria.To
+	}
+	if len(createdAt) > 0 {
+		conditions = append(conditions, bson.M{"createdAt": createdAt})
+	}
+
+	if criteria.Device != "" {
+		conditions = append(conditions, bson.M{"device": criteria.Device})
+	}
+
+	if len(criteria.Tags) > 0 {
+		conditions = append(conditions, bson.M{"tags": bson.M{"$all": criteria.Tags}})
+	}
+
+	switch criteria.Status {
+	case "":
+	case models.ReviewNew:
+		conditions = append(conditions, bson.M{"status": bson.M{"$in": bson.A{nil, models.ReviewNew}}})
+	default:
+		conditions = append(conditions, bson.M{"status": criteria.Status})
+	}
+
+	switch criteria.Assignee {
+	case "":
+	case models.UnassignedFilter:
+		conditions = append(conditions, bson.M{"assignee": bson.M{"$in": bson.A{nil, ""}}})
+	default:
+		conditions = append(conditions, bson.M{"assignee": criteria.Assignee})
+	}
+
+	for _, f := range criteria.Fields {
+		var condition bson.M
+		switch f.Op {
+		case models.FieldOpContains:
+			condition = bson.M{"$regex": regexp.QuoteMeta(f.Value), "$options": "i"}
+		case models.FieldOpRange:
+			// Bounds only compare with numbers, so text answers never match
+			condition = bson.M{}
+			if f.Min != nil {
+				condition["$gte"] = *f.Min
+			}
+			if f.Max != nil {
+				condition["$lte"] = *f.Max
+			}
+		default:
+			condition = bson.M{"$in": filterValues(f.Value)}
+		}
+		conditions = append(conditions, bson.M{"data." + f.FieldID: condition})
+	}
+
+	switch len(conditions) {
+	case 0:
+		return nil
+	case 1:
+		return conditions[0].(bson.M)
+	default:
+		return bson.M{"$and": conditions}
+	}
+}
+
+// filterValues lists the stored values a filter value can match. Query
+// strings are untyped, so "5" also matches the number 5 and "true" the boolean.
+func filterValues(value string) bson.A {
+	values := bson.A{value}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		values = append(values, n)
+	}
+	if value == "true" || value == "false" {
+		values = append(values, value == "true")
+	}
+	return values
+}
+
+// withFilter narrows a response match by the conditions from ResponseFilterMatch
+func withFilter(match, filter bson.M) bson.M {
+	if len(filter) == 0 {
+		return match
+	}
+	return bson.M{"$and": bson.A{match, filter}}
+}
+
+// resolveFilter combines the saved segment and ad-hoc filter of opts into
+// response conditions; nil means every response
+func resolveFilter(form *models.Form, opts AnalyticsOptions, loc *time.Location) (bson.M, error) {
+	var conditions bson.A
+
+	if opts.Segment != "" {
+		segment, ok := FindSegment(form, opts.Segment)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown segment %q", ErrInvalidQuery, opts.Segment)
+		}
+		match, err := ResponseFilterMatch(form, segment.Filter, loc)
+		if err != nil {
+			return nil, err
+		}
+		if match != nil {
+			conditions = append(conditions, match)
+		}
+	}
+
+	match, err := ResponseFilterMatch(form, opts.Filter, loc)
+	if err != nil {
+		return nil, err
+	}
+	if match != nil {
+		conditions = append(conditions, match)
+	}
+
+	switch len(conditions) {
+	case 0:
+		return nil, nil
+	case 1:
+		return conditions[0].(bson.M), nil
+	default:
+		return bson.M{"$and": conditions}, nil
+	}
+}
+
+// FindSegment looks up a saved segment by name
+func FindSegment(form *models.Form, name string) (models.Segment, bool) {
+	for _, segment := range form.Segments {
+		if segment.Name == name {
+			return segment, true
+		}
+	}
+	return models.Segment{}, false
+}
+
+// BackfillClientInfo stores the parsed device, browser, OS and bot flag on
+// responses submitted before they were recorded, so filters and the bot
+// exclusion cover them
+func (s *AnalyticsService) BackfillClientInfo(ctx context.Context, formID primitive.ObjectID) (int64, error) {
+	collection := s.db.Collection("responses")
+	missing := bson.M{"formId": formID, "os": bson.M{"$exists": false}}
+
+	cursor, err := collection.Aggregate(ctx, bson.A{
+		bson.M{"$match": missing},
+		bson.M{"$group": bson.M{"_id": "$userAgent"}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	userAgents := make(map[useragent.Info][]string)
+	for cursor.Next(ctx) {
+		var ua struct {
+			UserAgent string `bson:"_id"`
+		}
+		if err := cursor.Decode(&ua); err != nil {
+			continue
+		}
+		info := useragent.Parse(ua.UserAgent)
+		userAgents[info] = append(userAgents[info], ua.UserAgent)
+	}
+	if err := cursor.Err(); err != nil {
+		return 0, err
+	}
+
+	updated := int64(0)
+	for info, agents := range userAgents {
+		set := bson.M{"device": info.Device, "browser": info.Browser, "os": info.OS}
+		if info.Bot {
+			set["bot"] = true
+		}
+		result, err := collection.UpdateMany(ctx,
+			withFilter(missing, bson.M{"userAgent": bson.M{"$in": agents}}),
+			bson.M{"$set": set})
+		if err != nil {
+			return updated, err
+		}
+		updated += result.ModifiedCount
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/models"
+)
+
+// FunnelData shows how far respondents got through a form, in form order
+type FunnelData struct {
+	FormID      string            `json:"formId"`
+	Respondents int64             `json:"respondents"`
+	Completed   int64             `json:"completed"`
+	Fields      []FieldFunnelStep `json:"fields"`
+	Pages       []PageFunnelStep  `json:"pages"`
+}
+
+// FieldFunnelStep counts respondents that reached, answered and quit at a field
+type FieldFunnelStep struct {
+	FieldID     string  `json:"fieldId"`
+	FieldLabel  string  `json:"fieldLabel"`
+	Page        int     `json:"page"`
+	Reached     int64   `json:"reached"`
+	Answered    int64   `json:"answered"`
+	Abandoned   int64   `json:"abandoned"`
+	DropOffRate float64 `json:"dropOffRate"`
+}
+
+// PageFunnelStep counts respondents that reached, answered and quit on a page
+type PageFunnelStep struct {
+	Page        int     `json:"page"`
+	Reached     int64   `json:"reached"`
+	Answered    int64   `json:"answered"`
+	Abandoned   int64   `json:"abandoned"`
+	DropOffRate float64 `json:"dropOffRate"`
+}
+
+// funnelProgress is how far a single unfinished respondent got
+type funnelProgress struct {
+	furthest int
+	answered map[int]bool
+}
+
+// GetFormFunnel builds the per-field and per-page drop-off funnel of a form.
+// Submitted responses reach every field; unfinished respondents are taken
+// from partial responses and renderer sessions, merged by session ID.
+func (s *AnalyticsService) GetFormFunnel(formID string) (*FunnelData, error) {
+	objID, err := primitive.ObjectIDFromHex(formID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	var form models.Form
+	if err := s.db.Collection("forms").FindOne(ctx, bson.M{"_id": objID, "deletedAt": nil}).Decode(&form); err != nil {
+		return nil, err
+	}
+
+	fieldIndex := make(map[string]int, len(form.Fields))
+	for i, field := range form.Fields {
+		fieldIndex[field.ID] = i
+	}
+
+	completed, answeredCounts, pageAnswered, err := s.getCompletedFieldCounts(ctx, objID, form.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sessions that ended in a submission are already counted as completed
+	submittedSessions := make(map[string]bool)
+	sessionIDs, err := s.db.Collection("responses").Distinct(ctx, "sessionId", bson.M{"formId": objID})
+	if err != nil {
+		log.Printf("Error getting submitted sessions: %v", err)
+	}
+	for _, id := range sessionIDs {
+		if sid, ok := id.(string); ok && sid != "" {
+			submittedSessions[sid] = true
+		}
+	}
+
+	unfinished := make(map[string]*funnelProgress)
+	progressFor := func(key string) *funnelProgress {
+		p, ok := unfinished[key]
+		if !ok {
+			p = &funnelProgress{furthest: -1, answered: make(map[int]bool)}
+			unfinished[key] = p
+		}
+		return p
+	}
+
+	// Partial responses: every saved answer was both reached and answered
+	cursor, err := s.db.Collection("partial_responses").Find(ctx,
+		bson.M{"formId": objID, "completedAt": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"data": 1, "sessionId": 1, "resumeToken": 1}))
+	if err != nil {
+		return nil, err
+	}
+	for cursor.Next(ctx) {
+		var partial models.PartialResponse
+		if err := cursor.Decode(&partial); err != nil {
+			continue
+		}
+		if submittedSessions[partial.SessionID] {
+			continue
+		}
+
+		key := "partial:" + partial.ResumeToken
+		if partial.SessionID != "" {
+			key = "session:" + partial.SessionID
+		}
+		p := progressFor(key)
+		for fieldID, value := range partial.Data {
+			i, ok := fieldIndex[fieldID]
+			if !ok || isEmptyAnswer(value) {
+				continue
+			}
+			p.answered[i] = true
+			if i > p.furthest {
+				p.furthest = i
+			}
+		}
+	}
+	cursor.Close(ctx)
+
+	// Renderer sessions: focusing a field means it was reached
+	cursor, err = s.db.Collection("form_events").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"formId": objID, "type": models.EventTypeFieldFocus}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    "$sessionId",
+			"fields": bson.M{"$addToSet": "$fieldId"},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for cursor.Next(ctx) {
+		var session struct {
+			SessionID string   `bson:"_id"`
+			Fields    []string `bson:"fields"`
+		}
+		if err := cursor.Decode(&session); err != nil {
+			continue
+		}
+		if submittedSessions[session.SessionID] {
+			continue
+		}
+
+		p := progressFor("session:" + session.SessionID)
+		for _, fieldID := range session.Fields {
+			if i, ok := fieldIndex[fieldID]; ok && i > p.furthest {
+				p.furthest = i
+			}
+		}
+	}
+	cursor.Close(ctx)
+
+	funnel := &FunnelData{
+		FormID:    formID,
+		Completed: completed,
+		Fields:    make([]FieldFunnelStep, len(form.Fields)),
+		Pages:     []PageFunnelStep{},
+	}
+
+	for i, field := range form.Fields {
+		funnel.Fields[i] = FieldFunnelStep{
+			FieldID:    field.ID,
+			FieldLabel: field.Label,
+			Page:       fieldPage(field),
+			Reached:    completed,
+			Answered:   answeredCounts[field.ID],
+		}
+	}
+
+	for _, p := range unfinished {
+		if p.furthest < 0 {
+			continue // viewed but never interacted
+		}
+		funnel.Respondents++
+
+		for i := 0; i <= p.furthest; i++ {
+			funnel.Fields[i].Reached++
+		}
+		funnel.Fields[p.furthest].Abandoned++
+
+		pages := make(map[int]bool)
+		for i := range p.answered {
+			funnel.Fields[i].Answered++
+			pages[funnel.Fields[i].Page] = true
+		}
+		for page := range pages {
+			pageAnswered[page]++
+		}
+	}
+	funnel.Respondents += completed
+
+	// Pages follow the order in which they first appear in the form
+	for _, step := range funnel.Fields {
+		last := len(funnel.Pages) - 1
+		if last < 0 || funnel.Pages[last].Page != step.Page {
+			funnel.Pages = append(funnel.Pages, PageFunnelStep{
+				Page:     step.Page,
+				Reached:  step.Reached,
+				Answered: pageAnswered[step.Page],
+			})
+			last++
+		}
+		funnel.Pages[last].Abandoned += step.Abandoned
+	}
+
+	for i := range funnel.Fields {
+		funnel.Fields[i].DropOffRate = percentage(funnel.Fields[i].Abandoned, funnel.Fields[i].Reached)
+	}
+	for i := range funnel.Pages {
+		funnel.Pages[i].DropOffRate = percentage(funnel.Pages[i].Abandoned, funnel.Pages[i].Reached)
+	}
+
+	return funnel, nil
+}
+
+// getCompletedFieldCounts counts submitted responses and how many of them
+// answered each field and each page
+func (s *AnalyticsService) getCompletedFieldCounts(ctx context.Context, formID primitive.ObjectID, fields []models.FormField) (int64, map[string]int64, map[int]int64, error) {
+	total, err := s.db.Collection("responses").CountDocuments(ctx, humanResponses(formID))
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	pageBranches := bson.A{}
+	for _, field := range fields {
+		pageBranches = append(pageBranches, bson.M{
+			"case": bson.M{"$eq": bson.A{"$answers.k", field.ID}},
+			"then": fieldPage(field),
+		})
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: humanResponses(formID)}},
+		{{Key: "$project", Value: bson.M{"answers": bson.M{"$objectToArray": "$data"}}}},
+		{{Key: "$unwind", Value: "$answers"}},
+		{{Key: "$match", Value: bson.M{"answers.v": bson.M{"$nin": bson.A{nil, "", bson.A{}}}}}},
+	}
+	facets := bson.M{
+		"fields": bson.A{
+			bson.M{"$group": bson.M{"_id": "$answers.k", "count": bson.M{"$sum": 1}}},
+		},
+		"pages": bson.A{},
+	}
+	if len(pageBranches) > 0 {
+		facets["pages"] = bson.A{
+			bson.M{"$project": bson.M{"page": bson.M{"$switch": bson.M{"branches": pageBranches, "default": 0}}}},
+			bson.M{"$match": bson.M{"page": bson.M{"$gt": 0}}},
+			bson.M{"$group": bson.M{"_id": bson.M{"response": "$_id", "page": "$page"}}},
+			bson.M{"$group": bson.M{"_id": "$_id.page", "count": bson.M{"$sum": 1}}},
+		}
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: facets}})
+
+	cursor, err := s.db.Collection("responses").Aggregate(ctx, pipeline)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var result struct {
+		Fields []struct {
+			FieldID string `bson:"_id"`
+			Count   int64  `bson:"count"`
+		} `bson:"fields"`
+		Pages []struct {
+			Page  int   `bson:"_id"`
+			Count int64 `bson:"count"`
+		} `bson:"pages"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&result); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
+	fieldCounts := make(map[string]int64, len(result.Fields))
+	for _, f := range result.Fields {
+		fieldCounts[f.FieldID] = f.Count
+	}
+	pageCounts := make(map[int]int64, len(result.Pages))
+	for _, p := range result.Pages {
+		pageCounts[p.Page] = p.Count
+	}
+
+	return total, fieldCounts, pageCounts, nil
+}
+
+// fieldPage returns the 1-based page a field is shown on
+func fieldPage(field models.FormField) int {
+	if field.Page < 1 {
+		return 1
+	}
+	return field.Page
+}
+
+// isEmptyAnswer reports whether a stored answer counts as skipped
+func isEmptyAnswer(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case primitive.A:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
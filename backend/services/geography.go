@@ -0,0 +1,43 @@
+package services
+
+// unknownLocation is reported for responses that couldn't be geolocated
+const unknownLocation = "Unknown"
+
+// GeographyStats breaks responses down by where they were submitted from.
+// Countries are keyed by ISO code; regions by "<country>/<region>".
+type GeographyStats struct {
+	Countries map[string]int64 `json:"countries"`
+	Regions   map[string]int64 `json:"regions"`
+}
+
+func newGeographyStats() GeographyStats {
+	return GeographyStats{
+		Countries: make(map[string]int64),
+		Regions:   make(map[string]int64),
+	}
+}
+
+// regionKey identifies a region within its country
+func regionKey(country, region string) string {
+	return countryOrUnknown(country) + "/" + region
+}
+
+func countryOrUnknown(country string) string {
+	if country == "" {
+		return unknownLocation
+	}
+	return country
+}
+
+// FormatLocation renders a stored country and region for display, such as
+// "California, US"
+func FormatLocation(country, region string) string {
+	switch {
+	case country == "":
+		return unknownLocation
+	case region == "":
+		return country
+	default:
+		return region + ", " + country
+	}
+}
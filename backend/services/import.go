@@ -0,0 +1,548 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/models"
+	"form-builder-backend/search"
+	"form-builder-backend/useragent"
+)
+
+// Import formats
+const (
+	ImportCSV  = "csv"
+	ImportJSON = "json" // an array of objects, or newline-delimited objects
+)
+
+// Import mapping targets other than field IDs
+const (
+	ImportCreatedAt = "$createdAt"
+	ImportIPAddress = "$ipAddress"
+	ImportUserAgent = "$userAgent"
+	ImportDevice    = "$device"
+)
+
+// MaxImportRows is the most rows one import reads
+const MaxImportRows = 100000
+
+// maxImportErrors is the most row errors an import reports; later failures
+// are only counted
+const maxImportErrors = 100
+
+// importBatchSize is the number of responses inserted at a time
+const importBatchSize = 500
+
+// importTimeLayouts are the timestamp formats an import accepts, besides
+// RFC 3339 and Unix seconds
+var importTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+	"01/02/2006",
+}
+
+// ImportOptions controls how rows become responses
+type ImportOptions struct {
+	// Mapping maps CSV columns or JSON keys to field IDs or to one of the
+	// Import* targets; mapping a column to "" skips it. Columns it doesn't
+	// mention are matched to fields by ID or label, and to the metadata
+	// columns of an export by their headings.
+	Mapping map[string]string
+	// Location timestamps without a zone are read in
+	Location *time.Location
+	// DryRun validates every row without saving any
+	DryRun bool
+}
+
+// ImportRowError reports why a row wasn't imported. Rows are numbered from
+// 1, not counting a CSV header.
+type ImportRowError struct {
+	Row   int64  `json:"row"`
+	Error string `json:"error"`
+}
+
+// ImportResult summarizes an import
+type ImportResult struct {
+	Rows      int64            `json:"rows"`
+	Imported  int64            `json:"imported"`
+	Failed    int64            `json:"failed"`
+	Errors    []ImportRowError `json:"errors"` // the first maxImportErrors failures
+	DryRun    bool             `json:"dryRun,omitempty"`
+	Truncated bool             `json:"truncated,omitempty"` // rows past MaxImportRows were left out
+	Warning   string           `json:"warning,omitempty"`
+}
+
+// ImportSource reads the rows of an import file
+type ImportSource interface {
+	// Next returns the next row keyed by column, or io.EOF after the last
+	Next() (map[string]interface{}, error)
+}
+
+// NewImportSource reads rows in format from r
+func NewImportSource(format string, r io.Reader) (ImportSource, error) {
+	switch format {
+	case ImportCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				return nil, fmt.Errorf("%w: the file is empty", ErrInvalidQuery)
+			}
+			return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+		if len(header) > 0 {
+			header[0] = strings.TrimPrefix(header[0], "\ufeff")
+		}
+		return &csvImportSource{reader: reader, header: header}, nil
+	case ImportJSON:
+		buf := bufio.NewReader(r)
+		for {
+			b, err := buf.ReadByte()
+			if err == io.EOF {
+				return &jsonLinesImportSource{reader: buf}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if b == ' ' || b == '\t' || b == '\r' || b == '\n' {
+				continue
+			}
+			buf.UnreadByte()
+			if b != '[' {
+				return &jsonLinesImportSource{reader: buf}, nil
+			}
+			src := &jsonImportSource{dec: json.NewDecoder(buf)}
+			src.dec.Token()
+			return src, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: format must be csv or json", ErrInvalidQuery)
+}
+
+type csvImportSource struct {
+	reader *csv.Reader
+	header []string
+}
+
+// malformedRowError is returned by an ImportSource for a row it couldn't
+// parse but can read past
+type malformedRowError struct {
+	err error
+}
+
+func (e malformedRowError) Error() string {
+	return e.err.Error()
+}
+
+func (s *csvImportSource) Next() (map[string]interface{}, error) {
+	record, err := s.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, malformedRowError{err: parseErr.Err}
+		}
+		return nil, err
+	}
+	row := make(map[string]interface{}, len(record))
+	for i, value := range record {
+		if i < len(s.header) {
+			row[s.header[i]] = value
+		}
+	}
+	return row, nil
+}
+
+// jsonImportSource reads the elements of a JSON array. An element that
+// isn't an object is skipped, but the decoder can't find the next element
+// after a syntax error, so that ends the import.
+type jsonImportSource struct {
+	dec *json.Decoder
+}
+
+func (s *jsonImportSource) Next() (map[string]interface{}, error) {
+	if !s.dec.More() {
+		return nil, io.EOF
+	}
+	var row map[string]interface{}
+	if err := s.dec.Decode(&row); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, malformedRowError{err: fmt.Errorf("expected an object, got %s", typeErr.Value)}
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+	}
+	if row == nil {
+		return nil, malformedRowError{err: errors.New("expected an object, got null")}
+	}
+	return row, nil
+}
+
+// jsonLinesImportSource reads one object per line, so a line that isn't
+// valid JSON is skipped like a malformed CSV row
+type jsonLinesImportSource struct {
+	reader *bufio.Reader
+}
+
+func (s *jsonLinesImportSource) Next() (map[string]interface{}, error) {
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			continue
+		}
+		var row map[string]interface{}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return nil, malformedRowError{err: err}
+		}
+		if row == nil {
+			return nil, malformedRowError{err: errors.New("expected an object, got null")}
+		}
+		return row, nil
+	}
+}
+
+// importMapper resolves the target of each column of an import
+type importMapper struct {
+	mapping  map[string]string
+	fields   map[string]models.FormField
+	byLabel  map[string]string
+	resolved map[string]string
+	// locations holds the timezones named in export headings such as
+	// "Submitted At (Europe/Berlin)"
+	locations map[string]*time.Location
+}
+
+func newImportMapper(form *models.Form, mapping map[string]string) (*importMapper, error) {
+	m := &importMapper{
+		mapping:   mapping,
+		fields:    make(map[string]models.FormField, len(form.Fields)),
+		byLabel:   make(map[string]string, len(form.Fields)),
+		resolved:  make(map[string]string),
+		locations: make(map[string]*time.Location),
+	}
+	for _, field := range form.Fields {
+		m.fields[field.ID] = field
+		if label := strings.ToLower(strings.TrimSpace(field.Label)); label != "" {
+			if _, taken := m.byLabel[label]; !taken {
+				m.byLabel[label] = field.ID
+			}
+		}
+	}
+	for column, target := range mapping {
+		switch target {
+		case "", ImportCreatedAt, ImportIPAddress, ImportUserAgent, ImportDevice:
+		default:
+			if _, ok := m.fields[target]; !ok {
+				return nil, fmt.Errorf("%w: column %q is mapped to unknown field %q", ErrInvalidQuery, column, target)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *importMapper) target(column string) string {
+	if target, ok := m.mapping[column]; ok {
+		return target
+	}
+	if target, ok := m.resolved[column]; ok {
+		return target
+	}
+
+	key := strings.ToLower(strings.TrimSpace(column))
+	target := ""
+	switch {
+	case m.fields[column].ID != "":
+		target = column
+	case m.byLabel[key] != "":
+		target = m.byLabel[key]
+	case key == "createdat" || key == "submittedat" || strings.HasPrefix(key, "submitted at"):
+		target = ImportCreatedAt
+		if start, end := strings.Index(column, "("), strings.LastIndex(column, ")"); start >= 0 && end > start {
+			if loc, err := LoadLocation(column[start+1 : end]); err == nil {
+				m.locations[column] = loc
+			}
+		}
+	case key == "ipaddress" || key == "ip address":
+		target = ImportIPAddress
+	case key == "useragent" || key == "user agent":
+		target = ImportUserAgent
+	case key == "device":
+		target = ImportDevice
+	}
+	m.resolved[column] = target
+	return target
+}
+
+// maps reports whether any column of the mapping targets target
+func (m *importMapper) maps(target string) bool {
+	for _, t := range m.mapping {
+		if t == target {
+			return true
+		}
+	}
+	for _, t := range m.resolved {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportResponses reads rows from source into responses of form. Each row
+// is converted to its fields' types and checked with validate; rows that
+// fail are reported and skipped, and the rest are inserted in batches, each
+// of which is passed to inserted. Rows without a submission time column
+// are stamped with the time of the import. Rows past MaxImportRows are left
+// out and the result marked truncated.
+func (s *ResponseService) ImportResponses(ctx context.Context, form *models.Form, source ImportSource, opts ImportOptions, validate func(data map[string]interface{}) error, inserted func(batch []models.FormResponse)) (*ImportResult, error) {
+	mapper, err := newImportMapper(form, opts.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	result := &ImportResult{Errors: []ImportRowError{}, DryRun: opts.DryRun}
+	fail := func(row int64, err error) {
+		result.Failed++
+		if len(result.Errors) < maxImportErrors {
+			result.Errors = append(result.Errors, ImportRowError{Row: row, Error: err.Error()})
+		}
+	}
+
+	collection := s.db.Collection("responses")
+	batch := make([]models.FormResponse, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if !opts.DryRun {
+			docs := make([]interface{}, len(batch))
+			for i := range batch {
+				batch[i].ID = primitive.NewObjectID()
+				docs[i] = batch[i]
+			}
+			if _, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false)); err != nil {
+				return err
+			}
+			if inserted != nil {
+				inserted(batch)
+			}
+		}
+		result.Imported += int64(len(batch))
+		batch = make([]models.FormResponse, 0, importBatchSize)
+		return nil
+	}
+
+	importedAt := time.Now()
+	for {
+		row, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if result.Rows == MaxImportRows {
+			// The rows read so far may already be saved, so the rest are
+			// left out rather than failing the import
+			result.Truncated = true
+			result.Warning = fmt.Sprintf("Only the first %d rows were imported", MaxImportRows)
+			break
+		}
+		result.Rows++
+		if err != nil {
+			var malformed malformedRowError
+			if errors.As(err, &malformed) {
+				fail(result.Rows, err)
+				continue
+			}
+			return result, err
+		}
+
+		response, err := importResponse(form, mapper, row, loc)
+		if err == nil {
+			err = validate(response.Data)
+		}
+		if err != nil {
+			fail(result.Rows, err)
+			continue
+		}
+		if response.CreatedAt.IsZero() {
+			if mapper.maps(ImportCreatedAt) {
+				fail(result.Rows, errors.New("submission time is missing"))
+				continue
+			}
+			response.CreatedAt = importedAt
+		}
+
+		// Search text is rebuilt so encrypted answers are left out of it
+		if response.Data, response.Encryption, err = s.cipher.Encrypt(ctx, form, response.Data); err != nil {
+			return result, err
+		}
+		response.SearchText = search.Text(form, response.Data)
+		response.Rollup = RollupModes(form, response.Data)
+
+		batch = append(batch, response)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// importResponse converts one row to a response
+func importResponse(form *models.Form, mapper *importMapper, row map[string]interface{}, loc *time.Location) (models.FormResponse, error) {
+	response := models.FormResponse{
+		FormID: form.ID,
+		Data:   make(map[string]interface{}),
+		Source: models.ResponseSourceImport,
+	}
+	for column, raw := range row {
+		target := mapper.target(column)
+		switch target {
+		case "":
+		case ImportCreatedAt:
+			if str := strings.TrimSpace(toString(raw)); str != "" {
+				in := loc
+				if columnLoc := mapper.locations[column]; columnLoc != nil {
+					in = columnLoc
+				}
+				t, err := parseImportTime(str, in)
+				if err != nil {
+					return response, err
+				}
+				response.CreatedAt = t
+			}
+		case ImportIPAddress:
+			response.IPAddress = strings.TrimSpace(toString(raw))
+		case ImportUserAgent:
+			response.UserAgent = strings.TrimSpace(toString(raw))
+		case ImportDevice:
+			response.Device = strings.TrimSpace(toString(raw))
+		default:
+			field := mapper.fields[target]
+			value, err := importValue(field, raw)
+			if err != nil {
+				return response, err
+			}
+			if value != nil {
+				response.Data[field.ID] = value
+			}
+		}
+	}
+
+	if response.UserAgent != "" {
+		client := useragent.Parse(response.UserAgent)
+		if response.Device == "" {
+			response.Device = client.Device
+		}
+		response.Browser = client.Browser
+		response.OS = client.OS
+		response.Bot = client.Bot
+	}
+	response.SearchText = search.Text(form, response.Data)
+	return response, nil
+}
+
+// importValue converts an imported answer to the type a submission of the
+// field would have. Empty answers are nil. Multi-select answers may be
+// arrays, or text with the options separated by semicolons, as exported.
+func importValue(field models.FormField, raw interface{}) (interface{}, error) {
+	label := field.Label
+	if label == "" {
+		label = field.ID
+	}
+
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		if field.Type != "checkbox" {
+			return nil, fmt.Errorf("%s takes a single answer", label)
+		}
+		values := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if str := strings.TrimSpace(toString(item)); str != "" {
+				values = append(values, str)
+			}
+		}
+		if len(values) == 0 {
+			return nil, nil
+		}
+		return values, nil
+	case float64:
+		if isNumericField(field.Type) {
+			return v, nil
+		}
+	case string, bool:
+	default:
+		return nil, fmt.Errorf("%s has an unsupported value", label)
+	}
+
+	str := strings.TrimSpace(toString(raw))
+	if str == "" {
+		return nil, nil
+	}
+	switch {
+	case isNumericField(field.Type):
+		n, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", label)
+		}
+		return n, nil
+	case field.Type == "checkbox":
+		var values []interface{}
+		for _, option := range strings.Split(str, ";") {
+			if option = strings.TrimSpace(option); option != "" {
+				values = append(values, option)
+			}
+		}
+		return values, nil
+	}
+	return str, nil
+}
+
+// parseImportTime reads a timestamp in RFC 3339, Unix seconds or one of
+// importTimeLayouts in loc
+func parseImportTime(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid submission time %q", value)
+}
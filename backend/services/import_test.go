@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"form-builder-backend/models"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// readImportRows reads every row of source, recording malformed rows as nil
+func readImportRows(t *testing.T, source ImportSource) ([]map[string]interface{}, error) {
+	t.Helper()
+	var rows []map[string]interface{}
+	for {
+		row, err := source.Next()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			var malformed malformedRowError
+			if !errors.As(err, &malformed) {
+				return rows, err
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestJSONImportSkipsMalformedRows(t *testing.T) {
+	a := map[string]interface{}{"name": "a"}
+	b := map[string]interface{}{"name": "b"}
+
+	tests := []struct {
+		name string
+		file string
+		want []map[string]interface{}
+	}{
+		{"empty", "", nil},
+		{"ndjson", "{\"name\":\"a\"}\n{\"name\":\"b\"}\n", []map[string]interface{}{a, b}},
+		{"ndjson without final newline", "{\"name\":\"a\"}\r\n\n{\"name\":\"b\"}", []map[string]interface{}{a, b}},
+		{"ndjson bad line", "{\"name\":\"a\"}\n{\"name\":\n{\"name\":\"b\"}\n", []map[string]interface{}{a, nil, b}},
+		{"ndjson not an object", "{\"name\":\"a\"}\n[1]\nnull\n{\"name\":\"b\"}\n", []map[string]interface{}{a, nil, nil, b}},
+		{"array", "[{\"name\":\"a\"}, {\"name\":\"b\"}]", []map[string]interface{}{a, b}},
+		{"array not an object", "[{\"name\":\"a\"}, 7, null, {\"name\":\"b\"}]", []map[string]interface{}{a, nil, nil, b}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := NewImportSource(ImportJSON, strings.NewReader(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows, err := readImportRows(t, source)
+			if err != nil {
+				t.Fatalf("import stopped: %v", err)
+			}
+			if !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("rows = %v, want %v", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONImportArraySyntaxErrorStops(t *testing.T) {
+	source, err := NewImportSource(ImportJSON, strings.NewReader(`[{"name":"a"}, {"name":}, {"name":"b"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := readImportRows(t, source)
+	if !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("err = %v, want ErrInvalidQuery", err)
+	}
+	if len(rows) != 1 {
+		t.Errorf("read %d rows before the error, want 1", len(rows))
+	}
+}
+
+func TestImportStopsAtMaxImportRows(t *testing.T) {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := NewResponseService(client.Database("test"), nil)
+	form := &models.Form{Fields: []models.FormField{{ID: "name", Label: "Name", Type: "text"}}}
+	file := "name\n" + strings.Repeat("a\n", MaxImportRows+5)
+	source, err := NewImportSource(ImportCSV, strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run reads the file without saving it, so no server is needed
+	result, err := service.ImportResponses(context.Background(), form, source, ImportOptions{DryRun: true},
+		func(map[string]interface{}) error { return nil }, nil)
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if result.Rows != MaxImportRows || result.Imported != MaxImportRows {
+		t.Errorf("read %d rows and imported %d, want %d of each", result.Rows, result.Imported, MaxImportRows)
+	}
+	if !result.Truncated || result.Warning == "" {
+		t.Errorf("result = %+v, want it truncated with a warning", result)
+	}
+}
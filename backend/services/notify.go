@@ -0,0 +1,73 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// AlertNotifier delivers anomaly alerts outside the dashboard
+type AlertNotifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// LogNotifier writes alerts to the server log
+type LogNotifier struct{}
+
+// Notify logs the alert
+func (LogNotifier) Notify(ctx context.Context, alert Alert) error {
+	log.Printf("Submission %s on form %s (%q): %d in %s-%s, expected %.1f",
+		alert.Kind, alert.FormID, alert.FormTitle, alert.Observed,
+		alert.WindowStart.Format(time.RFC3339), alert.WindowEnd.Format(time.RFC3339), alert.Expected)
+	return nil
+}
+
+// WebhookNotifier POSTs alerts as JSON to a URL, such as a Slack or Teams
+// incoming webhook or an internal alerting endpoint
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier that posts to url with a short timeout
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify posts the alert, with a text summary for chat webhooks
+func (w *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(struct {
+		Text string `json:"text"`
+		Alert
+	}{
+		Text:  fmt.Sprintf("%s: %s (%d submissions, usually %.1f)", alert.FormTitle, alert.Message, alert.Observed, alert.Expected),
+		Alert: alert,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
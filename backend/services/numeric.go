@@ -0,0 +1,169 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Histogram bin bounds for AnalyticsOptions.HistogramBins
+const (
+	DefaultHistogramBins = 10
+	MaxHistogramBins     = 100
+)
+
+// numericPercentiles are the percentiles reported in NumericStats
+var numericPercentiles = []int{5, 10, 25, 50, 75, 90, 95, 99}
+
+// NumericStats summarizes the answers of a number or rating field
+type NumericStats struct {
+	Count       int64              `json:"count"`
+	Min         float64            `json:"min"`
+	Max         float64            `json:"max"`
+	Mean        float64            `json:"mean"`
+	Median      float64            `json:"median"`
+	StdDev      float64            `json:"stdDev"`
+	Percentiles map[string]float64 `json:"percentiles"`
+	Histogram   []HistogramBin     `json:"histogram"`
+}
+
+// HistogramBin counts the answers in [Min, Max); the last bin includes Max
+type HistogramBin struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Label string  `json:"label"`
+	Count int64   `json:"count"`
+}
+
+// numericValue is a distinct answer and how many times it was given
+type numericValue struct {
+	value float64
+	count int64
+}
+
+// isNumericField reports whether a field type holds numeric answers
+func isNumericField(fieldType string) bool {
+	return fieldType == "number" || fieldType == "rating"
+}
+
+// computeNumericStats derives exact statistics from per-value answer counts,
+// as kept in the rollups, so no raw responses have to be read. Keys that do
+// not parse as numbers are ignored.
+func computeNumericStats(valueCounts map[string]int64, bins int) *NumericStats {
+	values := make([]numericValue, 0, len(valueCounts))
+	for key, count := range valueCounts {
+		v, err := strconv.ParseFloat(key, 64)
+		if err != nil || count <= 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		values = append(values, numericValue{value: v, count: count})
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].value < values[j].value })
+
+	stats := &NumericStats{
+		Min:         values[0].value,
+		Max:         values[len(values)-1].value,
+		Percentiles: make(map[string]float64, len(numericPercentiles)),
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		stats.Count += v.count
+		sum += v.value * float64(v.count)
+	}
+	stats.Mean = sum / float64(stats.Count)
+
+	variance := 0.0
+	for _, v := range values {
+		d := v.value - stats.Mean
+		variance += d * d * float64(v.count)
+	}
+	stats.StdDev = math.Sqrt(variance / float64(stats.Count))
+
+	// cumulative[i] is the number of answers up to and including values[i]
+	cumulative := make([]int64, len(values))
+	running := int64(0)
+	for i, v := range values {
+		running += v.count
+		cumulative[i] = running
+	}
+	valueAt := func(rank int64) float64 {
+		i := sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > rank })
+		return values[i].value
+	}
+
+	// Percentiles interpolate linearly between the closest ranks
+	percentile := func(p float64) float64 {
+		rank := p * float64(stats.Count-1)
+		lower := int64(math.Floor(rank))
+		upper := int64(math.Ceil(rank))
+		lo, hi := valueAt(lower), valueAt(upper)
+		return lo + (hi-lo)*(rank-float64(lower))
+	}
+	for _, p := range numericPercentiles {
+		stats.Percentiles["p"+strconv.Itoa(p)] = percentile(float64(p) / 100)
+	}
+	stats.Median = stats.Percentiles["p50"]
+
+	stats.Histogram = buildHistogram(values, stats.Min, stats.Max, bins)
+	return stats
+}
+
+// buildHistogram bins sorted values. Integer answers that span no more than
+// bins distinct values, like star ratings, get one bin per value; anything
+// else gets bins of equal width.
+func buildHistogram(values []numericValue, min, max float64, bins int) []HistogramBin {
+	if bins < 1 {
+		bins = DefaultHistogramBins
+	}
+
+	integers := true
+	for _, v := range values {
+		if v.value != math.Trunc(v.value) {
+			integers = false
+			break
+		}
+	}
+
+	if integers && max-min+1 <= float64(bins) {
+		histogram := make([]HistogramBin, 0, int(max-min)+1)
+		for n := min; n <= max; n++ {
+			histogram = append(histogram, HistogramBin{Min: n, Max: n + 1, Label: formatNumber(n)})
+		}
+		for _, v := range values {
+			histogram[int(v.value-min)].Count += v.count
+		}
+		return histogram
+	}
+
+	if min == max {
+		return []HistogramBin{{Min: min, Max: max, Label: formatNumber(min), Count: values[0].count}}
+	}
+
+	width := (max - min) / float64(bins)
+	histogram := make([]HistogramBin, bins)
+	for i := range histogram {
+		lo := min + width*float64(i)
+		hi := min + width*float64(i+1)
+		if i == bins-1 {
+			hi = max
+		}
+		histogram[i] = HistogramBin{Min: lo, Max: hi, Label: formatNumber(lo) + "–" + formatNumber(hi)}
+	}
+	for _, v := range values {
+		i := int((v.value - min) / width)
+		if i >= bins {
+			i = bins - 1
+		}
+		histogram[i].Count += v.count
+	}
+	return histogram
+}
+
+// formatNumber renders a number without trailing zeros, as used for value keys
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,428 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/envelope"
+	"form-builder-backend/models"
+	"form-builder-backend/search"
+)
+
+// Response listing page sizes
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// ResponseService lists and manages stored responses
+type ResponseService struct {
+	db     *mongo.Database
+	cipher *FieldCipher // nil when field encryption is off
+}
+
+// NewResponseService creates a new response service. cipher encrypts the
+// answers to PII fields of imported responses and decrypts them for
+// exports; it may be nil.
+func NewResponseService(db *mongo.Database, cipher *FieldCipher) *ResponseService {
+	return &ResponseService{db: db, cipher: cipher}
+}
+
+// NewResponseListQuery checks a listing's filter and sort against form. sort
+// is "createdAt" (the default) or a field ID, and order is "asc" or "desc"
+// (the default).
+func NewResponseListQuery(form *models.Form, filter models.ResponseFilter, sort, order string, loc *time.Location) (models.ResponseListQuery, error) {
+	query := models.ResponseListQuery{Sort: sort, Descending: true}
+
+	criteria, err := ParseResponseFilter(form, filter, loc)
+	if err != nil {
+		return query, err
+	}
+	query.Criteria = criteria
+
+	switch strings.ToLower(order) {
+	case "", "desc":
+	case "asc":
+		query.Descending = false
+	default:
+		return query, fmt.Errorf("%w: order must be asc or desc", ErrInvalidQuery)
+	}
+
+	if query.Sort == "" {
+		query.Sort = models.SortByCreatedAt
+	}
+	if query.Sort != models.SortByCreatedAt {
+		field, ok := findField(form.Fields, query.Sort)
+		if !ok || !isQueryableFieldID(query.Sort) {
+			return query, fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, query.Sort)
+		}
+		// Arrays sort by their smallest or largest element, which a
+		// cursor can't resume from
+		if field.Type == "checkbox" {
+			return query, fmt.Errorf("%w: can't sort by multi-select field %q", ErrInvalidQuery, query.Sort)
+		}
+		if field.PII {
+			return query, fmt.Errorf("%w: can't sort by PII field %q", ErrInvalidQuery, query.Sort)
+		}
+	}
+
+	return query, nil
+}
+
+// ListResponses returns one page of the responses of a form that match
+// query, and how many match in total. Pages are read with a keyset on the
+// sort value and ID, so they stay consistent while responses arrive.
+func (s *ResponseService) ListResponses(ctx context.Context, form *models.Form, query models.ResponseListQuery) (*models.ResponsePage, error) {
+	collection := s.db.Collection("responses")
+	match := ResponseMatch(form.ID, query.Criteria, query.Search)
+
+	var after *models.ResponseCursor
+	if query.Cursor != "" {
+		cursor, err := models.DecodeResponseCursor(query.Cursor, query)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
+		after = &cursor
+	}
+
+	total, err := collection.CountDocuments(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	} else if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	results, err := findSorted(ctx, collection, match, query, after, int64(limit+1))
+	if err != nil {
+		return nil, err
+	}
+	defer results.Close(ctx)
+
+	responses := []models.FormResponse{}
+	if err := results.All(ctx, &responses); err != nil {
+		return nil, err
+	}
+
+	page := &models.ResponsePage{Total: total}
+	if len(responses) > limit {
+		responses = responses[:limit]
+		page.HasMore = true
+		page.NextCursor = NextResponseCursor(query, responses[limit-1]).Encode()
+	}
+	page.Responses = responses
+	if len(query.Search) > 0 {
+		page.Highlights = make(map[string][]models.Snippet, len(responses))
+		for _, response := range responses {
+			page.Highlights[response.ID.Hex()] = search.Highlight(form, response.Data, query.Search)
+		}
+	}
+	return page, nil
+}
+
+// findSorted finds the responses selected by match in the query's order,
+// starting after the cursor position when there is one. A limit of 0 returns
+// them all.
+func findSorted(ctx context.Context, collection *mongo.Collection, match bson.M, query models.ResponseListQuery, after *models.ResponseCursor, limit int64) (*mongo.Cursor, error) {
+	order, compare := 1, "$gt"
+	if query.Descending {
+		order, compare = -1, "$lt"
+	}
+
+	if query.Sort == models.SortByCreatedAt {
+		if after != nil {
+			createdAt, ok := after.Value.(primitive.DateTime)
+			if !ok {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, models.ErrInvalidCursor)
+			}
+			match = withFilter(match, bson.M{"$or": bson.A{
+				bson.M{"createdAt": bson.M{compare: createdAt}},
+				bson.M{"createdAt": createdAt, "_id": bson.M{compare: after.ID}},
+			}})
+		}
+		return collection.Find(ctx, match, options.Find().
+			SetSort(bson.D{{Key: "createdAt", Value: order}, {Key: "_id", Value: order}}).
+			SetLimit(limit))
+	}
+
+	// Missing answers sort as null. $expr compares across types in the
+	// same order $sort uses, unlike query operators.
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$addFields": bson.M{"_sort": bson.M{"$ifNull": bson.A{"$data." + query.Sort, nil}}}},
+	}
+	if after != nil {
+		pipeline = append(pipeline, bson.M{"$match": bson.M{"$expr": bson.M{"$or": bson.A{
+			bson.M{compare: bson.A{"$_sort", after.Value}},
+			bson.M{"$and": bson.A{
+				bson.M{"$eq": bson.A{"$_sort", after.Value}},
+				bson.M{compare: bson.A{"$_id", after.ID}},
+			}},
+		}}}})
+	}
+	pipeline = append(pipeline, bson.M{"$sort": bson.D{{Key: "_sort", Value: order}, {Key: "_id", Value: order}}})
+	if limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": limit})
+	}
+	pipeline = append(pipeline, bson.M{"$project": bson.M{"_sort": 0}})
+	return collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+}
+
+// ResponseMatch selects the responses of a form that meet criteria and
+// contain every search term
+func ResponseMatch(formID primitive.ObjectID, criteria models.ResponseCriteria, terms []string) bson.M {
+	match := withFilter(bson.M{"formId": formID}, CriteriaMatch(criteria))
+	if len(terms) > 0 {
+		match = withFilter(match, bson.M{"$text": bson.M{"$search": search.MongoSearch(terms)}})
+	}
+	return match
+}
+
+// deleteBatchSize is how many responses DeleteResponses holds and deletes
+// at a time
+const deleteBatchSize = 500
+
+// DeleteResponses deletes the responses selected by match a batch at a time,
+// calling deleted with each batch once it is gone so their rollups can be
+// updated and dashboards told. It returns how many were deleted.
+func (s *ResponseService) DeleteResponses(ctx context.Context, match bson.M, deleted func(batch []models.FormResponse)) (int64, error) {
+	collection := s.db.Collection("responses")
+	cursor, err := collection.Find(ctx, match, options.Find().SetBatchSize(deleteBatchSize))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	total := int64(0)
+	batch := make([]models.FormResponse, 0, deleteBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		ids := make([]primitive.ObjectID, len(batch))
+		for i, response := range batch {
+			ids[i] = response.ID
+		}
+		if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+			return err
+		}
+		total += int64(len(batch))
+		if deleted != nil {
+			deleted(batch)
+		}
+		batch = make([]models.FormResponse, 0, deleteBatchSize)
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var response models.FormResponse
+		if err := cursor.Decode(&response); err != nil {
+			return total, err
+		}
+		batch = append(batch, response)
+		if len(batch) == deleteBatchSize {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return total, err
+	}
+	return total, flush()
+}
+
+// BackfillEncryption encrypts the answers to a form's PII fields that were
+// stored in plain text, before a key was configured or the field was marked
+// PII, in its responses and partial responses. It does nothing when field
+// encryption is off.
+func (s *ResponseService) BackfillEncryption(ctx context.Context, formID primitive.ObjectID) (int64, error) {
+	if s.cipher == nil {
+		return 0, nil
+	}
+	var form models.Form
+	if err := s.db.Collection("forms").FindOne(ctx, bson.M{"_id": formID}).Decode(&form); err != nil {
+		return 0, err
+	}
+	plain := plainPIIAnswers(&form)
+	if len(plain) == 0 {
+		return 0, nil
+	}
+
+	updated := int64(0)
+	for _, name := range []string{"responses", "partial_responses"} {
+		n, err := s.encryptStored(ctx, s.db.Collection(name), &form, bson.M{"formId": formID, "$or": plain}, name == "responses")
+		updated += n
+		if err != nil {
+			return updated, err
+		}
+	}
+	return updated, nil
+}
+
+// plainPIIAnswers matches the records holding a plain text answer to one of
+// the form's PII fields
+func plainPIIAnswers(form *models.Form) bson.A {
+	plain := bson.A{}
+	for id := range piiFields(form) {
+		plain = append(plain, bson.M{"data." + id: bson.M{
+			"$exists": true,
+			"$not":    primitive.Regex{Pattern: envelope.SealedPattern},
+		}})
+	}
+	return plain
+}
+
+// encryptStored encrypts the plain text PII answers of the records matched
+// by match. Responses also get their search text and rollup modes updated,
+// since neither may hold an encrypted answer's content.
+func (s *ResponseService) encryptStored(ctx context.Context, collection *mongo.Collection, form *models.Form, match bson.M, responses bool) (int64, error) {
+	cursor, err := collection.Find(ctx, match,
+		options.Find().SetProjection(bson.M{"data": 1, "encryption": 1, "rollup": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	const batchSize = 500
+	updated := int64(0)
+	writes := make([]mongo.WriteModel, 0, batchSize)
+	flush := func() error {
+		if len(writes) == 0 {
+			return nil
+		}
+		result, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+		updated += result.ModifiedCount
+		writes = writes[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var record models.FormResponse
+		if err := cursor.Decode(&record); err != nil {
+			continue
+		}
+		data, key, err := s.cipher.EncryptWith(ctx, form, record.Data, record.Encryption)
+		if err != nil {
+			return updated, err
+		}
+		set := bson.M{}
+		for id, value := range data {
+			if envelope.IsSealed(value) && !envelope.IsSealed(record.Data[id]) {
+				set["data."+id] = value
+				if responses && record.Rollup != nil {
+					set["rollup."+rollupKey(id)] = models.RollupAnswered
+				}
+			}
+		}
+		if len(set) == 0 {
+			// Only empty answers matched
+			continue
+		}
+		// A record saved again since it was read keeps its new answers
+		filter := bson.M{"_id": record.ID}
+		if record.Encryption == nil {
+			filter["encryption"] = bson.M{"$exists": false}
+			set["encryption"] = key
+		}
+		for id := range set {
+			if strings.HasPrefix(id, "data.") {
+				filter[id] = record.Data[strings.TrimPrefix(id, "data.")]
+			}
+		}
+		if responses {
+			set["searchText"] = search.Text(form, data)
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(bson.M{"$set": set}))
+		if len(writes) == batchSize {
+			if err := flush(); err != nil {
+				return updated, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return updated, err
+	}
+	return updated, flush()
+}
+
+// NextResponseCursor returns the cursor that resumes a listing after last
+func NextResponseCursor(query models.ResponseListQuery, last models.FormResponse) models.ResponseCursor {
+	cursor := models.ResponseCursor{Sort: query.Sort, Descending: query.Descending, ID: last.ID}
+	if query.Sort == models.SortByCreatedAt {
+		cursor.Value = last.CreatedAt
+	} else {
+		cursor.Value = last.Data[query.Sort]
+	}
+	return cursor
+}
+
+// BackfillSearchText stores the full-text search field on responses submitted
+// before it was recorded, so searches cover them. Responses with a plain text
+// answer to a PII field are indexed again too, since it may have been in
+// their search text before the field was marked PII.
+func (s *ResponseService) BackfillSearchText(ctx context.Context, formID primitive.ObjectID) (int64, error) {
+	var form models.Form
+	if err := s.db.Collection("forms").FindOne(ctx, bson.M{"_id": formID}).Decode(&form); err != nil {
+		return 0, err
+	}
+	stale := append(bson.A{bson.M{"searchText": bson.M{"$exists": false}}}, plainPIIAnswers(&form)...)
+
+	collection := s.db.Collection("responses")
+	cursor, err := collection.Find(ctx,
+		bson.M{"formId": formID, "$or": stale},
+		options.Find().SetProjection(bson.M{"data": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	const batchSize = 500
+	updated := int64(0)
+	writes := make([]mongo.WriteModel, 0, batchSize)
+	flush := func() error {
+		if len(writes) == 0 {
+			return nil
+		}
+		result, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return err
+		}
+		updated += result.ModifiedCount
+		writes = writes[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var response models.FormResponse
+		if err := cursor.Decode(&response); err != nil {
+			continue
+		}
+		// Answers with no text store "" so they aren't revisited
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": response.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"searchText": search.Text(&form, response.Data)}}))
+		if len(writes) == batchSize {
+			if err := flush(); err != nil {
+				return updated, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return updated, err
+	}
+	return updated, flush()
+}
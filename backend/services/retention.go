@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/models"
+	"form-builder-backend/search"
+)
+
+// RetentionConfig tunes the retention worker
+type RetentionConfig struct {
+	// Interval between runs
+	Interval time.Duration
+	// BatchSize is how many responses are anonymized per write
+	BatchSize int64
+}
+
+// DefaultRetentionConfig returns the default retention settings
+func DefaultRetentionConfig() RetentionConfig {
+	return RetentionConfig{
+		Interval:  time.Hour,
+		BatchSize: 500,
+	}
+}
+
+// ValidateRetention checks a form's retention policy
+func ValidateRetention(policy *models.RetentionPolicy) error {
+	if policy.Days < 1 {
+		return fmt.Errorf("%w: retention days must be at least 1", ErrInvalidQuery)
+	}
+	if policy.Action != models.RetentionDelete && policy.Action != models.RetentionAnonymize {
+		return fmt.Errorf("%w: retention action must be delete or anonymize", ErrInvalidQuery)
+	}
+	return nil
+}
+
+// RetentionService enforces the forms' retention policies, deleting or
+// anonymizing responses once they are older than the policy allows, and
+// keeps an audit record of every run that changed something
+type RetentionService struct {
+	db        *mongo.Database
+	responses *ResponseService
+	analytics *AnalyticsService
+	config    RetentionConfig
+	deleted   func(form *models.Form, batch []models.FormResponse)
+	handlers  []func(models.RetentionResult)
+}
+
+// NewRetentionService creates a retention service. deleted is called with
+// each batch of responses a run deletes, after their rollups are updated,
+// and each handler for every form whose responses a run changed.
+func NewRetentionService(db *mongo.Database, responses *ResponseService, analytics *AnalyticsService, config RetentionConfig, deleted func(form *models.Form, batch []models.FormResponse), handlers ...func(models.RetentionResult)) *RetentionService {
+	return &RetentionService{
+		db:        db,
+		responses: responses,
+		analytics: analytics,
+		config:    config,
+		deleted:   deleted,
+		handlers:  handlers,
+	}
+}
+
+// EnsureIndexes creates the index for listing a form's audit records
+func (s *RetentionService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.db.Collection("retention_runs").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "forms.formId", Value: 1}, {Key: "startedAt", Value: -1}},
+	})
+	return err
+}
+
+// Run enforces the policies every Interval until ctx is done
+func (s *RetentionService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if _, err := s.Enforce(ctx, now); err != nil {
+				log.Printf("Error enforcing retention policies: %v", err)
+			}
+		}
+	}
+}
+
+// Enforce applies every form's retention policy as of now and records the
+// run, with only the forms it changed or failed on. A run that did nothing
+// leaves no record. A form that fails is retried next run.
+func (s *RetentionService) Enforce(ctx context.Context, now time.Time) (*models.RetentionRun, error) {
+	cursor, err := s.db.Collection("forms").Find(ctx, bson.M{
+		"retention.days": bson.M{"$gt": 0},
+		"deletedAt":      nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var forms []models.Form
+	if err := cursor.All(ctx, &forms); err != nil {
+		return nil, err
+	}
+
+	run := &models.RetentionRun{StartedAt: now, Forms: []models.RetentionResult{}}
+	for i := range forms {
+		result := s.enforceForm(ctx, &forms[i], now)
+		if result.Error != "" {
+			log.Printf("Error enforcing retention for form %s: %s", result.FormID.Hex(), result.Error)
+		}
+		changed := result.Deleted > 0 || result.Anonymized > 0 || result.PartialsDeleted > 0
+		if changed {
+			for _, handle := range s.handlers {
+				handle(result)
+			}
+		}
+		if changed || result.Error != "" {
+			run.Forms = append(run.Forms, result)
+		}
+	}
+	run.CompletedAt = time.Now()
+	if len(run.Forms) == 0 {
+		return run, nil
+	}
+
+	inserted, err := s.db.Collection("retention_runs").InsertOne(ctx, run)
+	if err != nil {
+		return run, err
+	}
+	run.ID = inserted.InsertedID.(primitive.ObjectID)
+	return run, nil
+}
+
+// Runs returns the most recent audit records of a form, each with only that
+// form's result
+func (s *RetentionService) Runs(ctx context.Context, formID primitive.ObjectID, limit int64) ([]models.RetentionRun, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "startedAt", Value: -1}}).
+		SetLimit(limit).
+		SetProjection(bson.M{"startedAt": 1, "completedAt": 1, "forms.$": 1})
+	cursor, err := s.db.Collection("retention_runs").Find(ctx, bson.M{"forms.formId": formID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	runs := []models.RetentionRun{}
+	if err := cursor.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (s *RetentionService) enforceForm(ctx context.Context, form *models.Form, now time.Time) models.RetentionResult {
+	policy := form.Retention
+	result := models.RetentionResult{
+		FormID: form.ID,
+		Action: policy.Action,
+		Days:   policy.Days,
+		Cutoff: now.AddDate(0, 0, -policy.Days),
+	}
+
+	var err error
+	if policy.Action == models.RetentionAnonymize {
+		result.Anonymized, err = s.anonymize(ctx, form, result.Cutoff)
+	} else {
+		result.Deleted, err = s.delete(ctx, form, result.Cutoff)
+	}
+	if err == nil {
+		// Unfinished submissions hold answers too, and are never anonymized
+		var deleted *mongo.DeleteResult
+		deleted, err = s.db.Collection("partial_responses").DeleteMany(ctx, bson.M{
+			"formId":    form.ID,
+			"updatedAt": bson.M{"$lt": result.Cutoff},
+		})
+		if err == nil {
+			result.PartialsDeleted = deleted.DeletedCount
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// nextBatch loads up to BatchSize of a form's responses matching match
+func (s *RetentionService) nextBatch(ctx context.Context, match bson.M) ([]models.FormResponse, error) {
+	cursor, err := s.db.Collection("responses").Find(ctx, match,
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(s.config.BatchSize))
+	if err != nil {
+		return nil, err
+	}
+	var batch []models.FormResponse
+	err = cursor.All(ctx, &batch)
+	return batch, err
+}
+
+// delete removes the form's responses submitted before cutoff
+func (s *RetentionService) delete(ctx context.Context, form *models.Form, cutoff time.Time) (int64, error) {
+	match := bson.M{"formId": form.ID, "createdAt": bson.M{"$lt": cutoff}}
+	return s.responses.DeleteResponses(ctx, match, func(batch []models.FormResponse) {
+		if err := s.analytics.RemoveResponses(ctx, form, batch); err != nil {
+			log.Printf("Error updating analytics rollups: %v", err)
+		}
+		if s.deleted != nil {
+			s.deleted(form, batch)
+		}
+	})
+}
+
+// anonymize strips the IP address, user agent, referrer, campaign, reviewer
+// notes and PII answers from the form's responses submitted before cutoff.
+// Their other answers, device and location stay in the analytics.
+func (s *RetentionService) anonymize(ctx context.Context, form *models.Form, cutoff time.Time) (int64, error) {
+	var piiFields []string
+	for _, field := range form.Fields {
+		if field.PII {
+			piiFields = append(piiFields, field.ID)
+		}
+	}
+
+	match := bson.M{"formId": form.ID, "createdAt": bson.M{"$lt": cutoff}, "anonymizedAt": nil}
+	anonymized := int64(0)
+	for {
+		batch, err := s.nextBatch(ctx, match)
+		if err != nil || len(batch) == 0 {
+			return anonymized, err
+		}
+
+		now := time.Now()
+		writes := make([]mongo.WriteModel, len(batch))
+		for i, response := range batch {
+			data := make(map[string]interface{}, len(response.Data))
+			for id, value := range response.Data {
+				data[id] = value
+			}
+			unset := bson.M{"referrer": "", "referrerDomain": "", "utm": "", "notes": ""}
+			for _, id := range piiFields {
+				delete(data, id)
+				unset["data."+id] = ""
+			}
+			update := bson.M{
+				"$set": bson.M{
+					"ipAddress":    "",
+					"userAgent":    "",
+					"searchText":   search.Text(form, data),
+					"anonymizedAt": now,
+				},
+				"$unset": unset,
+			}
+			writes[i] = mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": response.ID}).SetUpdate(update)
+		}
+		result, err := s.db.Collection("responses").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return anonymized, err
+		}
+		anonymized += result.ModifiedCount
+		if err := s.analytics.RemoveAnswers(ctx, form, batch, piiFields); err != nil {
+			log.Printf("Error updating analytics rollups: %v", err)
+		}
+		if int64(len(batch)) < s.config.BatchSize {
+			return anonymized, nil
+		}
+	}
+}
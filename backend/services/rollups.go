@@ -0,0 +1,766 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/envelope"
+	"form-builder-backend/models"
+	"form-builder-backend/useragent"
+)
+
+// Rollup granularities
+const (
+	rollupHour = "hour"
+	rollupDay  = "day"
+)
+
+// rollup is a pre-aggregated summary of a form's responses in one time bucket
+type rollup struct {
+	ID          string                 `bson:"_id"`
+	FormID      primitive.ObjectID     `bson:"formId"`
+	Granularity string                 `bson:"granularity"`
+	Bucket      time.Time              `bson:"bucket"`
+	Count       int64                  `bson:"count"`
+	Imported    int64                  `bson:"imported,omitempty"` // of Count, imported from files
+	Devices     map[string]int64       `bson:"devices,omitempty"`
+	Browsers    map[string]int64       `bson:"browsers,omitempty"`
+	OS          map[string]int64       `bson:"os,omitempty"`
+	Countries   map[string]int64       `bson:"countries,omitempty"`
+	Regions     map[string]int64       `bson:"regions,omitempty"` // keyed by regionKey
+	Referrers   map[string]int64       `bson:"referrers,omitempty"`
+	Sources     map[string]int64       `bson:"sources,omitempty"`
+	Mediums     map[string]int64       `bson:"mediums,omitempty"`
+	Campaigns   map[string]int64       `bson:"campaigns,omitempty"`
+	Fields      map[string]rollupField `bson:"fields,omitempty"`
+}
+
+// rollupField holds the answer counts of one field; map keys are encoded with
+// rollupKey. Free-text fields keep word, phrase and sentiment counters in
+// place of their raw values.
+type rollupField struct {
+	Answered  int64            `bson:"answered"`
+	Values    map[string]int64 `bson:"values,omitempty"`
+	Words     map[string]int64 `bson:"words,omitempty"`
+	Phrases   map[string]int64 `bson:"phrases,omitempty"`
+	Sentiment map[string]int64 `bson:"sentiment,omitempty"`
+	Chars     int64            `bson:"chars,omitempty"`
+	Score     int64            `bson:"score,omitempty"`
+	// Other counts the answers past maxRollupKeys, keyed by the capped
+	// counter they were left out of
+	Other map[string]int64 `bson:"other,omitempty"`
+}
+
+// maxRollupKeys caps the distinct keys a bucket keeps in each capped counter
+// of a field. Without it a high-cardinality field would grow its buckets
+// toward Mongo's 16 MB document limit, after which every $inc on them fails.
+const maxRollupKeys = 200
+
+// cappedCounters are the per-field counters keyed by what respondents typed.
+// Capping words and phrases also bounds what readRollups merges per bucket.
+// Live writes admit keys first come, first served; a rebuild keeps each
+// bucket's most frequent ones.
+var cappedCounters = map[string]bool{"values": true, "words": true, "phrases": true}
+
+// rollupID is deterministic so concurrent upserts of a bucket hit the same document
+func rollupID(formID primitive.ObjectID, granularity string, bucket time.Time) string {
+	return fmt.Sprintf("%s:%s:%d", formID.Hex(), granularity, bucket.Unix())
+}
+
+func rollupBucket(granularity string, t time.Time) time.Time {
+	if granularity == rollupDay {
+		return t.UTC().Truncate(24 * time.Hour)
+	}
+	return t.UTC().Truncate(time.Hour)
+}
+
+// rollupKeyEscaper escapes the characters Mongo treats specially in field paths
+var rollupKeyEscaper = strings.NewReplacer("%", "%25", ".", "%2E", "$", "%24")
+var rollupKeyUnescaper = strings.NewReplacer("%2E", ".", "%24", "$", "%25", "%")
+
+func rollupKey(s string) string {
+	return rollupKeyEscaper.Replace(s)
+}
+
+func rollupKeyDecode(s string) string {
+	return rollupKeyUnescaper.Replace(s)
+}
+
+// rollupIncrements returns the counters a response contributes to its
+// buckets; responses from bots contribute none
+func rollupIncrements(form *models.Form, response *models.FormResponse) map[string]int64 {
+	client := useragent.Parse(response.UserAgent)
+	if response.AnonymizedAt != nil {
+		// The user agent is gone, but what was parsed from it is kept
+		if response.Device != "" {
+			client.Device = response.Device
+		}
+		if response.Browser != "" {
+			client.Browser = response.Browser
+		}
+		if response.OS != "" {
+			client.OS = response.OS
+		}
+	}
+	if client.Bot || response.Bot {
+		return nil
+	}
+
+	inc := map[string]int64{
+		"count":                                 1,
+		"devices." + rollupKey(client.Device):   1,
+		"browsers." + rollupKey(client.Browser): 1,
+		"os." + rollupKey(client.OS):            1,
+		"countries." + rollupKey(countryOrUnknown(response.Country)): 1,
+	}
+	if response.Region != "" {
+		inc["regions."+rollupKey(regionKey(response.Country, response.Region))] = 1
+	}
+	if response.Source == models.ResponseSourceImport {
+		inc["imported"] = 1
+	}
+	source, medium, campaign := campaignKeys(response.UTM)
+	inc["referrers."+rollupKey(referrerOrDirect(response.ReferrerDomain))] = 1
+	inc["sources."+rollupKey(source)] = 1
+	inc["mediums."+rollupKey(medium)] = 1
+	inc["campaigns."+rollupKey(campaign)] = 1
+
+	modes := response.Rollup
+	if modes == nil {
+		// Stored before the modes were recorded
+		modes = RollupModes(form, response.Data)
+	}
+
+	for fieldID, value := range response.Data {
+		if isEmptyAnswer(value) {
+			continue
+		}
+		field := "fields." + rollupKey(fieldID)
+		inc[field+".answered"]++
+		mode := rollupMode(modes, fieldID)
+
+		// Encrypted answers are only counted as answered
+		if envelope.IsSealed(value) || mode == models.RollupAnswered {
+			continue
+		}
+
+		// Free text is summarized rather than counted verbatim, which would
+		// add a key for every distinct answer
+		if text, ok := value.(string); ok && mode == models.RollupText {
+			analysis := analyzeText(text)
+			for _, word := range analysis.words {
+				inc[field+".words."+rollupKey(word)]++
+			}
+			for _, phrase := range analysis.phrases {
+				inc[field+".phrases."+rollupKey(phrase)]++
+			}
+			inc[field+".sentiment."+analysis.sentiment()]++
+			inc[field+".chars"] += analysis.chars
+			inc[field+".score"] += analysis.score
+			continue
+		}
+
+		for _, valueStr := range answerValues(value) {
+			inc[field+".values."+rollupKey(valueStr)]++
+		}
+	}
+
+	return inc
+}
+
+// RollupModes returns how each answer in data is counted in the rollups
+// under the form as it is now, keyed by escaped field ID like the rollup
+// counters. It is stored with the response, so removing the response later
+// mirrors how it was added.
+func RollupModes(form *models.Form, data map[string]interface{}) map[string]string {
+	textFields := make(map[string]bool)
+	if form != nil {
+		for _, field := range form.Fields {
+			if isTextField(field.Type) {
+				textFields[field.ID] = true
+			}
+		}
+	}
+	pii := piiFields(form)
+
+	modes := make(map[string]string)
+	for fieldID, value := range data {
+		if isEmptyAnswer(value) {
+			continue
+		}
+		_, isString := value.(string)
+		key := rollupKey(fieldID)
+		switch {
+		case envelope.IsSealed(value) || pii[fieldID]:
+			// PII answers aren't broken down even when stored in plain text
+			modes[key] = models.RollupAnswered
+		case isString && textFields[fieldID]:
+			modes[key] = models.RollupText
+		default:
+			modes[key] = models.RollupValues
+		}
+	}
+	if len(modes) == 0 {
+		return nil
+	}
+	return modes
+}
+
+// rollupMode returns the stored mode of a field's answer
+func rollupMode(modes map[string]string, fieldID string) string {
+	if mode, ok := modes[rollupKey(fieldID)]; ok {
+		return mode
+	}
+	// Stored before the keys were escaped
+	return modes[fieldID]
+}
+
+// RecordResponse adds a newly stored response to its hourly and daily rollups.
+// Each bucket is updated with a single atomic $inc upsert. The rollups are
+// written after the response, not in a transaction with it, so a failed
+// write leaves them short until they are rebuilt with cmd/rebuild-rollups.
+func (s *AnalyticsService) RecordResponse(ctx context.Context, form *models.Form, response *models.FormResponse) error {
+	return s.applyRollups(ctx, form, []models.FormResponse{*response}, 1)
+}
+
+// RecordResponses adds a batch of responses to their rollups, combining the
+// increments of responses that share a bucket
+func (s *AnalyticsService) RecordResponses(ctx context.Context, form *models.Form, responses []models.FormResponse) error {
+	return s.applyRollups(ctx, form, responses, 1)
+}
+
+// RemoveResponses takes deleted responses out of their rollups, combining
+// the decrements of responses that share a bucket. Counters that reach zero
+// are removed, and buckets left empty are deleted.
+func (s *AnalyticsService) RemoveResponses(ctx context.Context, form *models.Form, responses []models.FormResponse) error {
+	return s.applyRollups(ctx, form, responses, -1)
+}
+
+// RemoveAnswers takes the responses' answers to the given fields out of
+// their rollups, leaving the rest of their contributions in place
+func (s *AnalyticsService) RemoveAnswers(ctx context.Context, form *models.Form, responses []models.FormResponse, fieldIDs []string) error {
+	if len(fieldIDs) == 0 {
+		return nil
+	}
+	prefixes := make([]string, len(fieldIDs))
+	for i, id := range fieldIDs {
+		prefixes[i] = "fields." + rollupKey(id) + "."
+	}
+	return s.applyIncrements(ctx, form, responses, -1, func(response *models.FormResponse) map[string]int64 {
+		inc := make(map[string]int64)
+		for key, n := range rollupIncrements(form, response) {
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(key, prefix) {
+					inc[key] = n
+					break
+				}
+			}
+		}
+		return inc
+	})
+}
+
+// ForgetAnswers stops breaking down the answers to fields, such as fields
+// just marked PII. Their values, words and text figures are removed from the
+// form's rollups, leaving how often they were answered, and the modes stored
+// with the responses change to match, so removing a response later takes
+// away only what is left.
+func (s *AnalyticsService) ForgetAnswers(ctx context.Context, formID primitive.ObjectID, fieldIDs []string) error {
+	if len(fieldIDs) == 0 {
+		return nil
+	}
+	modes := bson.M{}
+	unset := bson.M{}
+	for _, id := range fieldIDs {
+		modes["rollup."+rollupKey(id)] = models.RollupAnswered
+		field := "fields." + rollupKey(id)
+		for _, counter := range []string{"values", "words", "phrases", "sentiment", "chars", "score", "other"} {
+			unset[field+"."+counter] = ""
+		}
+	}
+	// Responses stored before their modes were recorded follow the form,
+	// which already counts these fields as answered
+	_, err := s.db.Collection("responses").UpdateMany(ctx,
+		bson.M{"formId": formID, "rollup": bson.M{"$exists": true}},
+		bson.M{"$set": modes})
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Collection("analytics_rollups").UpdateMany(ctx, bson.M{"formId": formID}, bson.M{"$unset": unset})
+	return err
+}
+
+// applyRollups adds (delta 1) or removes (delta -1) many responses from
+// their rollups with one write per bucket. Removals never create buckets.
+func (s *AnalyticsService) applyRollups(ctx context.Context, form *models.Form, responses []models.FormResponse, delta int64) error {
+	return s.applyIncrements(ctx, form, responses, delta, func(response *models.FormResponse) map[string]int64 {
+		return rollupIncrements(form, response)
+	})
+}
+
+// applyIncrements applies the counters increments returns for each
+// response, multiplied by delta, with one write per bucket
+func (s *AnalyticsService) applyIncrements(ctx context.Context, form *models.Form, responses []models.FormResponse, delta int64, increments func(*models.FormResponse) map[string]int64) error {
+	type bucketDelta struct {
+		granularity string
+		bucket      time.Time
+		inc         map[string]int64
+	}
+	deltas := make(map[string]*bucketDelta)
+	for i := range responses {
+		response := &responses[i]
+		inc := increments(response)
+		if len(inc) == 0 {
+			continue
+		}
+		for _, granularity := range []string{rollupHour, rollupDay} {
+			bucket := rollupBucket(granularity, response.CreatedAt)
+			id := rollupID(response.FormID, granularity, bucket)
+			d := deltas[id]
+			if d == nil {
+				d = &bucketDelta{granularity: granularity, bucket: bucket, inc: make(map[string]int64)}
+				deltas[id] = d
+			}
+			for key, n := range inc {
+				d.inc[key] += n * delta
+			}
+		}
+	}
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(deltas))
+	for id := range deltas {
+		ids = append(ids, id)
+	}
+	current, err := s.currentRollups(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(deltas))
+	for id, d := range deltas {
+		d.inc = admitKeys(d.inc, current[id])
+		write := mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": id})
+		if delta > 0 {
+			write.SetUpdate(bson.M{
+				"$inc": d.inc,
+				"$setOnInsert": bson.M{
+					"formId":      form.ID,
+					"granularity": d.granularity,
+					"bucket":      d.bucket,
+				},
+			}).SetUpsert(true)
+		} else {
+			write.SetUpdate(bson.M{"$inc": d.inc})
+		}
+		writes = append(writes, write)
+	}
+	collection := s.db.Collection("analytics_rollups")
+	if _, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return err
+	}
+	if delta > 0 {
+		return nil
+	}
+
+	// Drop what the removal emptied. Each write only matches while the
+	// counter is still zero, so a concurrent increment is never lost.
+	cleanup := make([]mongo.WriteModel, 0, len(deltas))
+	for id, d := range deltas {
+		for key := range d.inc {
+			if key == "count" {
+				continue
+			}
+			cleanup = append(cleanup, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": id, key: bson.M{"$lte": 0}}).
+				SetUpdate(bson.M{"$unset": bson.M{key: ""}}))
+		}
+		if _, ok := d.inc["count"]; ok {
+			cleanup = append(cleanup, mongo.NewDeleteOneModel().SetFilter(bson.M{"_id": id, "count": bson.M{"$lte": 0}}))
+		}
+	}
+	if len(cleanup) == 0 {
+		return nil
+	}
+	_, err = collection.BulkWrite(ctx, cleanup, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+// currentRollups loads the field counters of the given buckets, keyed by ID
+func (s *AnalyticsService) currentRollups(ctx context.Context, ids []string) (map[string]*rollup, error) {
+	cursor, err := s.db.Collection("analytics_rollups").Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"fields": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	current := make(map[string]*rollup, len(ids))
+	for cursor.Next(ctx) {
+		var r rollup
+		if err := cursor.Decode(&r); err != nil {
+			return nil, err
+		}
+		current[r.ID] = &r
+	}
+	return current, cursor.Err()
+}
+
+// admitKeys moves increments that would add a key to a full capped counter
+// onto the field's other counter. Decrements of keys the bucket doesn't have
+// come out of other, where their increments went. Buckets written
+// concurrently can overshoot the cap slightly, but every count lands
+// somewhere.
+func admitKeys(inc map[string]int64, current *rollup) map[string]int64 {
+	paths := make([]string, 0, len(inc))
+	for path := range inc {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	admitted := make(map[string]int64, len(inc))
+	added := make(map[string]int)
+	for _, path := range paths {
+		n := inc[path]
+		parts := strings.SplitN(path, ".", 4)
+		if len(parts) < 4 || parts[0] != "fields" || !cappedCounters[parts[2]] {
+			admitted[path] += n
+			continue
+		}
+		counts := current.counter(parts[1], parts[2])
+		_, exists := counts[parts[3]]
+		counter := parts[1] + "." + parts[2]
+		if !exists && n > 0 && len(counts)+added[counter] < maxRollupKeys {
+			exists = true
+			added[counter]++
+		}
+		if exists {
+			admitted[path] += n
+		} else {
+			admitted["fields."+parts[1]+".other."+parts[2]] += n
+		}
+	}
+	return admitted
+}
+
+// counter returns one of a field's capped counters, or nil for a bucket that
+// doesn't exist yet
+func (r *rollup) counter(fieldKey, name string) map[string]int64 {
+	if r == nil {
+		return nil
+	}
+	field := r.Fields[fieldKey]
+	switch name {
+	case "values":
+		return field.Values
+	case "words":
+		return field.Words
+	case "phrases":
+		return field.Phrases
+	}
+	return nil
+}
+
+// RebuildRollups regenerates a form's rollups from its raw responses and
+// returns the number of responses processed. Submissions that arrive while
+// the rebuild runs may be lost from the rollups, so run it when the form is quiet.
+func (s *AnalyticsService) RebuildRollups(ctx context.Context, formID primitive.ObjectID) (int64, error) {
+	rollups := make(map[string]*rollup)
+
+	var form models.Form
+	if err := s.db.Collection("forms").FindOne(ctx, bson.M{"_id": formID}).Decode(&form); err != nil {
+		return 0, err
+	}
+	// Responses saved before their field was marked PII may still have it
+	// broken down
+	if err := s.ForgetAnswers(ctx, formID, piiFieldIDs(&form)); err != nil {
+		return 0, err
+	}
+
+	cursor, err := s.db.Collection("responses").Find(ctx, bson.M{"formId": formID},
+		options.Find().SetProjection(bson.M{"data": 1, "userAgent": 1, "bot": 1, "country": 1, "region": 1, "referrerDomain": 1, "utm": 1, "createdAt": 1, "formId": 1, "device": 1, "browser": 1, "os": 1, "anonymizedAt": 1, "source": 1, "rollup": 1}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	processed := int64(0)
+	for cursor.Next(ctx) {
+		var response models.FormResponse
+		if err := cursor.Decode(&response); err != nil {
+			log.Printf("Error decoding response during rollup rebuild: %v", err)
+			continue
+		}
+		processed++
+
+		inc := rollupIncrements(&form, &response)
+		if inc == nil {
+			continue
+		}
+		for _, granularity := range []string{rollupHour, rollupDay} {
+			bucket := rollupBucket(granularity, response.CreatedAt)
+			id := rollupID(formID, granularity, bucket)
+			r, ok := rollups[id]
+			if !ok {
+				r = &rollup{
+					ID:          id,
+					FormID:      formID,
+					Granularity: granularity,
+					Bucket:      bucket,
+					Devices:     make(map[string]int64),
+					Browsers:    make(map[string]int64),
+					OS:          make(map[string]int64),
+					Countries:   make(map[string]int64),
+					Regions:     make(map[string]int64),
+					Referrers:   make(map[string]int64),
+					Sources:     make(map[string]int64),
+					Mediums:     make(map[string]int64),
+					Campaigns:   make(map[string]int64),
+					Fields:      make(map[string]rollupField),
+				}
+				rollups[id] = r
+			}
+			r.add(inc)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return processed, err
+	}
+	for _, r := range rollups {
+		r.trimCounters()
+	}
+
+	collection := s.db.Collection("analytics_rollups")
+	if _, err := collection.DeleteMany(ctx, bson.M{"formId": formID}); err != nil {
+		return processed, err
+	}
+
+	const batchSize = 500
+	batch := make([]interface{}, 0, batchSize)
+	for _, r := range rollups {
+		batch = append(batch, r)
+		if len(batch) == batchSize {
+			if _, err := collection.InsertMany(ctx, batch); err != nil {
+				return processed, err
+			}
+			batch = batch[:0]
+		}
+	}
+	if len(batch) > 0 {
+		if _, err := collection.InsertMany(ctx, batch); err != nil {
+			return processed, err
+		}
+	}
+
+	return processed, nil
+}
+
+// add applies the dotted-path increments from rollupIncrements to an in-memory rollup
+func (r *rollup) add(inc map[string]int64) {
+	for path, n := range inc {
+		parts := strings.SplitN(path, ".", 4)
+		switch {
+		case parts[0] == "count":
+			r.Count += n
+		case parts[0] == "imported":
+			r.Imported += n
+		case parts[0] == "devices":
+			r.Devices[parts[1]] += n
+		case parts[0] == "browsers":
+			r.Browsers[parts[1]] += n
+		case parts[0] == "os":
+			r.OS[parts[1]] += n
+		case parts[0] == "countries":
+			r.Countries[parts[1]] += n
+		case parts[0] == "regions":
+			r.Regions[parts[1]] += n
+		case parts[0] == "referrers":
+			r.Referrers[parts[1]] += n
+		case parts[0] == "sources":
+			r.Sources[parts[1]] += n
+		case parts[0] == "mediums":
+			r.Mediums[parts[1]] += n
+		case parts[0] == "campaigns":
+			r.Campaigns[parts[1]] += n
+		case parts[0] == "fields" && len(parts) >= 3:
+			field := r.Fields[parts[1]]
+			switch {
+			case parts[2] == "answered":
+				field.Answered += n
+			case parts[2] == "chars":
+				field.Chars += n
+			case parts[2] == "score":
+				field.Score += n
+			case len(parts) == 4:
+				counters := map[string]*map[string]int64{
+					"values":    &field.Values,
+					"words":     &field.Words,
+					"phrases":   &field.Phrases,
+					"sentiment": &field.Sentiment,
+					"other":     &field.Other,
+				}[parts[2]]
+				if counters == nil {
+					continue
+				}
+				if *counters == nil {
+					*counters = make(map[string]int64)
+				}
+				(*counters)[parts[3]] += n
+			}
+			r.Fields[parts[1]] = field
+		}
+	}
+}
+
+// trimCounters keeps the maxRollupKeys most frequent keys of each capped
+// counter and moves the rest onto the field's other counter
+func (r *rollup) trimCounters() {
+	for key, field := range r.Fields {
+		field.Values = trimCounts(field.Values, "values", &field.Other)
+		field.Words = trimCounts(field.Words, "words", &field.Other)
+		field.Phrases = trimCounts(field.Phrases, "phrases", &field.Other)
+		r.Fields[key] = field
+	}
+}
+
+func trimCounts(counts map[string]int64, name string, other *map[string]int64) map[string]int64 {
+	if len(counts) <= maxRollupKeys {
+		return counts
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if *other == nil {
+		*other = make(map[string]int64)
+	}
+	kept := make(map[string]int64, maxRollupKeys)
+	for i, key := range keys {
+		if i < maxRollupKeys {
+			kept[key] = counts[key]
+		} else {
+			(*other)[name] += counts[key]
+		}
+	}
+	return kept
+}
+
+// merge adds another bucket's counters for the same field, decoding map keys
+func (f *rollupField) merge(other rollupField) {
+	f.Answered += other.Answered
+	f.Chars += other.Chars
+	f.Score += other.Score
+	mergeCounts(&f.Values, other.Values)
+	mergeCounts(&f.Words, other.Words)
+	mergeCounts(&f.Phrases, other.Phrases)
+	mergeCounts(&f.Sentiment, other.Sentiment)
+	mergeCounts(&f.Other, other.Other)
+}
+
+func mergeCounts(dst *map[string]int64, src map[string]int64) {
+	if len(src) == 0 {
+		return
+	}
+	if *dst == nil {
+		*dst = make(map[string]int64, len(src))
+	}
+	for key, n := range src {
+		(*dst)[rollupKeyDecode(key)] += n
+	}
+}
+
+// rollupSummary holds the all-time figures summed from a form's daily rollups
+type rollupSummary struct {
+	total     int64
+	devices   map[string]int64
+	browsers  map[string]int64
+	oses      map[string]int64
+	geo       GeographyStats
+	referrers map[string]int64
+	campaigns CampaignStats
+	fields    map[string]rollupField // decoded keys
+}
+
+func newRollupSummary() *rollupSummary {
+	return &rollupSummary{
+		devices:   make(map[string]int64),
+		browsers:  make(map[string]int64),
+		oses:      make(map[string]int64),
+		geo:       newGeographyStats(),
+		referrers: make(map[string]int64),
+		campaigns: newCampaignStats(),
+		fields:    make(map[string]rollupField),
+	}
+}
+
+// readRollups sums a form's daily rollups into its all-time totals, client
+// breakdowns and field values
+func (s *AnalyticsService) readRollups(ctx context.Context, formID primitive.ObjectID) (*rollupSummary, error) {
+	summary := newRollupSummary()
+
+	cursor, err := s.db.Collection("analytics_rollups").Find(ctx, bson.M{"formId": formID, "granularity": rollupDay})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var r rollup
+		if err := cursor.Decode(&r); err != nil {
+			continue
+		}
+		summary.total += r.Count
+		for device, n := range r.Devices {
+			summary.devices[rollupKeyDecode(device)] += n
+		}
+		for browser, n := range r.Browsers {
+			summary.browsers[rollupKeyDecode(browser)] += n
+		}
+		for os, n := range r.OS {
+			summary.oses[rollupKeyDecode(os)] += n
+		}
+		for country, n := range r.Countries {
+			summary.geo.Countries[rollupKeyDecode(country)] += n
+		}
+		for region, n := range r.Regions {
+			summary.geo.Regions[rollupKeyDecode(region)] += n
+		}
+		for referrer, n := range r.Referrers {
+			summary.referrers[rollupKeyDecode(referrer)] += n
+		}
+		for source, n := range r.Sources {
+			summary.campaigns.Sources[rollupKeyDecode(source)] += n
+		}
+		for medium, n := range r.Mediums {
+			summary.campaigns.Mediums[rollupKeyDecode(medium)] += n
+		}
+		for campaign, n := range r.Campaigns {
+			summary.campaigns.Campaigns[rollupKeyDecode(campaign)] += n
+		}
+		for key, f := range r.Fields {
+			fieldID := rollupKeyDecode(key)
+			field := summary.fields[fieldID]
+			field.merge(f)
+			summary.fields[fieldID] = field
+		}
+	}
+
+	return summary, cursor.Err()
+}
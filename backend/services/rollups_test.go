@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"form-builder-backend/models"
+)
+
+func TestRollupIncrementsFollowStoredModes(t *testing.T) {
+	form := &models.Form{Fields: []models.FormField{
+		{ID: "comment", Type: "textarea"},
+		{ID: "color", Type: "select"},
+	}}
+	response := &models.FormResponse{
+		UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15",
+		Data:      map[string]interface{}{"comment": "great service", "color": "red"},
+	}
+	response.Rollup = RollupModes(form, response.Data)
+	added := rollupIncrements(form, response)
+
+	// The comment field becomes a select after the response was stored
+	changed := &models.Form{Fields: []models.FormField{
+		{ID: "comment", Type: "select"},
+		{ID: "color", Type: "select"},
+	}}
+	if removed := rollupIncrements(changed, response); !reflect.DeepEqual(removed, added) {
+		t.Errorf("increments changed with the form:\nadded   %v\nremoved %v", added, removed)
+	}
+	if _, ok := added["fields.comment.words.great"]; !ok {
+		t.Errorf("free text should be counted by words, got %v", added)
+	}
+	if _, ok := added["fields.color.values.red"]; !ok {
+		t.Errorf("choices should be counted by value, got %v", added)
+	}
+}
+
+func TestAdmitKeysCapsDistinctKeys(t *testing.T) {
+	current := &rollup{Fields: map[string]rollupField{"f": {Values: map[string]int64{}}}}
+	for i := 0; i < maxRollupKeys-1; i++ {
+		current.Fields["f"].Values[fmt.Sprintf("v%03d", i)] = 1
+	}
+
+	got := admitKeys(map[string]int64{
+		"count":                2,
+		"fields.f.answered":    2,
+		"fields.f.values.v000": 1, // already kept
+		"fields.f.values.x1":   1, // takes the last slot
+		"fields.f.values.x2":   1, // overflows
+	}, current)
+	want := map[string]int64{
+		"count":                 2,
+		"fields.f.answered":     2,
+		"fields.f.values.v000":  1,
+		"fields.f.values.x1":    1,
+		"fields.f.other.values": 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("admitKeys = %v, want %v", got, want)
+	}
+}
+
+func TestAdmitKeysRemovesFromOtherWhenKeyMissing(t *testing.T) {
+	current := &rollup{Fields: map[string]rollupField{"f": {Values: map[string]int64{"kept": 3}}}}
+	got := admitKeys(map[string]int64{
+		"fields.f.values.kept":    -1,
+		"fields.f.values.dropped": -1,
+	}, current)
+	want := map[string]int64{
+		"fields.f.values.kept":  -1,
+		"fields.f.other.values": -1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("admitKeys = %v, want %v", got, want)
+	}
+}
+
+func TestTrimCountsKeepsMostFrequent(t *testing.T) {
+	counts := make(map[string]int64)
+	for i := 0; i < maxRollupKeys+5; i++ {
+		counts[fmt.Sprintf("w%03d", i)] = int64(i + 1)
+	}
+	var other map[string]int64
+	kept := trimCounts(counts, "words", &other)
+	if len(kept) != maxRollupKeys {
+		t.Fatalf("kept %d keys, want %d", len(kept), maxRollupKeys)
+	}
+	for i := 0; i < 5; i++ {
+		if _, ok := kept[fmt.Sprintf("w%03d", i)]; ok {
+			t.Errorf("kept w%03d, one of the least frequent", i)
+		}
+	}
+	if other["words"] != 1+2+3+4+5 {
+		t.Errorf("other words = %d, want 15", other["words"])
+	}
+}
+
+func TestRollupModesEscapeFieldIDs(t *testing.T) {
+	form := &models.Form{Fields: []models.FormField{{ID: "q1.notes", Type: "textarea"}, {ID: "$price", Type: "number"}}}
+	response := &models.FormResponse{Data: map[string]interface{}{"q1.notes": "great", "$price": 12.0}}
+	response.Rollup = RollupModes(form, response.Data)
+
+	want := map[string]string{"q1%2Enotes": models.RollupText, "%24price": models.RollupValues}
+	if !reflect.DeepEqual(response.Rollup, want) {
+		t.Errorf("RollupModes = %v, want %v", response.Rollup, want)
+	}
+	inc := rollupIncrements(form, response)
+	if inc["fields.q1%2Enotes.words.great"] != 1 || inc["fields.%24price.values.12"] != 1 {
+		t.Errorf("stored modes weren't followed: %v", inc)
+	}
+
+	// Modes stored before the keys were escaped still apply
+	response.Rollup = map[string]string{"q1.notes": models.RollupAnswered, "$price": models.RollupValues}
+	for key := range rollupIncrements(form, response) {
+		if strings.HasPrefix(key, "fields.q1%2Enotes.") && key != "fields.q1%2Enotes.answered" {
+			t.Errorf("answer counted only as answered was broken down as %s", key)
+		}
+	}
+}
+
+func TestRollupModesOnlyCountPIIAnswers(t *testing.T) {
+	form := &models.Form{Fields: []models.FormField{
+		{ID: "email", Type: "email", PII: true},
+		{ID: "bio", Type: "textarea", PII: true},
+		{ID: "color", Type: "select"},
+	}}
+	response := &models.FormResponse{Data: map[string]interface{}{"email": "jane@example.com", "bio": "likes red", "color": "red"}}
+	response.Rollup = RollupModes(form, response.Data)
+
+	want := map[string]string{"email": models.RollupAnswered, "bio": models.RollupAnswered, "color": models.RollupValues}
+	if !reflect.DeepEqual(response.Rollup, want) {
+		t.Errorf("RollupModes = %v, want %v", response.Rollup, want)
+	}
+	for key := range rollupIncrements(form, response) {
+		if strings.HasPrefix(key, "fields.email.") || strings.HasPrefix(key, "fields.bio.") {
+			if !strings.HasSuffix(key, ".answered") {
+				t.Errorf("plain text PII answer broken down as %s", key)
+			}
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"sort"
+
+	"form-builder-backend/envelope"
+	"form-builder-backend/models"
+)
+
+// MaskedValue stands in for the answers to PII fields wherever they are
+// shown to someone who may not read them
+const MaskedValue = "••••••"
+
+// FieldCipher encrypts the answers to fields marked PII before they are
+// stored, with a data key per response. A nil FieldCipher stores them as
+// they are.
+type FieldCipher struct {
+	keys envelope.KeyProvider
+}
+
+// NewFieldCipher creates a field cipher whose data keys are wrapped by keys
+func NewFieldCipher(keys envelope.KeyProvider) *FieldCipher {
+	return &FieldCipher{keys: keys}
+}
+
+// Encrypt returns a copy of data with the form's PII answers encrypted,
+// and the wrapped data key to store with it. Data without PII answers is
+// returned as is, with no key.
+func (c *FieldCipher) Encrypt(ctx context.Context, form *models.Form, data map[string]interface{}) (map[string]interface{}, *models.WrappedKey, error) {
+	return c.EncryptWith(ctx, form, data, nil)
+}
+
+// EncryptWith is Encrypt for answers already stored under wrapped, the data
+// key of their record if it has one. Answers still in plain text are
+// encrypted with that key, so the ones sealed earlier still open.
+func (c *FieldCipher) EncryptWith(ctx context.Context, form *models.Form, data map[string]interface{}, wrapped *models.WrappedKey) (map[string]interface{}, *models.WrappedKey, error) {
+	if c == nil {
+		return data, wrapped, nil
+	}
+	pii := piiFields(form)
+	var key *envelope.DataKey
+	encrypted := make(map[string]interface{}, len(data))
+	for id, value := range data {
+		if !pii[id] || isEmptyAnswer(value) || envelope.IsSealed(value) {
+			encrypted[id] = value
+			continue
+		}
+		if key == nil {
+			var err error
+			if key, err = c.dataKey(ctx, wrapped); err != nil {
+				return nil, nil, err
+			}
+		}
+		sealed, err := envelope.SealValue(key.Plaintext, value)
+		if err != nil {
+			return nil, nil, err
+		}
+		encrypted[id] = sealed
+	}
+	if key == nil {
+		return data, wrapped, nil
+	}
+	if wrapped != nil {
+		return encrypted, wrapped, nil
+	}
+	return encrypted, &models.WrappedKey{KeyID: key.KeyID, Key: key.Wrapped}, nil
+}
+
+// dataKey unwraps a record's data key, or makes a new one for a record
+// without
+func (c *FieldCipher) dataKey(ctx context.Context, wrapped *models.WrappedKey) (*envelope.DataKey, error) {
+	if wrapped == nil {
+		return c.keys.GenerateDataKey(ctx)
+	}
+	plaintext, err := c.keys.UnwrapDataKey(ctx, wrapped.KeyID, wrapped.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &envelope.DataKey{KeyID: wrapped.KeyID, Plaintext: plaintext, Wrapped: wrapped.Key}, nil
+}
+
+// Decrypt returns a copy of data with its encrypted answers decrypted with
+// the wrapped data key stored alongside them
+func (c *FieldCipher) Decrypt(ctx context.Context, data map[string]interface{}, key *models.WrappedKey) (map[string]interface{}, error) {
+	if key == nil || !hasSealedAnswers(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, envelope.ErrUnknownKey
+	}
+	plaintext, err := c.keys.UnwrapDataKey(ctx, key.KeyID, key.Key)
+	if err != nil {
+		return nil, err
+	}
+	decrypted := make(map[string]interface{}, len(data))
+	for id, value := range data {
+		if s, ok := value.(string); ok && envelope.IsSealed(s) {
+			if value, err = envelope.OpenValue(plaintext, s); err != nil {
+				return nil, err
+			}
+		}
+		decrypted[id] = value
+	}
+	return decrypted, nil
+}
+
+// Reveal decrypts a response's answers in place for a reader allowed to see
+// them, and masks them when they can't be decrypted
+func (c *FieldCipher) Reveal(ctx context.Context, form *models.Form, response *models.FormResponse) error {
+	data, err := c.Decrypt(ctx, response.Data, response.Encryption)
+	if err != nil {
+		response.Data = MaskSensitive(form, response.Data)
+		return err
+	}
+	response.Data = data
+	return nil
+}
+
+// MaskSensitive returns a copy of data with the answers to PII fields, and
+// any encrypted answers, replaced by MaskedValue
+func MaskSensitive(form *models.Form, data map[string]interface{}) map[string]interface{} {
+	pii := piiFields(form)
+	masked := make(map[string]interface{}, len(data))
+	for id, value := range data {
+		if (pii[id] && !isEmptyAnswer(value)) || envelope.IsSealed(value) {
+			value = MaskedValue
+		}
+		masked[id] = value
+	}
+	return masked
+}
+
+func piiFields(form *models.Form) map[string]bool {
+	pii := make(map[string]bool)
+	if form != nil {
+		for _, field := range form.Fields {
+			if field.PII {
+				pii[field.ID] = true
+			}
+		}
+	}
+	return pii
+}
+
+// piiFieldIDs lists the form's PII fields
+func piiFieldIDs(form *models.Form) []string {
+	ids := []string{}
+	for _, field := range form.Fields {
+		if field.PII {
+			ids = append(ids, field.ID)
+		}
+	}
+	return ids
+}
+
+func hasSealedAnswers(data map[string]interface{}) bool {
+	for _, value := range data {
+		if envelope.IsSealed(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ForgedSealedAnswer returns the ID of a submitted answer that looks like an
+// encrypted one, in a list or on its own. Stored as is it would be taken
+// for a sealed answer: masked, kept out of search and fail to decrypt.
+func ForgedSealedAnswer(data map[string]interface{}) (string, bool) {
+	ids := make([]string, 0, len(data))
+	for id := range data {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // report the same answer every time
+	for _, id := range ids {
+		if looksSealed(data[id]) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func looksSealed(value interface{}) bool {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if looksSealed(item) {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			if looksSealed(item) {
+				return true
+			}
+		}
+	}
+	return envelope.IsSealed(value)
+}
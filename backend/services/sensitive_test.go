@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"form-builder-backend/envelope"
+	"form-builder-backend/models"
+)
+
+func testCipher(t *testing.T) *FieldCipher {
+	t.Helper()
+	key := make([]byte, envelope.KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := envelope.LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewFieldCipher(keys)
+}
+
+func sensitiveTestForm() *models.Form {
+	return &models.Form{Fields: []models.FormField{
+		{ID: "email", Type: "email", PII: true},
+		{ID: "age", Type: "number", PII: true},
+		{ID: "color", Type: "select"},
+	}}
+}
+
+func TestFieldCipherRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	cipher := testCipher(t)
+	form := sensitiveTestForm()
+	data := map[string]interface{}{"email": "jane@example.com", "age": 42, "color": "red"}
+
+	encrypted, key, err := cipher.Encrypt(ctx, form, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key == nil {
+		t.Fatal("Encrypt returned no data key for PII answers")
+	}
+	if !envelope.IsSealed(encrypted["email"]) || !envelope.IsSealed(encrypted["age"]) {
+		t.Errorf("PII answers left in plain text: %v", encrypted)
+	}
+	if encrypted["color"] != "red" {
+		t.Errorf("other answers should be stored as they are, got %v", encrypted["color"])
+	}
+	if data["email"] != "jane@example.com" {
+		t.Error("Encrypt changed its input")
+	}
+
+	decrypted, err := cipher.Decrypt(ctx, encrypted, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"email": "jane@example.com", "age": 42.0, "color": "red"}
+	if !reflect.DeepEqual(decrypted, want) {
+		t.Errorf("Decrypt = %v, want %v", decrypted, want)
+	}
+}
+
+func TestFieldCipherSkipsEmptyAndNonPIIAnswers(t *testing.T) {
+	data := map[string]interface{}{"email": "", "color": "red"}
+	encrypted, key, err := testCipher(t).Encrypt(context.Background(), sensitiveTestForm(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != nil || !reflect.DeepEqual(encrypted, data) {
+		t.Errorf("Encrypt = %v with key %v, want the data unchanged and no key", encrypted, key)
+	}
+}
+
+func TestFieldCipherEncryptWithKeepsTheRecordKey(t *testing.T) {
+	ctx := context.Background()
+	cipher := testCipher(t)
+	form := sensitiveTestForm()
+
+	// The email was encrypted on submission; age became PII afterwards
+	before := &models.Form{Fields: []models.FormField{{ID: "email", PII: true}, {ID: "age"}}}
+	stored, key, err := cipher.Encrypt(ctx, before, map[string]interface{}{"email": "jane@example.com", "age": 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealedEmail := stored["email"]
+
+	backfilled, got, err := cipher.EncryptWith(ctx, form, stored, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != key {
+		t.Error("EncryptWith replaced the record's data key")
+	}
+	if backfilled["email"] != sealedEmail {
+		t.Error("EncryptWith sealed an already sealed answer again")
+	}
+	decrypted, err := cipher.Decrypt(ctx, backfilled, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted["email"] != "jane@example.com" || decrypted["age"] != 42.0 {
+		t.Errorf("Decrypt = %v", decrypted)
+	}
+}
+
+func TestNilFieldCipher(t *testing.T) {
+	ctx := context.Background()
+	var cipher *FieldCipher
+	data := map[string]interface{}{"email": "jane@example.com"}
+
+	encrypted, key, err := cipher.Encrypt(ctx, sensitiveTestForm(), data)
+	if err != nil || key != nil || !reflect.DeepEqual(encrypted, data) {
+		t.Errorf("nil cipher Encrypt = %v, %v, %v; want the data as is", encrypted, key, err)
+	}
+	if decrypted, err := cipher.Decrypt(ctx, data, nil); err != nil || !reflect.DeepEqual(decrypted, data) {
+		t.Errorf("nil cipher Decrypt of plain data = %v, %v", decrypted, err)
+	}
+
+	sealed, key, err := testCipher(t).Encrypt(ctx, sensitiveTestForm(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cipher.Decrypt(ctx, sealed, key); !errors.Is(err, envelope.ErrUnknownKey) {
+		t.Errorf("nil cipher Decrypt of sealed data = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestDecryptWithAnotherKeyFileFails(t *testing.T) {
+	ctx := context.Background()
+	sealed, key, err := testCipher(t).Encrypt(ctx, sensitiveTestForm(), map[string]interface{}{"email": "jane@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testCipher(t).Decrypt(ctx, sealed, key); !errors.Is(err, envelope.ErrUnknownKey) {
+		t.Errorf("Decrypt = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestMaskSensitive(t *testing.T) {
+	form := sensitiveTestForm()
+	tests := []struct {
+		name string
+		data map[string]interface{}
+		want map[string]interface{}
+	}{
+		{"pii answer", map[string]interface{}{"email": "jane@example.com"}, map[string]interface{}{"email": MaskedValue}},
+		{"empty pii answer", map[string]interface{}{"email": ""}, map[string]interface{}{"email": ""}},
+		{"other answer", map[string]interface{}{"color": "red"}, map[string]interface{}{"color": "red"}},
+		{"sealed answer to a field no longer pii", map[string]interface{}{"color": "enc:v1:AAAA"}, map[string]interface{}{"color": MaskedValue}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaskSensitive(form, tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MaskSensitive = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForgedSealedAnswer(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   map[string]interface{}
+		wantID string
+	}{
+		{"plain answers", map[string]interface{}{"email": "jane@example.com", "age": 42.0}, ""},
+		{"prefix inside the text", map[string]interface{}{"notes": "see enc:v1:AAAA"}, ""},
+		{"sealed looking answer", map[string]interface{}{"color": "red", "email": "enc:v1:AAAA"}, "email"},
+		{"in a list", map[string]interface{}{"tags": []interface{}{"a", "enc:v1:AAAA"}}, "tags"},
+		{"in an object", map[string]interface{}{"address": map[string]interface{}{"street": "enc:v1:AAAA"}}, "address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, forged := ForgedSealedAnswer(tt.data)
+			if id != tt.wantID || forged != (tt.wantID != "") {
+				t.Errorf("ForgedSealedAnswer = %q, %v; want %q", id, forged, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxTopValues caps the number of entries in FieldStats.TopValues
+const maxTopValues = 20
+
+// maxTopTerms caps the words and phrases reported for a free-text field
+const maxTopTerms = 20
+
+// TextStats summarizes the answers to a free-text field
+type TextStats struct {
+	AverageLength float64          `json:"averageLength"` // characters
+	TopWords      []TermCount      `json:"topWords"`
+	TopPhrases    []TermCount      `json:"topPhrases"`
+	Sentiment     SentimentSummary `json:"sentiment"`
+}
+
+// TermCount is the number of answers mentioning a word or phrase
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int64  `json:"count"`
+}
+
+// SentimentSummary counts answers by lexicon sentiment. Score is the mean
+// per-answer score; positive values lean positive.
+type SentimentSummary struct {
+	Score    float64 `json:"score"`
+	Positive int64   `json:"positive"`
+	Neutral  int64   `json:"neutral"`
+	Negative int64   `json:"negative"`
+}
+
+// isTextField reports whether a field collects free text
+func isTextField(fieldType string) bool {
+	return fieldType == "text" || fieldType == "textarea"
+}
+
+// textAnalysis is what one free-text answer contributes to its field's summary
+type textAnalysis struct {
+	words   []string // distinct, stopwords removed
+	phrases []string // distinct two-word phrases without stopwords
+	chars   int64
+	score   int64
+}
+
+// sentiment labels the analysis as positive, negative or neutral
+func (a textAnalysis) sentiment() string {
+	switch {
+	case a.score > 0:
+		return "positive"
+	case a.score < 0:
+		return "negative"
+	default:
+		return "neutral"
+	}
+}
+
+// analyzeText tokenizes an answer and scores it against the sentiment lexicon.
+// Words and phrases are counted once per answer so a long rant can't dominate.
+func analyzeText(text string) textAnalysis {
+	text = strings.TrimSpace(text)
+	analysis := textAnalysis{chars: int64(len([]rune(text)))}
+
+	tokens := tokenize(text)
+	seenWords := make(map[string]bool)
+	seenPhrases := make(map[string]bool)
+	for i, token := range tokens {
+		if score, ok := sentimentLexicon[token]; ok {
+			if i > 0 && negators[tokens[i-1]] || i > 1 && negators[tokens[i-2]] {
+				score = -score
+			}
+			analysis.score += score
+		}
+
+		if !isTermWord(token) {
+			continue
+		}
+		if !seenWords[token] {
+			seenWords[token] = true
+			analysis.words = append(analysis.words, token)
+		}
+		if i > 0 && isTermWord(tokens[i-1]) {
+			phrase := tokens[i-1] + " " + token
+			if !seenPhrases[phrase] {
+				seenPhrases[phrase] = true
+				analysis.phrases = append(analysis.phrases, phrase)
+			}
+		}
+	}
+
+	return analysis
+}
+
+// tokenize lowercases text and splits it into words. Apostrophes are dropped
+// so "don't" and "dont" are the same token.
+func tokenize(text string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			current.WriteRune(r)
+		case r == '\'' || r == '’':
+			// keep contractions together
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// isTermWord reports whether a token is worth counting as a word
+func isTermWord(token string) bool {
+	if len([]rune(token)) < 2 || stopwords[token] {
+		return false
+	}
+	for _, r := range token {
+		if !unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// addText adds n answers with the given analysis to the field's text counters
+func (f *rollupField) addText(a textAnalysis, n int64) {
+	if f.Words == nil {
+		f.Words = make(map[string]int64)
+	}
+	if f.Phrases == nil {
+		f.Phrases = make(map[string]int64)
+	}
+	if f.Sentiment == nil {
+		f.Sentiment = make(map[string]int64)
+	}
+	for _, word := range a.words {
+		f.Words[word] += n
+	}
+	for _, phrase := range a.phrases {
+		f.Phrases[phrase] += n
+	}
+	f.Chars += a.chars * n
+	f.Score += a.score * n
+	f.Sentiment[a.sentiment()] += n
+}
+
+// buildTextStats summarizes a text field's counters over its answered responses
+func buildTextStats(counts rollupField) *TextStats {
+	if counts.Answered == 0 {
+		return nil
+	}
+
+	answered := float64(counts.Answered)
+	return &TextStats{
+		AverageLength: float64(counts.Chars) / answered,
+		TopWords:      topTerms(counts.Words, maxTopTerms, 1),
+		// Phrases used only once are noise rather than a theme
+		TopPhrases: topTerms(counts.Phrases, maxTopTerms, 2),
+		Sentiment: SentimentSummary{
+			Score:    float64(counts.Score) / answered,
+			Positive: counts.Sentiment["positive"],
+			Neutral:  counts.Sentiment["neutral"],
+			Negative: counts.Sentiment["negative"],
+		},
+	}
+}
+
+// topTerms returns the most frequent terms counted at least minCount times,
+// ties broken alphabetically
+func topTerms(counts map[string]int64, limit int, minCount int64) []TermCount {
+	terms := make([]TermCount, 0, len(counts))
+	for term, n := range counts {
+		if n >= minCount {
+			terms = append(terms, TermCount{Term: term, Count: n})
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if len(terms) > limit {
+		terms = terms[:limit]
+	}
+	return terms
+}
+
+// topValues keeps the limit most frequent values, ties broken alphabetically
+func topValues(values map[string]int64, limit int) map[string]int64 {
+	top := make(map[string]int64)
+	for _, term := range topTerms(values, limit, 1) {
+		top[term.Term] = term.Count
+	}
+	return top
+}
+
+// stopwords are common English words left out of word and phrase counts
+var stopwords = toSet(
+	"a", "about", "above", "after", "again", "against", "all", "also", "am", "an", "and", "any", "are", "arent",
+	"as", "at", "be", "because", "been", "before", "being", "below", "between", "both", "but", "by", "can",
+	"cant", "could", "couldnt", "did", "didnt", "do", "does", "doesnt", "doing", "dont", "down", "during",
+	"each", "even", "few", "for", "from", "further", "get", "got", "had", "hadnt", "has", "hasnt", "have",
+	"havent", "having", "he", "hed", "hell", "her", "here", "hers", "herself", "hes", "him", "himself", "his",
+	"how", "i", "id", "if", "ill", "im", "in", "into", "is", "isnt", "it", "its", "itself", "ive", "just",
+	"let", "lets", "me", "more", "most", "much", "my", "myself", "no", "nor", "not", "now", "of", "off", "on",
+	"once", "one", "only", "or", "other", "our", "ours", "ourselves", "out", "over", "own", "really", "same",
+	"she", "shes", "should", "shouldnt", "so", "some", "such", "than", "that", "thats", "the", "their",
+	"theirs", "them", "themselves", "then", "there", "theres", "these", "they", "theyre", "theyve", "thing",
+	"things", "this", "those", "through", "to", "too", "under", "until", "up", "us", "very", "was", "wasnt",
+	"we", "were", "werent", "weve", "what", "when", "where", "which", "while", "who", "whom", "why", "will",
+	"with", "wont", "would", "wouldnt", "you", "youd", "youll", "your", "youre", "yours", "yourself",
+	"yourselves", "youve",
+)
+
+// negators flip the sentiment of the word that follows within two tokens
+var negators = toSet(
+	"not", "no", "never", "none", "nothing", "neither", "nor", "cannot", "cant", "dont", "doesnt", "didnt",
+	"isnt", "arent", "wasnt", "werent", "wont", "wouldnt", "shouldnt", "couldnt", "hardly", "barely",
+)
+
+// sentimentLexicon scores words from -3 (very negative) to 3 (very positive)
+var sentimentLexicon = map[string]int64{
+	// positive
+	"amazing": 3, "awesome": 3, "excellent": 3, "fantastic": 3, "outstanding": 3, "perfect": 3,
+	"superb": 3, "wonderful": 3, "brilliant": 3, "love": 3, "loved": 3, "loves": 3, "delighted": 3,
+	"great": 2, "good": 2, "nice": 2, "happy": 2, "glad": 2, "pleased": 2, "enjoy": 2, "enjoyed": 2,
+	"liked": 2, "helpful": 2, "friendly": 2, "easy": 2, "fast": 1, "quick": 1, "clean": 1,
+	"recommend": 2, "recommended": 2, "satisfied": 2, "impressed": 2, "beautiful": 2, "best": 3,
+	"better": 1, "smooth": 1, "simple": 1, "intuitive": 2, "useful": 2, "valuable": 2, "reliable": 2,
+	"fun": 2, "thanks": 1, "thank": 1, "appreciate": 2, "appreciated": 2, "convenient": 1, "fine": 1,
+	"ok": 1, "okay": 1, "polite": 2, "professional": 1, "efficient": 2, "responsive": 1, "worth": 1,
+	// negative
+	"awful": -3, "horrible": -3, "terrible": -3, "worst": -3, "hate": -3, "hated": -3, "disgusting": -3,
+	"useless": -3, "unacceptable": -3, "furious": -3, "bad": -2, "poor": -2, "slow": -2, "difficult": -2,
+	"hard": -1, "confusing": -2, "confused": -2, "broken": -2, "bug": -1, "buggy": -2, "crash": -2,
+	"crashed": -2, "crashes": -2, "error": -1, "errors": -1, "problem": -1, "problems": -1, "issue": -1,
+	"issues": -1, "annoying": -2, "annoyed": -2, "disappointed": -2, "disappointing": -2, "frustrating": -2,
+	"frustrated": -2, "unhappy": -2, "angry": -3, "rude": -2, "expensive": -1, "waste": -2, "wrong": -2,
+	"fail": -2, "failed": -2, "fails": -2, "failure": -2, "complicated": -2, "ugly": -2, "worse": -2,
+	"lacking": -1, "missing": -1, "unclear": -1, "dislike": -2, "sad": -2, "sorry": -1, "late": -1,
+}
+
+func toSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
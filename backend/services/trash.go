@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/models"
+)
+
+// TrashConfig tunes how long deleted forms can be restored
+type TrashConfig struct {
+	// RestoreWindow is how long a deleted form stays in the trash before it
+	// and its data are purged
+	RestoreWindow time.Duration
+	// PurgeInterval is how often expired forms are purged
+	PurgeInterval time.Duration
+}
+
+// DefaultTrashConfig returns the default trash settings
+func DefaultTrashConfig() TrashConfig {
+	return TrashConfig{
+		RestoreWindow: 30 * 24 * time.Hour,
+		PurgeInterval: time.Hour,
+	}
+}
+
+// formDataCollections hold a form's data by formId and are emptied when the
+// form is purged
+var formDataCollections = []string{"responses", "partial_responses", "form_events", "analytics_rollups"}
+
+// TrashService soft-deletes forms, restores them within the restore window
+// and purges them, with their responses, rollups and export files, once it
+// has passed
+type TrashService struct {
+	db      *mongo.Database
+	exports *ExportJobService
+	config  TrashConfig
+}
+
+// NewTrashService creates a trash service. exports may be nil when export
+// jobs aren't running.
+func NewTrashService(db *mongo.Database, exports *ExportJobService, config TrashConfig) *TrashService {
+	return &TrashService{db: db, exports: exports, config: config}
+}
+
+// EnsureIndexes creates the index for finding forms due to be purged
+func (s *TrashService) EnsureIndexes(ctx context.Context) error {
+	_, err := s.db.Collection("forms").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "purgeAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	return err
+}
+
+// Delete moves one of a user's forms to the trash. The form stops accepting
+// submissions and is hidden until it is restored or purged.
+func (s *TrashService) Delete(ctx context.Context, id primitive.ObjectID, userID string) (*models.Form, error) {
+	now := time.Now()
+	purgeAt := now.Add(s.config.RestoreWindow)
+
+	var form models.Form
+	err := s.db.Collection("forms").FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "userId": userID, "deletedAt": nil},
+		bson.M{"$set": bson.M{"deletedAt": now, "purgeAt": purgeAt, "isActive": false}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&form)
+	if err != nil {
+		return nil, err
+	}
+	return &form, nil
+}
+
+// Restore takes one of a user's forms out of the trash. A published form
+// accepts submissions again.
+func (s *TrashService) Restore(ctx context.Context, id primitive.ObjectID, userID string) (*models.Form, error) {
+	collection := s.db.Collection("forms")
+	filter := bson.M{"_id": id, "userId": userID, "deletedAt": bson.M{"$ne": nil}}
+
+	var form models.Form
+	if err := collection.FindOne(ctx, filter).Decode(&form); err != nil {
+		return nil, err
+	}
+	form.IsActive = form.Status == "published"
+	form.UpdatedAt = time.Now()
+	form.DeletedAt, form.PurgeAt = nil, nil
+	result, err := collection.UpdateOne(ctx, filter, bson.M{
+		"$set":   bson.M{"isActive": form.IsActive, "updatedAt": form.UpdatedAt},
+		"$unset": bson.M{"deletedAt": "", "purgeAt": ""},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		// Purged since it was read
+		return nil, mongo.ErrNoDocuments
+	}
+	return &form, nil
+}
+
+// Purge removes a form and everything stored for it. The form document goes
+// last, so a purge that fails partway is retried by the next sweep.
+func (s *TrashService) Purge(ctx context.Context, id primitive.ObjectID) error {
+	if s.exports != nil {
+		if err := s.exports.DeleteForm(ctx, id); err != nil {
+			return err
+		}
+	}
+	for _, name := range formDataCollections {
+		if _, err := s.db.Collection(name).DeleteMany(ctx, bson.M{"formId": id}); err != nil {
+			return err
+		}
+	}
+	_, err := s.db.Collection("forms").DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// Run purges forms whose restore window has passed until ctx is done
+func (s *TrashService) Run(ctx context.Context) {
+	s.purgeExpired(ctx, time.Now())
+	ticker := time.NewTicker(s.config.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.purgeExpired(ctx, now)
+		}
+	}
+}
+
+func (s *TrashService) purgeExpired(ctx context.Context, now time.Time) {
+	ids, err := s.db.Collection("forms").Distinct(ctx, "_id", bson.M{"purgeAt": bson.M{"$lte": now}})
+	if err != nil {
+		log.Printf("Error finding forms to purge: %v", err)
+		return
+	}
+	for _, id := range ids {
+		formID, ok := id.(primitive.ObjectID)
+		if !ok {
+			continue
+		}
+		if err := s.Purge(ctx, formID); err != nil {
+			log.Printf("Error purging form %s: %v", formID.Hex(), err)
+			continue
+		}
+		log.Printf("Purged deleted form %s", formID.Hex())
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"form-builder-backend/models"
+)
+
+// ErrInvalidQuery is wrapped by errors caused by bad analytics or listing
+// query parameters
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Trend intervals
+const (
+	IntervalHour  = "hour"
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// maxTrendBuckets bounds the number of points a single trends query returns
+const maxTrendBuckets = 2000
+
+// TrendQuery selects the range and granularity of GetResponseTrends. From and
+// To are RFC 3339 timestamps or YYYY-MM-DD dates in the query's timezone; a
+// date-only To includes that whole day. The options' filter and segment
+// narrow the responses counted.
+type TrendQuery struct {
+	AnalyticsOptions
+	From     string
+	To       string
+	Interval string
+}
+
+// TrendData is a zero-filled response trend with the preceding period for comparison
+type TrendData struct {
+	FormID   string      `json:"formId"`
+	Interval string      `json:"interval"`
+	Timezone string      `json:"timezone"`
+	Current  TrendPeriod `json:"current"`
+	Previous TrendPeriod `json:"previous"`
+	Change   *float64    `json:"change"` // percent change from the previous period; null when it had no responses
+}
+
+// TrendPeriod is one period of a trend
+type TrendPeriod struct {
+	From   time.Time    `json:"from"`
+	To     time.Time    `json:"to"`
+	Total  int64        `json:"total"`
+	Points []TrendPoint `json:"points"`
+}
+
+// GetResponseTrends counts a form's responses per interval over an arbitrary range
+func (s *AnalyticsService) GetResponseTrends(formID string, q TrendQuery) (*TrendData, error) {
+	objID, err := primitive.ObjectIDFromHex(formID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+
+	var form models.Form
+	if err := s.db.Collection("forms").FindOne(ctx, bson.M{"_id": objID, "deletedAt": nil}).Decode(&form); err != nil {
+		return nil, err
+	}
+
+	loc := q.Location
+	if loc == nil {
+		if loc, err = LoadLocation(form.Timezone); err != nil {
+			loc = time.UTC
+		}
+	}
+
+	interval := q.Interval
+	if interval == "" {
+		interval = IntervalDay
+	}
+	if interval != IntervalHour && interval != IntervalDay && interval != IntervalWeek && interval != IntervalMonth {
+		return nil, fmt.Errorf("%w: interval must be hour, day, week or month", ErrInvalidQuery)
+	}
+
+	// Without an end the range runs to the end of the current local hour:
+	// nothing is stored after now, and a whole hour can still be read from
+	// rollups
+	to := alignInterval(time.Now(), IntervalHour, loc).Add(time.Hour)
+	if q.To != "" {
+		if to, err = parseTrendTime(q.To, loc, true); err != nil {
+			return nil, err
+		}
+	}
+	from := stepInterval(alignInterval(to, interval, loc), interval, loc, -30)
+	if q.From != "" {
+		if from, err = parseTrendTime(q.From, loc, false); err != nil {
+			return nil, err
+		}
+	}
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+	}
+
+	boundaries, previousBoundaries, err := trendBoundaries(from, to, interval, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := resolveFilter(&form, q.AnalyticsOptions, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	useRollups, err := s.hasRollups(ctx, objID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.trendPeriod(ctx, objID, filter, boundaries, interval, loc, useRollups)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.trendPeriod(ctx, objID, filter, previousBoundaries, interval, loc, useRollups)
+	if err != nil {
+		return nil, err
+	}
+
+	trends := &TrendData{
+		FormID:   formID,
+		Interval: interval,
+		Timezone: loc.String(),
+		Current:  current,
+		Previous: previous,
+	}
+	if previous.Total > 0 {
+		change := float64(current.Total-previous.Total) / float64(previous.Total) * 100
+		trends.Change = &change
+	}
+
+	return trends, nil
+}
+
+// trendPeriod counts and labels the buckets of one period
+func (s *AnalyticsService) trendPeriod(ctx context.Context, formID primitive.ObjectID, filter bson.M, boundaries []time.Time, interval string, loc *time.Location, useRollups bool) (TrendPeriod, error) {
+	counts, err := s.countBuckets(ctx, formID, filter, boundaries, loc, useRollups)
+	if err != nil {
+		return TrendPeriod{}, err
+	}
+
+	period := TrendPeriod{
+		From:   boundaries[0].In(loc),
+		To:     boundaries[len(boundaries)-1].In(loc),
+		Points: make([]TrendPoint, len(counts)),
+	}
+	for i, count := range counts {
+		start := boundaries[i].In(loc)
+		period.Total += count
+		period.Points[i] = TrendPoint{
+			Time:  start,
+			Count: count,
+			Label: start.Format(intervalLabelLayout(interval)),
+		}
+	}
+	return period, nil
+}
+
+// trendBoundaries returns the bucket boundaries of a trend: zero-filled
+// buckets from the interval containing from until to, the last one cut short
+// at to, and those of the previous period. That has the same number of
+// intervals, leading up to where this one starts, with its last one cut
+// as far into it so the two compare like for like.
+func trendBoundaries(from, to time.Time, interval string, loc *time.Location) (current, previous []time.Time, err error) {
+	current = []time.Time{alignInterval(from, interval, loc)}
+	for current[len(current)-1].Before(to) {
+		if len(current) > maxTrendBuckets {
+			return nil, nil, fmt.Errorf("%w: range spans more than %d %ss", ErrInvalidQuery, maxTrendBuckets, interval)
+		}
+		current = append(current, stepInterval(current[len(current)-1], interval, loc, 1))
+	}
+	buckets := len(current) - 1
+	elapsed := to.Sub(current[buckets-1])
+	current[buckets] = to
+
+	previous = make([]time.Time, 0, len(current))
+	for i := buckets; i >= 0; i-- {
+		previous = append(previous, stepInterval(current[0], interval, loc, -i))
+	}
+	if end := previous[buckets-1].Add(elapsed); end.Before(previous[buckets]) {
+		previous[buckets] = end
+	}
+	return current, previous, nil
+}
+
+// hasRollups reports whether a form's rollups have been built
+func (s *AnalyticsService) hasRollups(ctx context.Context, formID primitive.ObjectID) (bool, error) {
+	count, err := s.db.Collection("analytics_rollups").CountDocuments(ctx,
+		bson.M{"formId": formID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// parseTrendTime parses an RFC 3339 timestamp or a YYYY-MM-DD date in loc.
+// With endOfDay, a date means the end of that day.
+func parseTrendTime(value string, loc *time.Location, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q is not an RFC 3339 timestamp or YYYY-MM-DD date", ErrInvalidQuery, value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// alignInterval returns the start of the interval containing t, in loc.
+// Weeks start on Monday.
+func alignInterval(t time.Time, interval string, loc *time.Location) time.Time {
+	local := t.In(loc)
+	y, m, d := local.Date()
+	switch interval {
+	case IntervalHour:
+		return time.Date(y, m, d, local.Hour(), 0, 0, 0, loc)
+	case IntervalWeek:
+		return time.Date(y, m, d-(int(local.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case IntervalMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+}
+
+// stepInterval moves an interval start n intervals forward (or back when
+// negative). Calendar arithmetic keeps days and months whole across DST.
+func stepInterval(t time.Time, interval string, loc *time.Location, n int) time.Time {
+	local := t.In(loc)
+	y, m, d := local.Date()
+	switch interval {
+	case IntervalHour:
+		return t.Add(time.Duration(n) * time.Hour)
+	case IntervalWeek:
+		return time.Date(y, m, d+7*n, 0, 0, 0, 0, loc)
+	case IntervalMonth:
+		return time.Date(y, m+time.Month(n), 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d+n, 0, 0, 0, 0, loc)
+	}
+}
+
+func intervalLabelLayout(interval string) string {
+	switch interval {
+	case IntervalHour:
+		return "Jan 2 3:04 PM"
+	case IntervalMonth:
+		return "Jan 2006"
+	default:
+		return "Jan 2"
+	}
+}
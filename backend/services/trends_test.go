@@ -0,0 +1,81 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrendBoundaries(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(value string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, berlin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	tests := []struct {
+		name          string
+		from, to      string
+		interval      string
+		want, wantOld []string
+	}{
+		{
+			"last day cut at to",
+			"2024-03-04 00:00", "2024-03-06 15:00", IntervalDay,
+			[]string{"2024-03-04 00:00", "2024-03-05 00:00", "2024-03-06 00:00", "2024-03-06 15:00"},
+			[]string{"2024-03-01 00:00", "2024-03-02 00:00", "2024-03-03 00:00", "2024-03-03 15:00"},
+		},
+		{
+			"whole days",
+			"2024-03-04 10:00", "2024-03-06 00:00", IntervalDay,
+			[]string{"2024-03-04 00:00", "2024-03-05 00:00", "2024-03-06 00:00"},
+			[]string{"2024-03-02 00:00", "2024-03-03 00:00", "2024-03-04 00:00"},
+		},
+		{
+			"week cut at to",
+			"2024-03-04 00:00", "2024-03-13 12:00", IntervalWeek,
+			[]string{"2024-03-04 00:00", "2024-03-11 00:00", "2024-03-13 12:00"},
+			[]string{"2024-02-19 00:00", "2024-02-26 00:00", "2024-02-28 12:00"},
+		},
+		{
+			// March is longer than February, so the previous period's last
+			// month ends where it would anyway
+			"month cut after the previous month's length",
+			"2024-03-01 00:00", "2024-03-31 00:00", IntervalMonth,
+			[]string{"2024-03-01 00:00", "2024-03-31 00:00"},
+			[]string{"2024-02-01 00:00", "2024-03-01 00:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, previous, err := trendBoundaries(at(tt.from), at(tt.to), tt.interval, berlin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			check := func(period string, got []time.Time, want []string) {
+				if len(got) != len(want) {
+					t.Fatalf("%s boundaries = %v, want %v", period, got, want)
+				}
+				for i := range want {
+					if !got[i].Equal(at(want[i])) {
+						t.Errorf("%s boundary %d = %v, want %s", period, i, got[i].In(berlin), want[i])
+					}
+				}
+			}
+			check("current", current, tt.want)
+			check("previous", previous, tt.wantOld)
+		})
+	}
+}
+
+func TestTrendBoundariesLimit(t *testing.T) {
+	from := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, _, err := trendBoundaries(from, from.Add(maxTrendBuckets*2*time.Hour), IntervalHour, time.UTC); err == nil {
+		t.Error("trendBoundaries accepted a range over the bucket limit")
+	}
+}
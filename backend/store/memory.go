@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"form-builder-backend/models"
+	"form-builder-backend/search"
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -13,7 +15,10 @@ import (
 type MemoryStore struct {
 	forms     map[string]*models.Form
 	responses map[string]*models.FormResponse
-	mu        sync.RWMutex
+	partials  map[string]*models.PartialResponse // keyed by resume token
+	// terms maps each search term to the IDs of the responses containing it
+	terms map[string]map[string]bool
+	mu    sync.RWMutex
 }
 
 // NewMemoryStore creates a new in-memory store
@@ -21,6 +26,8 @@ func NewMemoryStore() *MemoryStore {
 	store := &MemoryStore{
 		forms:     make(map[string]*models.Form),
 		responses: make(map[string]*models.FormResponse),
+		partials:  make(map[string]*models.PartialResponse),
+		terms:     make(map[string]map[string]bool),
 	}
 	
 	// Create a demo form for testing
@@ -90,7 +97,7 @@ func (s *MemoryStore) GetForm(id string) (*models.Form, error) {
 	defer s.mu.RUnlock()
 	
 	form, exists := s.forms[id]
-	if !exists {
+	if !exists || form.DeletedAt != nil {
 		return nil, errors.New("form not found")
 	}
 	
@@ -103,7 +110,7 @@ func (s *MemoryStore) GetForms(userID string, status string) ([]*models.Form, er
 	
 	var forms []*models.Form
 	for _, form := range s.forms {
-		if form.UserID == userID {
+		if form.UserID == userID && form.DeletedAt == nil {
 			if status == "" || form.Status == status {
 				// Count responses for this form
 				responseCount := 0
@@ -126,7 +133,7 @@ func (s *MemoryStore) UpdateForm(id string, updates map[string]interface{}) (*mo
 	defer s.mu.Unlock()
 	
 	form, exists := s.forms[id]
-	if !exists {
+	if !exists || form.DeletedAt != nil {
 		return nil, errors.New("form not found")
 	}
 	
@@ -139,6 +146,8 @@ func (s *MemoryStore) UpdateForm(id string, updates map[string]interface{}) (*mo
 	}
 	if fields, ok := updates["fields"].([]models.FormField); ok {
 		form.Fields = fields
+		// Answers to fields newly marked PII leave the search index
+		s.reindexResponses(form)
 	}
 	if status, ok := updates["status"].(string); ok {
 		form.Status = status
@@ -147,23 +156,93 @@ func (s *MemoryStore) UpdateForm(id string, updates map[string]interface{}) (*mo
 	if isActive, ok := updates["isActive"].(bool); ok {
 		form.IsActive = isActive
 	}
+	if timezone, ok := updates["timezone"].(string); ok {
+		form.Timezone = timezone
+	}
 	
 	form.UpdatedAt = time.Now()
 	return form, nil
 }
 
-func (s *MemoryStore) DeleteForm(id string) error {
+// DeleteForm moves a form to the trash until purgeAt
+func (s *MemoryStore) DeleteForm(id string, purgeAt time.Time) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
-	if _, exists := s.forms[id]; !exists {
+
+	form, exists := s.forms[id]
+	if !exists || form.DeletedAt != nil {
 		return errors.New("form not found")
 	}
-	
-	delete(s.forms, id)
+
+	now := time.Now()
+	form.DeletedAt = &now
+	form.PurgeAt = &purgeAt
+	form.IsActive = false
+	return nil
+}
+
+// RestoreForm takes a form out of the trash
+func (s *MemoryStore) RestoreForm(id string) (*models.Form, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	form, exists := s.forms[id]
+	if !exists || form.DeletedAt == nil {
+		return nil, errors.New("form not found")
+	}
+
+	form.DeletedAt = nil
+	form.PurgeAt = nil
+	form.IsActive = form.Status == "published"
+	form.UpdatedAt = time.Now()
+	return form, nil
+}
+
+// PurgeForm removes a form with its responses and partial responses
+func (s *MemoryStore) PurgeForm(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	form, exists := s.forms[id]
+	if !exists {
+		return errors.New("form not found")
+	}
+
+	s.purgeForm(form)
 	return nil
 }
 
+// PurgeExpiredForms purges the forms whose restore window ended before now
+// and returns how many there were
+func (s *MemoryStore) PurgeExpiredForms(now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for _, form := range s.forms {
+		if form.PurgeAt != nil && !form.PurgeAt.After(now) {
+			s.purgeForm(form)
+			purged++
+		}
+	}
+	return purged
+}
+
+func (s *MemoryStore) purgeForm(form *models.Form) {
+	for id, response := range s.responses {
+		if response.FormID == form.ID {
+			s.unindexResponse(response)
+			delete(s.responses, id)
+		}
+	}
+	for token, partial := range s.partials {
+		if partial.FormID == form.ID {
+			delete(s.partials, token)
+		}
+	}
+	delete(s.forms, form.ID.Hex())
+}
+
 // Responses operations
 
 func (s *MemoryStore) CreateResponse(response *models.FormResponse) error {
@@ -172,11 +251,140 @@ func (s *MemoryStore) CreateResponse(response *models.FormResponse) error {
 	
 	response.ID = primitive.NewObjectID()
 	response.CreatedAt = time.Now()
+	response.SearchText = search.Text(s.forms[response.FormID.Hex()], response.Data)
 	
 	s.responses[response.ID.Hex()] = response
+	s.indexResponse(response)
 	return nil
 }
 
+func (s *MemoryStore) GetResponse(id string) (*models.FormResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	response, exists := s.responses[id]
+	if !exists {
+		return nil, errors.New("response not found")
+	}
+
+	return response, nil
+}
+
+// UpdateResponse applies "data", "tags", "status" and "assignee" updates to
+// a response
+func (s *MemoryStore) UpdateResponse(id string, updates map[string]interface{}) (*models.FormResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response, exists := s.responses[id]
+	if !exists {
+		return nil, errors.New("response not found")
+	}
+
+	if data, ok := updates["data"].(map[string]interface{}); ok {
+		s.unindexResponse(response)
+		response.Data = data
+		response.SearchText = search.Text(s.forms[response.FormID.Hex()], data)
+		s.indexResponse(response)
+	}
+	if tags, ok := updates["tags"].([]string); ok {
+		response.Tags = tags
+	}
+	if status, ok := updates["status"].(string); ok {
+		response.Status = status
+	}
+	if assignee, ok := updates["assignee"].(string); ok {
+		response.Assignee = assignee
+	}
+
+	return response, nil
+}
+
+// AddResponseNote appends a note to a response
+func (s *MemoryStore) AddResponseNote(id string, note models.ResponseNote) (*models.FormResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response, exists := s.responses[id]
+	if !exists {
+		return nil, errors.New("response not found")
+	}
+	response.Notes = append(response.Notes, note)
+
+	return response, nil
+}
+
+// DeleteResponseNote removes a note from a response
+func (s *MemoryStore) DeleteResponseNote(id, noteID string) (*models.FormResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	response, exists := s.responses[id]
+	if !exists {
+		return nil, errors.New("response not found")
+	}
+	for i, note := range response.Notes {
+		if note.ID.Hex() == noteID {
+			response.Notes = append(response.Notes[:i], response.Notes[i+1:]...)
+			return response, nil
+		}
+	}
+
+	return nil, errors.New("note not found")
+}
+
+// DeleteResponses deletes responses by ID and returns the ones that existed
+func (s *MemoryStore) DeleteResponses(ids []string) []*models.FormResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted []*models.FormResponse
+	for _, id := range ids {
+		response, exists := s.responses[id]
+		if !exists {
+			continue
+		}
+		s.unindexResponse(response)
+		delete(s.responses, id)
+		deleted = append(deleted, response)
+	}
+
+	return deleted
+}
+
+// indexResponse adds a response to the search index
+func (s *MemoryStore) indexResponse(response *models.FormResponse) {
+	id := response.ID.Hex()
+	for _, term := range search.Terms(response.SearchText) {
+		if s.terms[term] == nil {
+			s.terms[term] = make(map[string]bool)
+		}
+		s.terms[term][id] = true
+	}
+}
+
+// reindexResponses rebuilds the search text of a form's responses
+func (s *MemoryStore) reindexResponses(form *models.Form) {
+	for _, response := range s.responses {
+		if response.FormID == form.ID {
+			s.unindexResponse(response)
+			response.SearchText = search.Text(form, response.Data)
+			s.indexResponse(response)
+		}
+	}
+}
+
+// unindexResponse removes a response from the search index
+func (s *MemoryStore) unindexResponse(response *models.FormResponse) {
+	id := response.ID.Hex()
+	for _, term := range search.Terms(response.SearchText) {
+		delete(s.terms[term], id)
+		if len(s.terms[term]) == 0 {
+			delete(s.terms, term)
+		}
+	}
+}
+
 func (s *MemoryStore) GetResponsesByForm(formID string) ([]*models.FormResponse, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -205,13 +413,84 @@ func (s *MemoryStore) CountResponses(formID string, since time.Time) (int64, err
 	return count, nil
 }
 
+// Partial responses operations
+
+// SavePartialResponse creates a new partial response, or updates the one
+// matching partial.ResumeToken when it is set
+func (s *MemoryStore) SavePartialResponse(partial *models.PartialResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if partial.ResumeToken == "" {
+		partial.ID = primitive.NewObjectID()
+		partial.ResumeToken = uuid.New().String()
+		partial.CreatedAt = time.Now()
+		partial.UpdatedAt = partial.CreatedAt
+		s.partials[partial.ResumeToken] = partial
+		return nil
+	}
+
+	existing, exists := s.partials[partial.ResumeToken]
+	if !exists || existing.FormID != partial.FormID || existing.CompletedAt != nil {
+		return errors.New("partial response not found")
+	}
+
+	existing.Data = partial.Data
+	existing.UpdatedAt = time.Now()
+	*partial = *existing
+	return nil
+}
+
+func (s *MemoryStore) GetPartialResponse(token string) (*models.PartialResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	partial, exists := s.partials[token]
+	if !exists || partial.CompletedAt != nil {
+		return nil, errors.New("partial response not found")
+	}
+
+	return partial, nil
+}
+
+// CompletePartialResponse links a partial response to the response it became
+func (s *MemoryStore) CompletePartialResponse(token string, responseID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	partial, exists := s.partials[token]
+	if !exists || partial.CompletedAt != nil {
+		return errors.New("partial response not found")
+	}
+
+	now := time.Now()
+	partial.CompletedAt = &now
+	partial.ResponseID = &responseID
+	return nil
+}
+
+// CountPartialResponses counts the unfinished partial responses of a form
+func (s *MemoryStore) CountPartialResponses(formID string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := int64(0)
+	for _, partial := range s.partials {
+		if partial.FormID.Hex() == formID && partial.CompletedAt == nil {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
 // Check if form is published
 func (s *MemoryStore) IsFormPublished(formID string) (bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	
 	form, exists := s.forms[formID]
-	if !exists {
+	if !exists || form.DeletedAt != nil {
 		return false, errors.New("form not found")
 	}
 	
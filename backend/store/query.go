@@ -0,0 +1,341 @@
+package store
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"form-builder-backend/models"
+	"form-builder-backend/search"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Response listing page sizes, matching services.DefaultPageSize and MaxPageSize
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// ListResponses returns one page of the responses of a form that match
+// query, with the same filter, ordering and cursor semantics as the Mongo
+// listing in services.ResponseService
+func (s *MemoryStore) ListResponses(formID string, query models.ResponseListQuery) (*models.ResponsePage, error) {
+	var after *models.ResponseCursor
+	if query.Cursor != "" {
+		cursor, err := models.DecodeResponseCursor(query.Cursor, query)
+		if err != nil {
+			return nil, err
+		}
+		after = &cursor
+	}
+
+	s.mu.RLock()
+	form := s.forms[formID]
+	var matched []*models.FormResponse
+	for _, resp := range s.searchCandidates(query.Search) {
+		if resp.FormID.Hex() == formID && matchesCriteria(resp, query.Criteria) {
+			matched = append(matched, resp)
+		}
+	}
+	s.mu.RUnlock()
+
+	// compare orders a before b in the listing's direction
+	compare := func(aValue interface{}, aID primitive.ObjectID, bValue interface{}, bID primitive.ObjectID) int {
+		c := compareValues(aValue, bValue)
+		if c == 0 {
+			c = bytes.Compare(aID[:], bID[:])
+		}
+		if query.Descending {
+			c = -c
+		}
+		return c
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		return compare(sortValue(a, query.Sort), a.ID, sortValue(b, query.Sort), b.ID) < 0
+	})
+
+	page := &models.ResponsePage{Total: int64(len(matched)), Responses: []models.FormResponse{}}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	} else if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	for _, resp := range matched {
+		if after != nil && compare(sortValue(resp, query.Sort), resp.ID, after.Value, after.ID) <= 0 {
+			continue
+		}
+		if len(page.Responses) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Responses = append(page.Responses, *resp)
+	}
+	if len(query.Search) > 0 {
+		page.Highlights = make(map[string][]models.Snippet, len(page.Responses))
+		for _, resp := range page.Responses {
+			page.Highlights[resp.ID.Hex()] = search.Highlight(form, resp.Data, query.Search)
+		}
+	}
+	if page.HasMore {
+		last := page.Responses[limit-1]
+		page.NextCursor = models.ResponseCursor{
+			Sort:       query.Sort,
+			Descending: query.Descending,
+			Value:      sortValue(&last, query.Sort),
+			ID:         last.ID,
+		}.Encode()
+	}
+
+	return page, nil
+}
+
+// searchCandidates returns the responses containing every term, looked up
+// from the search index starting with the rarest term, or every response
+// when there are no terms. The caller must hold s.mu.
+func (s *MemoryStore) searchCandidates(terms []string) map[string]*models.FormResponse {
+	if len(terms) == 0 {
+		return s.responses
+	}
+
+	rarest := s.terms[terms[0]]
+	for _, term := range terms[1:] {
+		if len(s.terms[term]) < len(rarest) {
+			rarest = s.terms[term]
+		}
+	}
+
+	candidates := make(map[string]*models.FormResponse)
+	for id := range rarest {
+		found := true
+		for _, term := range terms {
+			if !s.terms[term][id] {
+				found = false
+				break
+			}
+		}
+		if found {
+			candidates[id] = s.responses[id]
+		}
+	}
+	return candidates
+}
+
+func sortValue(resp *models.FormResponse, sortBy string) interface{} {
+	if sortBy == models.SortByCreatedAt {
+		return resp.CreatedAt
+	}
+	return resp.Data[sortBy]
+}
+
+// matchesCriteria applies criteria the way services.CriteriaMatch does in Mongo
+func matchesCriteria(resp *models.FormResponse, criteria models.ResponseCriteria) bool {
+	if !criteria.From.IsZero() && resp.CreatedAt.Before(criteria.From) {
+		return false
+	}
+	if !criteria.To.IsZero() && !resp.CreatedAt.Before(criteria.To) {
+		return false
+	}
+	if criteria.Device != "" && resp.Device != criteria.Device {
+		return false
+	}
+	for _, tag := range criteria.Tags {
+		if !containsString(resp.Tags, tag) {
+			return false
+		}
+	}
+	if criteria.Status != "" {
+		status := resp.Status
+		if status == "" {
+			status = models.ReviewNew
+		}
+		if status != criteria.Status {
+			return false
+		}
+	}
+	switch criteria.Assignee {
+	case "":
+	case models.UnassignedFilter:
+		if resp.Assignee != "" {
+			return false
+		}
+	default:
+		if resp.Assignee != criteria.Assignee {
+			return false
+		}
+	}
+	for _, f := range criteria.Fields {
+		if !matchesField(resp.Data[f.FieldID], f) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesField tests an answer against a field criterion. Like a Mongo query,
+// an array answer matches when one of its elements does, and for a range
+// each bound may be met by a different element.
+func matchesField(answer interface{}, f models.FieldCriterion) bool {
+	elements := []interface{}{answer}
+	switch a := answer.(type) {
+	case []interface{}:
+		elements = a
+	case primitive.A:
+		elements = a
+	case []string:
+		elements = make([]interface{}, len(a))
+		for i, s := range a {
+			elements[i] = s
+		}
+	}
+
+	anyElement := func(test func(interface{}) bool) bool {
+		for _, e := range elements {
+			if test(e) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch f.Op {
+	case models.FieldOpContains:
+		needle := strings.ToLower(f.Value)
+		return anyElement(func(e interface{}) bool {
+			s, ok := e.(string)
+			return ok && strings.Contains(strings.ToLower(s), needle)
+		})
+	case models.FieldOpRange:
+		return (f.Min == nil || anyElement(func(e interface{}) bool {
+			n, ok := toNumber(e)
+			return ok && n >= *f.Min
+		})) && (f.Max == nil || anyElement(func(e interface{}) bool {
+			n, ok := toNumber(e)
+			return ok && n <= *f.Max
+		}))
+	default:
+		return anyElement(func(e interface{}) bool {
+			switch v := e.(type) {
+			case string:
+				return v == f.Value
+			case bool:
+				return (f.Value == "true" && v) || (f.Value == "false" && !v)
+			}
+			n, ok := toNumber(e)
+			if !ok {
+				return false
+			}
+			want, err := strconv.ParseFloat(f.Value, 64)
+			return err == nil && n == want
+		})
+	}
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// compareValues orders values the way Mongo sorts them: null, then numbers,
+// strings, documents, arrays, booleans and dates. Times compare at BSON's
+// millisecond precision so a cursor resumes exactly where it left off.
+func compareValues(a, b interface{}) int {
+	ra, rb := typeRank(a), typeRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+
+	switch ra {
+	case rankNumber:
+		x, _ := toNumber(a)
+		y, _ := toNumber(b)
+		return compareNumbers(x, y)
+	case rankString:
+		return strings.Compare(a.(string), b.(string))
+	case rankBool:
+		x, y := a.(bool), b.(bool)
+		if x == y {
+			return 0
+		}
+		if !x {
+			return -1
+		}
+		return 1
+	case rankDate:
+		return compareNumbers(float64(toDateTime(a)), float64(toDateTime(b)))
+	}
+	return 0
+}
+
+// Sort ranks of BSON types
+const (
+	rankNull = iota
+	rankNumber
+	rankString
+	rankDocument
+	rankArray
+	rankBool
+	rankDate
+)
+
+func typeRank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return rankNull
+	case string:
+		return rankString
+	case bool:
+		return rankBool
+	case time.Time, primitive.DateTime:
+		return rankDate
+	case map[string]interface{}, primitive.M, primitive.D:
+		return rankDocument
+	case []interface{}, primitive.A, []string:
+		return rankArray
+	}
+	if _, ok := toNumber(v); ok {
+		return rankNumber
+	}
+	return rankDocument
+}
+
+func toDateTime(v interface{}) primitive.DateTime {
+	if t, ok := v.(time.Time); ok {
+		return primitive.NewDateTimeFromTime(t)
+	}
+	return v.(primitive.DateTime)
+}
+
+func compareNumbers(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"testing"
+
+	"form-builder-backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const demoFormID = "6789c7f2e8b9a0d1e2f3a4b5"
+
+func addResponses(t *testing.T, s *MemoryStore, data ...map[string]interface{}) {
+	t.Helper()
+	formID, _ := primitive.ObjectIDFromHex(demoFormID)
+	for _, d := range data {
+		if err := s.CreateResponse(&models.FormResponse{FormID: formID, Data: d}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListResponsesPagesInSortOrder(t *testing.T) {
+	s := NewMemoryStore()
+	addResponses(t, s,
+		map[string]interface{}{"rating": 3.0},
+		map[string]interface{}{"rating": 1.0},
+		map[string]interface{}{"rating": "n/a"},
+		map[string]interface{}{},
+		map[string]interface{}{"rating": 2.0},
+	)
+
+	query := models.ResponseListQuery{Sort: "rating", Limit: 2}
+	var got []interface{}
+	for pages := 0; ; pages++ {
+		if pages == 3 {
+			t.Fatal("listing didn't end after 3 pages")
+		}
+		page, err := s.ListResponses(demoFormID, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total != 5 {
+			t.Errorf("Total = %d, want 5", page.Total)
+		}
+		for _, resp := range page.Responses {
+			got = append(got, resp.Data["rating"])
+		}
+		if !page.HasMore {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	// Mongo's order: missing, then numbers, then strings
+	want := []interface{}{nil, 1.0, 2.0, 3.0, "n/a"}
+	if len(got) != len(want) {
+		t.Fatalf("listed %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("listed %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+func TestListResponsesFiltersFields(t *testing.T) {
+	s := NewMemoryStore()
+	addResponses(t, s,
+		map[string]interface{}{"feedback": "Great SERVICE", "rating": 5.0},
+		map[string]interface{}{"feedback": "slow service", "rating": 2.0},
+		map[string]interface{}{"feedback": "fine", "rating": 4.0},
+	)
+	min := 3.0
+	query := models.ResponseListQuery{Sort: models.SortByCreatedAt, Criteria: models.ResponseCriteria{Fields: []models.FieldCriterion{
+		{FieldID: "feedback", Op: models.FieldOpContains, Value: "service"},
+		{FieldID: "rating", Op: models.FieldOpRange, Min: &min},
+	}}}
+	page, err := s.ListResponses(demoFormID, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Responses[0].Data["feedback"] != "Great SERVICE" {
+		t.Errorf("listed %+v, want only the matching response", page.Responses)
+	}
+}
+
+func TestListResponsesSearch(t *testing.T) {
+	s := NewMemoryStore()
+	addResponses(t, s,
+		map[string]interface{}{"name": "Jane Doe", "feedback": "Paid the invoice"},
+		map[string]interface{}{"name": "Jane Roe", "feedback": "No invoice yet"},
+		map[string]interface{}{"name": "Sam Invoice", "feedback": "All good"},
+	)
+
+	page, err := s.ListResponses(demoFormID, models.ResponseListQuery{Sort: models.SortByCreatedAt, Search: []string{"invoice", "paid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 1 || page.Responses[0].Data["name"] != "Jane Doe" {
+		t.Fatalf("listed %+v, want the response with both terms", page.Responses)
+	}
+	if snippets := page.Highlights[page.Responses[0].ID.Hex()]; len(snippets) != 1 || snippets[0].FieldID != "feedback" {
+		t.Errorf("highlights = %+v, want one feedback snippet", snippets)
+	}
+
+	// Answers to a field marked PII leave the index
+	form, _ := s.GetForm(demoFormID)
+	fields := append([]models.FormField(nil), form.Fields...)
+	for i := range fields {
+		if fields[i].ID == "name" {
+			fields[i].PII = true
+		}
+	}
+	if _, err := s.UpdateForm(demoFormID, map[string]interface{}{"fields": fields}); err != nil {
+		t.Fatal(err)
+	}
+	page, err = s.ListResponses(demoFormID, models.ResponseListQuery{Sort: models.SortByCreatedAt, Search: []string{"jane"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 0 {
+		t.Errorf("search for a PII answer listed %d responses, want none", page.Total)
+	}
+}
@@ -0,0 +1,137 @@
+// Package useragent classifies HTTP User-Agent strings by device, browser and
+// operating system, and recognizes crawlers and scripted clients.
+package useragent
+
+import "strings"
+
+// Device classes
+const (
+	DeviceDesktop = "Desktop"
+	DeviceMobile  = "Mobile"
+	DeviceTablet  = "Tablet"
+	DeviceOther   = "Other"
+)
+
+// Other is reported for browsers and operating systems that aren't recognized
+const Other = "Other"
+
+// Info is what a user agent says about the client
+type Info struct {
+	Device  string `json:"device"`
+	Browser string `json:"browser"`
+	OS      string `json:"os"`
+	Bot     bool   `json:"bot"`
+}
+
+// Parse classifies a user agent. An empty user agent is Other throughout.
+func Parse(userAgent string) Info {
+	ua := strings.ToLower(userAgent)
+	info := Info{
+		Device:  DeviceOther,
+		Browser: Other,
+		OS:      Other,
+	}
+	if ua == "" {
+		return info
+	}
+
+	info.Bot = isBot(ua)
+	info.OS = parseOS(ua)
+	info.Browser = parseBrowser(ua)
+	if !info.Bot {
+		info.Device = parseDevice(ua, info.OS)
+	}
+	return info
+}
+
+// botTokens appear in the user agents of crawlers, link previewers, HTTP
+// libraries, and the uptime monitors and load balancer health checks that
+// poll the status endpoint. Monitors are matched by name: a bare "monitor"
+// would also catch ordinary browsers whose user agents mention one.
+var botTokens = []string{
+	"bot", "crawl", "spider", "slurp", "scraper", "archiver", "facebookexternalhit", "embedly",
+	"whatsapp", "headlesschrome", "phantomjs", "lighthouse", "pagespeed", "pingdom", "uptime",
+	"statuscake", "site24x7", "newrelicpinger", "checkly", "freshping", "kube-probe",
+	"elb-healthchecker", "googlehc", "healthcheck", "health-check",
+	"curl/", "wget/", "python-requests", "python-urllib", "aiohttp", "go-http-client",
+	"okhttp", "axios/", "node-fetch", "undici", "java/", "apache-httpclient", "libwww", "httpie",
+	"postman", "insomnia",
+}
+
+func isBot(ua string) bool {
+	return containsAny(ua, botTokens...)
+}
+
+func parseOS(ua string) string {
+	switch {
+	case strings.Contains(ua, "windows phone"):
+		return "Windows Phone"
+	case strings.Contains(ua, "windows"):
+		return "Windows"
+	case strings.Contains(ua, "ipad"):
+		return "iPadOS"
+	case containsAny(ua, "iphone", "ipod"):
+		return "iOS"
+	case strings.Contains(ua, "cros "):
+		return "ChromeOS"
+	case strings.Contains(ua, "android"):
+		return "Android"
+	case containsAny(ua, "mac os x", "macintosh"):
+		return "macOS"
+	case containsAny(ua, "linux", "x11", "freebsd", "openbsd"):
+		return "Linux"
+	}
+	return Other
+}
+
+// parseBrowser checks the browsers built on Chrome or Safari before those
+// engines, since their user agents carry the Chrome and Safari tokens too
+func parseBrowser(ua string) string {
+	switch {
+	case containsAny(ua, "edg/", "edga/", "edgios/", "edge/"):
+		return "Edge"
+	case containsAny(ua, "opr/", "opera", "opios/"):
+		return "Opera"
+	case strings.Contains(ua, "samsungbrowser/"):
+		return "Samsung Internet"
+	case strings.Contains(ua, "yabrowser/"):
+		return "Yandex"
+	case strings.Contains(ua, "ucbrowser/"):
+		return "UC Browser"
+	case strings.Contains(ua, "vivaldi/"):
+		return "Vivaldi"
+	case containsAny(ua, "firefox/", "fxios/"):
+		return "Firefox"
+	case containsAny(ua, "chrome/", "crios/", "chromium/"):
+		return "Chrome"
+	case containsAny(ua, "msie ", "trident/"):
+		return "Internet Explorer"
+	case strings.Contains(ua, "safari/") && strings.Contains(ua, "version/"):
+		return "Safari"
+	}
+	return Other
+}
+
+// parseDevice checks tablets first: Android tablets omit the "Mobile" token
+// phones carry, and some tablet user agents include "Mobile" anyway
+func parseDevice(ua, os string) string {
+	switch {
+	case containsAny(ua, "ipad", "tablet", "kindle", "silk/", "playbook"),
+		os == "Android" && !strings.Contains(ua, "mobile"):
+		return DeviceTablet
+	case containsAny(ua, "mobi", "iphone", "ipod", "windows phone", "opera mini", "blackberry"):
+		return DeviceMobile
+	case os == "Windows" || os == "macOS" || os == "Linux" || os == "ChromeOS":
+		return DeviceDesktop
+	}
+	return DeviceOther
+}
+
+func containsAny(s string, substrings ...string) bool {
+	for _, substr := range substrings {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package useragent
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want Info
+	}{
+		{
+			// Carries "Mobile", which used to be checked before the tablet
+			// tokens and made every iPad a phone
+			"iPad Safari",
+			"Mozilla/5.0 (iPad; CPU OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+			Info{Device: DeviceTablet, Browser: "Safari", OS: "iPadOS"},
+		},
+		{
+			"iPad Chrome",
+			"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/119.0.6045.109 Mobile/15E148 Safari/604.1",
+			Info{Device: DeviceTablet, Browser: "Chrome", OS: "iPadOS"},
+		},
+		{
+			"Android tablet Chrome",
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
+			Info{Device: DeviceTablet, Browser: "Chrome", OS: "Android"},
+		},
+		{
+			"Android tablet Firefox",
+			"Mozilla/5.0 (Android 13; Tablet; rv:120.0) Gecko/120.0 Firefox/120.0",
+			Info{Device: DeviceTablet, Browser: "Firefox", OS: "Android"},
+		},
+		{
+			"Android tablet Samsung Internet",
+			"Mozilla/5.0 (Linux; Android 13; SAMSUNG SM-X810) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Safari/537.36",
+			Info{Device: DeviceTablet, Browser: "Samsung Internet", OS: "Android"},
+		},
+		{
+			"Kindle Fire Silk",
+			"Mozilla/5.0 (Linux; Android 9; KFTRWI) AppleWebKit/537.36 (KHTML, like Gecko) Silk/119.3.1 like Chrome/119.0.6045.193 Safari/537.36",
+			Info{Device: DeviceTablet, Browser: "Chrome", OS: "Android"},
+		},
+		{
+			"Android phone Chrome",
+			"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			Info{Device: DeviceMobile, Browser: "Chrome", OS: "Android"},
+		},
+		{
+			"Android phone Firefox",
+			"Mozilla/5.0 (Android 14; Mobile; rv:121.0) Gecko/121.0 Firefox/121.0",
+			Info{Device: DeviceMobile, Browser: "Firefox", OS: "Android"},
+		},
+		{
+			"Android phone Samsung Internet",
+			"Mozilla/5.0 (Linux; Android 13; SAMSUNG SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			Info{Device: DeviceMobile, Browser: "Samsung Internet", OS: "Android"},
+		},
+		{
+			"iPhone Safari",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1",
+			Info{Device: DeviceMobile, Browser: "Safari", OS: "iOS"},
+		},
+		{
+			"iPhone Firefox",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/120.0 Mobile/15E148 Safari/605.1.15",
+			Info{Device: DeviceMobile, Browser: "Firefox", OS: "iOS"},
+		},
+		{
+			"Windows Edge",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+			Info{Device: DeviceDesktop, Browser: "Edge", OS: "Windows"},
+		},
+		{
+			"Windows Opera",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 OPR/105.0.0.0",
+			Info{Device: DeviceDesktop, Browser: "Opera", OS: "Windows"},
+		},
+		{
+			// iPadOS Safari asks for desktop sites and can't be told apart
+			"Mac Safari",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15",
+			Info{Device: DeviceDesktop, Browser: "Safari", OS: "macOS"},
+		},
+		{
+			"Linux Firefox",
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0",
+			Info{Device: DeviceDesktop, Browser: "Firefox", OS: "Linux"},
+		},
+		{
+			"ChromeOS Chrome",
+			"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Info{Device: DeviceDesktop, Browser: "Chrome", OS: "ChromeOS"},
+		},
+		{
+			"Internet Explorer 11",
+			"Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			Info{Device: DeviceDesktop, Browser: "Internet Explorer", OS: "Windows"},
+		},
+		{
+			"Googlebot",
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Info{Device: DeviceOther, Browser: Other, OS: Other, Bot: true},
+		},
+		{
+			"Googlebot smartphone",
+			"Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.6045.199 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Info{Device: DeviceOther, Browser: "Chrome", OS: "Android", Bot: true},
+		},
+		{
+			"Bingbot",
+			"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+			Info{Device: DeviceOther, Browser: Other, OS: Other, Bot: true},
+		},
+		{
+			"Facebook link preview",
+			"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			Info{Device: DeviceOther, Browser: Other, OS: Other, Bot: true},
+		},
+		{
+			"headless Chrome",
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36",
+			Info{Device: DeviceOther, Browser: "Chrome", OS: "Linux", Bot: true},
+		},
+		{
+			"UptimeRobot",
+			"Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)",
+			Info{Device: DeviceOther, Browser: Other, OS: Other, Bot: true},
+		},
+		{
+			"Kubernetes probe",
+			"kube-probe/1.28",
+			Info{Device: DeviceOther, Browser: Other, OS: Other, Bot: true},
+		},
+		{
+			"curl",
+			"curl/8.4.0",
+			Info{Device: DeviceOther, Browser: Other, OS: Other, Bot: true},
+		},
+		{
+			"Python requests",
+			"python-requests/2.31.0",
+			Info{Device: DeviceOther, Browser: Other, OS: Other, Bot: true},
+		},
+		{
+			"empty",
+			"",
+			Info{Device: DeviceOther, Browser: Other, OS: Other},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.ua); got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.ua, got, tt.want)
+			}
+		})
+	}
+}
@@ -14,6 +14,11 @@ const (
 	MessageTypeNewResponse = "new_response"
 	MessageTypeAnalyticsUpdate = "analytics_update"
 	MessageTypeHeartbeat = "heartbeat"
+	MessageTypeAlert = "alert"
+	MessageTypeResponseDeleted = "response_deleted"
+	MessageTypeResponseUpdated = "response_updated"
+	MessageTypeExportProgress = "export_progress"
+	MessageTypeResponsesImported = "responses_imported"
 )
 
 // Message represents a WebSocket message
@@ -0,0 +1,161 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		i    int
+		want string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+		{16383, "XFD"}, // Excel's last column
+	}
+	for _, tt := range tests {
+		if got := ColumnName(tt.i); got != tt.want {
+			t.Errorf("ColumnName(%d) = %s, want %s", tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	short := strings.Repeat("a", MaxCellLength)
+	if got := truncate(short); got != short {
+		t.Errorf("truncate cut a cell of exactly MaxCellLength")
+	}
+	long := strings.Repeat("é", MaxCellLength+10)
+	got := truncate(long)
+	if n := len([]rune(got)); n != MaxCellLength {
+		t.Errorf("truncate kept %d characters, want %d", n, MaxCellLength)
+	}
+	if !strings.HasPrefix(long, got) {
+		t.Errorf("truncate split a character")
+	}
+}
+
+// sheetXML is the part of a worksheet the tests read back
+type sheetXML struct {
+	Rows []struct {
+		R     string `xml:"r,attr"`
+		Cells []struct {
+			R      string `xml:"r,attr"`
+			S      string `xml:"s,attr"`
+			T      string `xml:"t,attr"`
+			V      string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Responses & more")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader([]string{"Name", "Score"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow([]interface{}{"<Jane> & \"Co\"", 4.5, int64(7), nil, "", true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("workbook is not a valid zip: %v", err)
+	}
+	parts := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = b
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook is missing %s", name)
+		}
+	}
+	for name, b := range parts {
+		if err := xml.Unmarshal(b, new(interface{})); err != nil {
+			t.Errorf("%s is not well-formed XML: %v", name, err)
+		}
+	}
+	if !bytes.Contains(parts["xl/workbook.xml"], []byte(`name="Responses &amp; more"`)) {
+		t.Errorf("sheet name not escaped: %s", parts["xl/workbook.xml"])
+	}
+
+	var sheet sheetXML
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(sheet.Rows))
+	}
+	header, row := sheet.Rows[0], sheet.Rows[1]
+	if header.R != "1" || len(header.Cells) != 2 || header.Cells[0].S != "1" || header.Cells[1].Inline != "Score" {
+		t.Errorf("header row = %+v", header)
+	}
+	if row.R != "2" || len(row.Cells) != 3 {
+		t.Fatalf("data row should have 3 non-empty cells, got %+v", row)
+	}
+	if c := row.Cells[0]; c.R != "A2" || c.T != "inlineStr" || c.Inline != `<Jane> & "Co"` || c.S != "" {
+		t.Errorf("string cell = %+v", c)
+	}
+	if c := row.Cells[1]; c.R != "B2" || c.V != "4.5" {
+		t.Errorf("float cell = %+v", c)
+	}
+	if c := row.Cells[2]; c.R != "C2" || c.V != "7" {
+		t.Errorf("int cell = %+v", c)
+	}
+}
+
+func TestWriterRowLimit(t *testing.T) {
+	w, err := NewWriter(io.Discard, "Sheet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.rows = MaxRows
+	if err := w.WriteRow([]interface{}{"x"}); err != ErrTooManyRows {
+		t.Errorf("WriteRow past the last row = %v, want ErrTooManyRows", err)
+	}
+}
+
+func TestUnclosedWorkbookIsUnreadable(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "Sheet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteRow([]interface{}{"partial"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err == nil {
+		t.Error("a workbook cut short before Close opened as a valid zip")
+	}
+}
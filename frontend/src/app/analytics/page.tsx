@@ -390,6 +390,10 @@ export default function AnalyticsPage() {
                   <div className="space-y-1 text-sm font-mono">
                     <div>• new_response - New form submission</div>
                     <div>• analytics_update - Updated analytics data</div>
+                    <div>• alert - Unusual submission spike or drop</div>
+                    <div>• response_updated - Tags, status, assignee or notes changed</div>
+                    <div>• export_progress - Background export status</div>
+                    <div>• responses_imported - Historical responses loaded</div>
                     <div>• heartbeat - Keep-alive signal</div>
                     <div>• subscribe/unsubscribe - Channel management</div>
                   </div>
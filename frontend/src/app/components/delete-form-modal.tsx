@@ -38,8 +38,9 @@ export function DeleteFormModal({
             <span className="font-medium text-foreground">&ldquo;{formTitle}&rdquo;</span>?
           </p>
           <p className="text-sm text-muted-foreground">
-            This action cannot be undone. All responses and analytics data will
-            be permanently deleted.
+            The form stops accepting responses and moves to the trash. It can
+            be restored for 30 days, after which all responses and analytics
+            data are permanently deleted.
           </p>
 
           <div className="flex justify-end gap-2 pt-4">
@@ -22,6 +22,7 @@ interface FormFieldData {
   placeholder?: string;
   required: boolean;
   options?: string[];
+  pii?: boolean;
 }
 
 interface FormBuilderProps {
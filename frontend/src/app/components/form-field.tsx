@@ -18,6 +18,7 @@ interface FormFieldData {
   placeholder?: string;
   required: boolean;
   options?: string[];
+  pii?: boolean;
 }
 
 interface FormFieldProps {
@@ -187,6 +188,19 @@ export function FormField({
               />
             </div>
 
+            <div className="flex items-center justify-between">
+              <Label htmlFor={`pii-${field.id}`}>
+                Personal data (encrypted, masked in live views)
+              </Label>
+              <Switch
+                id={`pii-${field.id}`}
+                checked={!!field.pii}
+                onCheckedChange={(checked: boolean) =>
+                  onUpdate({ pii: checked })
+                }
+              />
+            </div>
+
             {(field.type === "text" || field.type === "textarea") && (
               <div>
                 <Label>Placeholder text</Label>
@@ -24,6 +24,7 @@ import { Plus, Search, Filter, Grid, List } from "lucide-react";
 import { useForms } from "@/hooks/use-forms";
 import { Form } from "@/lib/api";
 import { useToast } from "@/hooks/use-toast";
+import { ToastAction } from "@/app/components/ui/toast";
 
 interface FormsDashboardProps {
   onFormSelect: (form: Form) => void;
@@ -55,6 +56,7 @@ export function FormsDashboard({
     error,
     createForm,
     deleteForm,
+    restoreForm,
     duplicateForm,
     publishForm,
     unpublishForm,
@@ -125,7 +127,12 @@ export function FormsDashboard({
       setFormToDelete(null);
       toast({
         title: "Success",
-        description: "Form deleted successfully",
+        description: "Form moved to trash",
+        action: (
+          <ToastAction altText="Undo" onClick={() => handleRestoreForm(formId)}>
+            Undo
+          </ToastAction>
+        ),
       });
     } catch {
       toast({
@@ -136,6 +143,22 @@ export function FormsDashboard({
     }
   };
 
+  const handleRestoreForm = async (formId: string) => {
+    try {
+      await restoreForm(formId);
+      toast({
+        title: "Success",
+        description: "Form restored",
+      });
+    } catch {
+      toast({
+        variant: "destructive",
+        title: "Error",
+        description: "Failed to restore form",
+      });
+    }
+  };
+
   const handleStatusChange = async (
     formId: string,
     newStatus: "draft" | "published" | "archived"
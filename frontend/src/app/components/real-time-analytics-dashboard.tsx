@@ -119,6 +119,20 @@ export function RealTimeAnalyticsDashboard({
     onAnalyticsUpdate: useCallback(() => {
       console.log("Analytics updated in real-time");
     }, []),
+    onAlert: useCallback(
+      (alert) => {
+        toast({
+          title:
+            alert.kind === "spike"
+              ? "⚠️ Submission spike"
+              : "⚠️ Submissions dropped",
+          description: `${alert.message}. ${alert.observed} in the last hour, usually ${alert.expected.toFixed(1)}.`,
+          variant: "destructive",
+          duration: 10000,
+        });
+      },
+      [toast]
+    ),
   });
 
   // Handle connection toggle
@@ -1,6 +1,6 @@
 "use client";
 
-import { useState, useEffect } from "react";
+import { useState, useEffect, useRef } from "react";
 import {
   Card,
   CardContent,
@@ -9,6 +9,21 @@ import {
 } from "@/app/components/ui/card";
 import { Button } from "@/app/components/ui/button";
 import { Badge } from "@/app/components/ui/badge";
+import { Input } from "@/app/components/ui/input";
+import { Textarea } from "@/app/components/ui/textarea";
+import {
+  Select,
+  SelectContent,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from "@/app/components/ui/select";
+import {
+  Dialog,
+  DialogContent,
+  DialogHeader,
+  DialogTitle,
+} from "@/app/components/ui/dialog";
 import {
   Table,
   TableBody,
@@ -18,8 +33,28 @@ import {
   TableRow,
 } from "@/app/components/ui/table";
 import { ScrollArea } from "@/app/components/ui/scroll-area";
-import { Download, Eye, Calendar, User, Globe } from "lucide-react";
-import { Form, FormResponse, apiService } from "@/lib/api";
+import {
+  Download,
+  Eye,
+  Calendar,
+  User,
+  Globe,
+  Search,
+  Trash2,
+  AlertTriangle,
+  FileText,
+  Upload,
+  MessageSquare,
+} from "lucide-react";
+import {
+  ExportJob,
+  Form,
+  ImportResult,
+  ReviewStatus,
+  FormResponse,
+  Snippet,
+  apiService,
+} from "@/lib/api";
 import { useToast } from "@/hooks/use-toast";
 import { format } from "date-fns";
 
@@ -28,22 +63,54 @@ interface ResponsesViewProps {
   onBack: () => void;
 }
 
+const reviewStatusLabels: Record<ReviewStatus, string> = {
+  new: "New",
+  in_review: "In review",
+  resolved: "Resolved",
+};
+
 export function ResponsesView({ form, onBack }: ResponsesViewProps) {
   const [responses, setResponses] = useState<FormResponse[]>([]);
+  const [total, setTotal] = useState(0);
+  const [nextCursor, setNextCursor] = useState<string | undefined>();
   const [loading, setLoading] = useState(true);
+  const [loadingMore, setLoadingMore] = useState(false);
+  const [searchInput, setSearchInput] = useState("");
+  const [search, setSearch] = useState("");
+  const [statusFilter, setStatusFilter] = useState<ReviewStatus | "all">(
+    "all"
+  );
+  const [noteText, setNoteText] = useState("");
+  const [highlights, setHighlights] = useState<Record<string, Snippet[]>>({});
+  const [summaryJob, setSummaryJob] = useState<ExportJob | null>(null);
+  const [importing, setImporting] = useState(false);
+  const [importResult, setImportResult] = useState<ImportResult | null>(null);
+  const [reloadKey, setReloadKey] = useState(0);
+  const importInputRef = useRef<HTMLInputElement>(null);
+  const [responseToDelete, setResponseToDelete] =
+    useState<FormResponse | null>(null);
   const [error, setError] = useState<string | null>(null);
   const [selectedResponse, setSelectedResponse] = useState<FormResponse | null>(
     null
   );
   const { toast } = useToast();
 
+  const listFilters: Record<string, string> =
+    statusFilter === "all" ? {} : { status: statusFilter };
+
   useEffect(() => {
     const loadResponses = async () => {
       try {
         setLoading(true);
         setError(null);
-        const data = await apiService.getFormResponses(form.id);
-        setResponses(data);
+        const page = await apiService.getFormResponses(form.id, {
+          search,
+          filters: listFilters,
+        });
+        setResponses(page.responses);
+        setTotal(page.total);
+        setNextCursor(page.nextCursor);
+        setHighlights(page.highlights ?? {});
       } catch (err) {
         setError(
           err instanceof Error ? err.message : "Failed to load responses"
@@ -54,59 +121,160 @@ export function ResponsesView({ form, onBack }: ResponsesViewProps) {
     };
 
     loadResponses();
-  }, [form.id]);
+  // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [form.id, search, statusFilter, reloadKey]);
 
-  const exportToCSV = () => {
-    if (responses.length === 0) {
+  const loadMore = async () => {
+    if (!nextCursor) return;
+    try {
+      setLoadingMore(true);
+      const page = await apiService.getFormResponses(form.id, {
+        search,
+        filters: listFilters,
+        cursor: nextCursor,
+      });
+      setResponses((prev) => [...prev, ...page.responses]);
+      setTotal(page.total);
+      setNextCursor(page.nextCursor);
+      setHighlights((prev) => ({ ...prev, ...page.highlights }));
+    } catch (err) {
       toast({
         variant: "destructive",
-        title: "No data to export",
-        description: "There are no responses to export yet.",
+        title: "Failed to load responses",
+        description: err instanceof Error ? err.message : undefined,
       });
-      return;
+    } finally {
+      setLoadingMore(false);
     }
+  };
 
-    // Get all unique field IDs from all responses
-    const allFieldIds = new Set<string>();
-    responses.forEach((response) => {
-      Object.keys(response.data).forEach((fieldId) => allFieldIds.add(fieldId));
-    });
+  const handleDeleteResponse = async (response: FormResponse) => {
+    try {
+      await apiService.deleteResponse(response.id);
+      setResponses((prev) => prev.filter((r) => r.id !== response.id));
+      setTotal((prev) => prev - 1);
+      setResponseToDelete(null);
+      setSelectedResponse(null);
+      toast({
+        title: "Success",
+        description: "Response deleted successfully",
+      });
+    } catch {
+      toast({
+        variant: "destructive",
+        title: "Error",
+        description: "Failed to delete response",
+      });
+    }
+  };
+
+  // applyUpdate shows a response's new review state in the list and detail
+  const applyUpdate = (updated: FormResponse) => {
+    setResponses((prev) =>
+      prev.map((r) => (r.id === updated.id ? { ...r, ...updated } : r))
+    );
+    setSelectedResponse((prev) =>
+      prev && prev.id === updated.id ? { ...prev, ...updated } : prev
+    );
+  };
+
+  const handleReviewUpdate = async (
+    response: FormResponse,
+    updates: { status?: ReviewStatus; assignee?: string; tags?: string[] }
+  ) => {
+    try {
+      applyUpdate(await apiService.updateResponse(response.id, updates));
+    } catch (err) {
+      toast({
+        variant: "destructive",
+        title: "Failed to update response",
+        description: err instanceof Error ? err.message : undefined,
+      });
+    }
+  };
+
+  const handleAddNote = async (response: FormResponse) => {
+    const text = noteText.trim();
+    if (!text) return;
+    try {
+      const note = await apiService.addResponseNote(response.id, text);
+      applyUpdate({ ...response, notes: [...(response.notes ?? []), note] });
+      setNoteText("");
+    } catch (err) {
+      toast({
+        variant: "destructive",
+        title: "Failed to add note",
+        description: err instanceof Error ? err.message : undefined,
+      });
+    }
+  };
+
+  const handleDeleteNote = async (response: FormResponse, noteId: string) => {
+    try {
+      await apiService.deleteResponseNote(response.id, noteId);
+      applyUpdate({
+        ...response,
+        notes: (response.notes ?? []).filter((note) => note.id !== noteId),
+      });
+    } catch {
+      toast({
+        variant: "destructive",
+        title: "Error",
+        description: "Failed to delete note",
+      });
+    }
+  };
+
+  const deleteDialog = (
+    <Dialog
+      open={!!responseToDelete}
+      onOpenChange={() => setResponseToDelete(null)}
+    >
+      <DialogContent className="sm:max-w-md">
+        <DialogHeader>
+          <DialogTitle className="font-inter flex items-center gap-2">
+            <AlertTriangle className="h-5 w-5 text-destructive" />
+            Delete Response
+          </DialogTitle>
+        </DialogHeader>
+
+        <div className="space-y-4">
+          <p className="text-sm text-muted-foreground">
+            This action cannot be undone. The response will be removed from
+            the list and from analytics.
+          </p>
 
-    // Create headers
-    const fieldHeaders = form.fields
-      .filter((field) => allFieldIds.has(field.id))
-      .map((field) => field.label);
-    const headers = ["Submitted At", "IP Address", ...fieldHeaders];
-
-    // Create CSV content
-    const csvContent = [
-      headers.join(","),
-      ...responses.map((response) => {
-        const values = [
-          `"${format(new Date(response.createdAt), "yyyy-MM-dd HH:mm:ss")}"`,
-          `"${response.ipAddress}"`,
-          ...form.fields
-            .filter((field) => allFieldIds.has(field.id))
-            .map((field) => {
-              const value = response.data[field.id];
-              if (Array.isArray(value)) {
-                return `"${value.join(", ")}"`;
+          <div className="flex justify-end gap-2 pt-4">
+            <Button
+              variant="outline"
+              onClick={() => setResponseToDelete(null)}
+              className="bg-transparent"
+            >
+              Cancel
+            </Button>
+            <Button
+              variant="destructive"
+              onClick={() =>
+                responseToDelete && handleDeleteResponse(responseToDelete)
               }
-              return `"${value || ""}"`;
-            }),
-        ];
-        return values.join(",");
-      }),
-    ].join("\n");
-
-    // Download file
-    const blob = new Blob([csvContent], { type: "text/csv;charset=utf-8;" });
+            >
+              Delete Response
+            </Button>
+          </div>
+        </div>
+      </DialogContent>
+    </Dialog>
+  );
+
+  const exportResponses = (exportFormat: "csv" | "xlsx") => {
+    // The server streams the file, so large exports never load in the page
     const link = document.createElement("a");
-    const url = URL.createObjectURL(blob);
-    link.setAttribute("href", url);
     link.setAttribute(
-      "download",
-      `${form.title.replace(/[^a-z0-9]/gi, "_").toLowerCase()}_responses.csv`
+      "href",
+      apiService.getResponsesExportUrl(form.id, exportFormat, {
+        search,
+        filters: listFilters,
+      })
     );
     link.style.visibility = "hidden";
     document.body.appendChild(link);
@@ -114,11 +282,87 @@ export function ResponsesView({ form, onBack }: ResponsesViewProps) {
     document.body.removeChild(link);
 
     toast({
-      title: "Export successful",
-      description: `Exported ${responses.length} responses to CSV`,
+      title: "Export started",
+      description: `Downloading ${total} responses as ${exportFormat.toUpperCase()}`,
     });
   };
 
+  const handleImport = async (file: File) => {
+    try {
+      setImporting(true);
+      const result = await apiService.importResponses(form.id, file);
+      setImportResult(result);
+      if (result.imported > 0) setReloadKey((key) => key + 1);
+    } catch (err) {
+      toast({
+        variant: "destructive",
+        title: "Import failed",
+        description: err instanceof Error ? err.message : undefined,
+      });
+    } finally {
+      setImporting(false);
+      if (importInputRef.current) importInputRef.current.value = "";
+    }
+  };
+
+  const importDialog = (
+    <Dialog open={!!importResult} onOpenChange={() => setImportResult(null)}>
+      <DialogContent className="sm:max-w-lg">
+        <DialogHeader>
+          <DialogTitle className="font-inter">Import Complete</DialogTitle>
+        </DialogHeader>
+        {importResult && (
+          <div className="space-y-4">
+            <p className="text-sm text-muted-foreground">
+              Imported {importResult.imported} of {importResult.rows} rows.
+              {importResult.failed > 0 &&
+                ` ${importResult.failed} rows were skipped.`}
+            </p>
+            {importResult.errors.length > 0 && (
+              <ScrollArea className="h-48 rounded border p-2">
+                {importResult.errors.map((rowError) => (
+                  <div key={rowError.row} className="text-sm">
+                    <span className="font-medium">Row {rowError.row}:</span>{" "}
+                    {rowError.error}
+                  </div>
+                ))}
+              </ScrollArea>
+            )}
+            <div className="flex justify-end pt-2">
+              <Button onClick={() => setImportResult(null)}>Close</Button>
+            </div>
+          </div>
+        )}
+      </DialogContent>
+    </Dialog>
+  );
+
+  // exportSummary runs a PDF summary as a background export and downloads it
+  // once the job completes
+  const exportSummary = async () => {
+    try {
+      let job = await apiService.createExport(form.id, "pdf");
+      setSummaryJob(job);
+      while (job.status === "queued" || job.status === "running") {
+        await new Promise((resolve) => setTimeout(resolve, 2000));
+        job = await apiService.getExport(form.id, job.id);
+        setSummaryJob(job);
+      }
+      if (job.status !== "completed") {
+        throw new Error(job.error || "The export did not complete");
+      }
+      window.location.href = apiService.getExportDownloadUrl(form.id, job.id);
+    } catch (err) {
+      toast({
+        variant: "destructive",
+        title: "Export failed",
+        description: err instanceof Error ? err.message : undefined,
+      });
+    } finally {
+      setSummaryJob(null);
+    }
+  };
+
   if (loading) {
     return (
       <div className="p-6">
@@ -170,11 +414,23 @@ export function ResponsesView({ form, onBack }: ResponsesViewProps) {
           >
             ← Back to Responses
           </Button>
-          <h2 className="text-2xl font-bold">Response Details</h2>
-          <p className="text-muted-foreground">
-            Submitted on{" "}
-            {format(new Date(selectedResponse.createdAt), "PPP at pp")}
-          </p>
+          <div className="flex items-center justify-between">
+            <div>
+              <h2 className="text-2xl font-bold">Response Details</h2>
+              <p className="text-muted-foreground">
+                Submitted on{" "}
+                {format(new Date(selectedResponse.createdAt), "PPP at pp")}
+              </p>
+            </div>
+            <Button
+              variant="destructive"
+              onClick={() => setResponseToDelete(selectedResponse)}
+              className="gap-2"
+            >
+              <Trash2 className="h-4 w-4" />
+              Delete
+            </Button>
+          </div>
         </div>
 
         <div className="grid gap-6">
@@ -205,6 +461,115 @@ export function ResponsesView({ form, onBack }: ResponsesViewProps) {
             </CardContent>
           </Card>
 
+          <Card>
+            <CardHeader>
+              <CardTitle className="flex items-center gap-2">
+                <MessageSquare className="h-5 w-5" />
+                Review
+              </CardTitle>
+            </CardHeader>
+            <CardContent className="space-y-4">
+              <div className="grid gap-4 sm:grid-cols-3">
+                <div className="space-y-1">
+                  <span className="text-sm text-muted-foreground">Status</span>
+                  <Select
+                    value={selectedResponse.status ?? "new"}
+                    onValueChange={(status) =>
+                      handleReviewUpdate(selectedResponse, {
+                        status: status as ReviewStatus,
+                      })
+                    }
+                  >
+                    <SelectTrigger>
+                      <SelectValue />
+                    </SelectTrigger>
+                    <SelectContent>
+                      {Object.entries(reviewStatusLabels).map(
+                        ([status, label]) => (
+                          <SelectItem key={status} value={status}>
+                            {label}
+                          </SelectItem>
+                        )
+                      )}
+                    </SelectContent>
+                  </Select>
+                </div>
+                <div className="space-y-1">
+                  <span className="text-sm text-muted-foreground">
+                    Assignee
+                  </span>
+                  <Input
+                    key={`assignee-${selectedResponse.id}`}
+                    defaultValue={selectedResponse.assignee ?? ""}
+                    placeholder="Unassigned"
+                    onBlur={(e) => {
+                      const assignee = e.target.value.trim();
+                      if (assignee !== (selectedResponse.assignee ?? "")) {
+                        handleReviewUpdate(selectedResponse, { assignee });
+                      }
+                    }}
+                  />
+                </div>
+                <div className="space-y-1">
+                  <span className="text-sm text-muted-foreground">Tags</span>
+                  <Input
+                    key={`tags-${selectedResponse.id}`}
+                    defaultValue={(selectedResponse.tags ?? []).join(", ")}
+                    placeholder="Comma-separated"
+                    onBlur={(e) => {
+                      const tags = e.target.value
+                        .split(",")
+                        .map((tag) => tag.trim())
+                        .filter(Boolean);
+                      if (
+                        tags.join(",") !== (selectedResponse.tags ?? []).join(",")
+                      ) {
+                        handleReviewUpdate(selectedResponse, { tags });
+                      }
+                    }}
+                  />
+                </div>
+              </div>
+
+              <div className="space-y-2">
+                <span className="text-sm text-muted-foreground">Notes</span>
+                {(selectedResponse.notes ?? []).map((note) => (
+                  <div key={note.id} className="rounded border p-3 text-sm">
+                    <div className="flex items-center justify-between text-muted-foreground mb-1">
+                      <span>
+                        {note.author} ·{" "}
+                        {format(new Date(note.createdAt), "PPP at pp")}
+                      </span>
+                      <Button
+                        variant="ghost"
+                        size="sm"
+                        onClick={() =>
+                          handleDeleteNote(selectedResponse, note.id)
+                        }
+                      >
+                        <Trash2 className="h-4 w-4" />
+                      </Button>
+                    </div>
+                    <p className="whitespace-pre-wrap">{note.text}</p>
+                  </div>
+                ))}
+                <Textarea
+                  value={noteText}
+                  onChange={(e) => setNoteText(e.target.value)}
+                  placeholder="Add an internal note"
+                />
+                <div className="flex justify-end">
+                  <Button
+                    onClick={() => handleAddNote(selectedResponse)}
+                    disabled={!noteText.trim()}
+                  >
+                    Add Note
+                  </Button>
+                </div>
+              </div>
+            </CardContent>
+          </Card>
+
           <Card>
             <CardHeader>
               <CardTitle>Form Data</CardTitle>
@@ -231,6 +596,8 @@ export function ResponsesView({ form, onBack }: ResponsesViewProps) {
             </CardContent>
           </Card>
         </div>
+
+        {deleteDialog}
       </div>
     );
   }
@@ -245,16 +612,92 @@ export function ResponsesView({ form, onBack }: ResponsesViewProps) {
           <div>
             <h2 className="text-2xl font-bold">{form.title} - Responses</h2>
             <p className="text-muted-foreground">
-              {responses.length} total response
-              {responses.length !== 1 ? "s" : ""}
+              {total} {search ? "matching" : "total"} response
+              {total !== 1 ? "s" : ""}
             </p>
           </div>
           <div className="flex gap-2">
+            <Select
+              value={statusFilter}
+              onValueChange={(value) =>
+                setStatusFilter(value as ReviewStatus | "all")
+              }
+            >
+              <SelectTrigger className="w-36">
+                <SelectValue />
+              </SelectTrigger>
+              <SelectContent>
+                <SelectItem value="all">All statuses</SelectItem>
+                {Object.entries(reviewStatusLabels).map(([status, label]) => (
+                  <SelectItem key={status} value={status}>
+                    {label}
+                  </SelectItem>
+                ))}
+              </SelectContent>
+            </Select>
+            <form
+              className="relative"
+              onSubmit={(e) => {
+                e.preventDefault();
+                setSearch(searchInput.trim());
+              }}
+            >
+              <Search className="absolute left-2 top-2.5 h-4 w-4 text-muted-foreground" />
+              <Input
+                value={searchInput}
+                onChange={(e) => setSearchInput(e.target.value)}
+                placeholder="Search responses"
+                className="pl-8 w-64"
+              />
+            </form>
+            <input
+              ref={importInputRef}
+              type="file"
+              accept=".csv,.json,.ndjson"
+              className="hidden"
+              onChange={(e) => {
+                const file = e.target.files?.[0];
+                if (file) handleImport(file);
+              }}
+            />
+            <Button
+              variant="outline"
+              onClick={() => importInputRef.current?.click()}
+              disabled={importing}
+              className="gap-2"
+            >
+              <Upload className="h-4 w-4" />
+              {importing ? "Importing..." : "Import"}
+            </Button>
             {responses.length > 0 && (
-              <Button onClick={exportToCSV} className="gap-2">
-                <Download className="h-4 w-4" />
-                Export CSV
-              </Button>
+              <>
+                <Button
+                  onClick={() => exportResponses("csv")}
+                  className="gap-2"
+                >
+                  <Download className="h-4 w-4" />
+                  Export CSV
+                </Button>
+                <Button
+                  variant="outline"
+                  onClick={() => exportResponses("xlsx")}
+                  className="gap-2"
+                >
+                  <Download className="h-4 w-4" />
+                  Export XLSX
+                </Button>
+                <Button
+                  variant="outline"
+                  onClick={exportSummary}
+                  disabled={!!summaryJob}
+                  className="gap-2"
+                >
+                  <FileText className="h-4 w-4" />
+                  {summaryJob
+                    ? `Summary ${Math.round(summaryJob.progress)}%`
+                    : "Summary PDF"}
+                </Button>
+              </>
             )}
           </div>
         </div>
@@ -266,9 +709,13 @@ export function ResponsesView({ form, onBack }: ResponsesViewProps) {
             <div className="mx-auto w-16 h-16 bg-muted rounded-full flex items-center justify-center mb-4">
               <Globe className="h-8 w-8 text-muted-foreground" />
             </div>
-            <h3 className="text-lg font-medium mb-2">No responses yet</h3>
+            <h3 className="text-lg font-medium mb-2">
+              {search ? "No matching responses" : "No responses yet"}
+            </h3>
             <p className="text-muted-foreground mb-4">
-              Share your form to start collecting responses.
+              {search
+                ? `No responses mention "${search}".`
+                : "Share your form to start collecting responses."}
             </p>
           </CardContent>
         </Card>
@@ -287,6 +734,7 @@ export function ResponsesView({ form, onBack }: ResponsesViewProps) {
                   <TableRow>
                     <TableHead>Submitted</TableHead>
                     <TableHead>IP Address</TableHead>
+                    <TableHead>Status</TableHead>
                     <TableHead>Preview</TableHead>
                     <TableHead className="w-[100px]">Actions</TableHead>
                   </TableRow>
@@ -304,30 +752,73 @@ export function ResponsesView({ form, onBack }: ResponsesViewProps) {
                         {response.ipAddress}
                       </TableCell>
                       <TableCell>
-                        <div className="flex flex-wrap gap-1">
-                          {form.fields.slice(0, 2).map((field) => {
-                            const value = response.data[field.id];
-                            if (!value) return null;
-                            return (
-                              <Badge
-                                key={field.id}
-                                variant="secondary"
-                                className="text-xs"
-                              >
-                                {field.label}:{" "}
-                                {Array.isArray(value)
-                                  ? value.join(", ")
-                                  : String(value).slice(0, 20)}
-                                {String(value).length > 20 ? "..." : ""}
+                        <Badge
+                          variant={
+                            response.status === "resolved"
+                              ? "secondary"
+                              : "outline"
+                          }
+                        >
+                          {reviewStatusLabels[response.status ?? "new"]}
+                        </Badge>
+                        {response.assignee && (
+                          <div className="text-xs text-muted-foreground mt-1">
+                            {response.assignee}
+                          </div>
+                        )}
+                      </TableCell>
+                      <TableCell>
+                        {highlights[response.id]?.length ? (
+                          <div className="space-y-1 text-sm">
+                            {highlights[response.id].map((snippet) => (
+                              <div key={snippet.fieldId}>
+                                <span className="text-muted-foreground">
+                                  {form.fields.find(
+                                    (field) => field.id === snippet.fieldId
+                                  )?.label ?? snippet.fieldId}
+                                  :{" "}
+                                </span>
+                                {snippet.parts.map((part, i) =>
+                                  part.match ? (
+                                    <mark
+                                      key={i}
+                                      className="bg-yellow-200 rounded px-0.5"
+                                    >
+                                      {part.text}
+                                    </mark>
+                                  ) : (
+                                    <span key={i}>{part.text}</span>
+                                  )
+                                )}
+                              </div>
+                            ))}
+                          </div>
+                        ) : (
+                          <div className="flex flex-wrap gap-1">
+                            {form.fields.slice(0, 2).map((field) => {
+                              const value = response.data[field.id];
+                              if (!value) return null;
+                              return (
+                                <Badge
+                                  key={field.id}
+                                  variant="secondary"
+                                  className="text-xs"
+                                >
+                                  {field.label}:{" "}
+                                  {Array.isArray(value)
+                                    ? value.join(", ")
+                                    : String(value).slice(0, 20)}
+                                  {String(value).length > 20 ? "..." : ""}
+                                </Badge>
+                              );
+                            })}
+                            {Object.keys(response.data).length > 2 && (
+                              <Badge variant="outline" className="text-xs">
+                                +{Object.keys(response.data).length - 2} more
                               </Badge>
-                            );
-                          })}
-                          {Object.keys(response.data).length > 2 && (
-                            <Badge variant="outline" className="text-xs">
-                              +{Object.keys(response.data).length - 2} more
-                            </Badge>
-                          )}
-                        </div>
+                            )}
+                          </div>
+                        )}
                       </TableCell>
                       <TableCell>
                         <Button
@@ -342,10 +833,25 @@ export function ResponsesView({ form, onBack }: ResponsesViewProps) {
                   ))}
                 </TableBody>
               </Table>
+              {nextCursor && (
+                <div className="flex justify-center py-4">
+                  <Button
+                    variant="outline"
+                    onClick={loadMore}
+                    disabled={loadingMore}
+                  >
+                    {loadingMore
+                      ? "Loading..."
+                      : `Load more (${responses.length} of ${total})`}
+                  </Button>
+                </div>
+              )}
             </ScrollArea>
           </CardContent>
         </Card>
       )}
+
+      {importDialog}
     </div>
   );
 }
@@ -1,14 +1,28 @@
 "use client"
 
-import { useState, useEffect } from "react"
+import { useState, useEffect, useRef, type FocusEvent } from "react"
 import { useParams } from "next/navigation"
 import { PublicForm } from "@/app/components/public-form"
 import { Card, CardContent, CardHeader, CardTitle } from "@/app/components/ui/card"
 import { Button } from "@/app/components/ui/button"
 import { CheckCircle, AlertCircle, Home } from "lucide-react"
-import { apiService, Form } from "@/lib/api"
+import { apiService, Attribution, Form, FormEvent } from "@/lib/api"
 import Link from "next/link"
 
+// getAttribution reads the referrer, utm_* parameters and ?tags= the form was opened with
+function getAttribution(): Attribution {
+  const params = new URLSearchParams(window.location.search)
+  const utm: NonNullable<Attribution["utm"]> = {}
+  for (const key of ["source", "medium", "campaign", "term", "content"] as const) {
+    const value = params.get(`utm_${key}`)
+    if (value) {
+      utm[key] = value
+    }
+  }
+  const tags = (params.get("tags") || "").split(",").map((tag) => tag.trim()).filter(Boolean)
+  return { referrer: document.referrer || undefined, utm, tags: tags.length > 0 ? tags : undefined }
+}
+
 export default function PublicFormPage() {
   const params = useParams()
   const formId = params.id as string
@@ -19,6 +33,8 @@ export default function PublicFormPage() {
   const [isSubmitting, setIsSubmitting] = useState(false)
   const [isSubmitted, setIsSubmitted] = useState(false)
   const [submitError, setSubmitError] = useState<string | null>(null)
+  const sessionId = useRef(crypto.randomUUID())
+  const started = useRef(false)
 
   useEffect(() => {
     const loadForm = async () => {
@@ -29,6 +45,9 @@ export default function PublicFormPage() {
         const formData = await apiService.getPublicForm(formId)
         console.log('Form data received:', formData)
         setForm(formData)
+        apiService.trackFormEvents(formId, sessionId.current, [
+          { type: "view", timestamp: new Date().toISOString() },
+        ])
       } catch (err) {
         console.error('Error loading form:', err)
         setError(err instanceof Error ? err.message : 'Failed to load form')
@@ -47,7 +66,7 @@ export default function PublicFormPage() {
     setSubmitError(null)
     
     try {
-      await apiService.submitFormResponse(formId, data)
+      await apiService.submitFormResponse(formId, data, sessionId.current, getAttribution())
       setIsSubmitted(true)
     } catch (err) {
       console.error('Error submitting form:', err)
@@ -60,6 +79,24 @@ export default function PublicFormPage() {
   const handleReset = () => {
     setIsSubmitted(false)
     setSubmitError(null)
+    sessionId.current = crypto.randomUUID()
+    started.current = false
+  }
+
+  // Field inputs use the field ID as their element ID, so focus events
+  // bubbling up from the form tell us which question the respondent is on
+  const handleFocus = (e: FocusEvent<HTMLDivElement>) => {
+    const fieldId = e.target.id
+    if (!form || !form.fields.some((field) => field.id === fieldId)) return
+
+    const timestamp = new Date().toISOString()
+    const events: FormEvent[] = []
+    if (!started.current) {
+      started.current = true
+      events.push({ type: "start", timestamp })
+    }
+    events.push({ type: "field_focus", fieldId, timestamp })
+    apiService.trackFormEvents(formId, sessionId.current, events)
   }
 
   if (loading) {
@@ -142,11 +179,13 @@ export default function PublicFormPage() {
 
   return (
     <>
-      <PublicForm 
-        form={form} 
-        onSubmit={handleSubmit}
-        isSubmitting={isSubmitting}
-      />
+      <div onFocusCapture={handleFocus}>
+        <PublicForm 
+          form={form} 
+          onSubmit={handleSubmit}
+          isSubmitting={isSubmitting}
+        />
+      </div>
       {submitError && (
         <div className="fixed bottom-4 right-4 max-w-sm">
           <Card className="border-destructive">
@@ -59,6 +59,18 @@ export function useForms(initialStatus?: string) {
     }
   }, []);
 
+  const restoreForm = useCallback(async (id: string) => {
+    try {
+      const restoredForm = await apiService.restoreForm(id);
+      setForms(prev => [restoredForm, ...prev.filter(form => form.id !== id)]);
+      return restoredForm;
+    } catch (err) {
+      const errorMessage = err instanceof Error ? err.message : 'Failed to restore form';
+      setError(errorMessage);
+      throw new Error(errorMessage);
+    }
+  }, []);
+
   const saveDraft = useCallback(async (id: string, formData: Omit<CreateFormRequest, 'status'>) => {
     try {
       const response = await apiService.saveDraft(id, formData);
@@ -131,6 +143,7 @@ export function useForms(initialStatus?: string) {
     createForm,
     updateForm,
     deleteForm,
+    restoreForm,
     saveDraft,
     publishForm,
     unpublishForm,
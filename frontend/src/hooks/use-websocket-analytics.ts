@@ -2,6 +2,7 @@
 
 import { useEffect, useState, useCallback, useRef } from 'react';
 import { getWebSocketClient, WebSocketClient, WSMessage } from '@/lib/websocket';
+import type { ExportJob } from '@/lib/api';
 
 export interface AnalyticsData {
   formId: string;
@@ -52,10 +53,25 @@ export interface NewResponseData {
   device: string;
 }
 
+export interface AnomalyAlert {
+  formId: string;
+  formTitle: string;
+  kind: 'spike' | 'drop';
+  message: string;
+  windowStart: string;
+  windowEnd: string;
+  observed: number;
+  expected: number;
+  baselineDays: number;
+  detectedAt: string;
+}
+
 interface UseWebSocketAnalyticsOptions {
   formId: string;
   onNewResponse?: (response: NewResponseData) => void;
   onAnalyticsUpdate?: (analytics: AnalyticsData) => void;
+  onAlert?: (alert: AnomalyAlert) => void;
+  onExportProgress?: (job: ExportJob) => void;
   autoConnect?: boolean;
 }
 
@@ -77,6 +93,8 @@ export function useWebSocketAnalytics({
   formId,
   onNewResponse,
   onAnalyticsUpdate,
+  onAlert,
+  onExportProgress,
   autoConnect = true
 }: UseWebSocketAnalyticsOptions): UseWebSocketAnalyticsReturn {
   const [analytics, setAnalytics] = useState<AnalyticsData | null>(null);
@@ -149,6 +167,26 @@ export function useWebSocketAnalytics({
     onAnalyticsUpdate?.(data);
   }, [onAnalyticsUpdate]);
 
+  const handleResponsesDeleted = useCallback((ids: string[]) => {
+    const deleted = new Set(ids);
+    setAnalytics(prev => {
+      if (!prev) return prev;
+      return {
+        ...prev,
+        recentResponses: prev.recentResponses.filter(r => !deleted.has(r.id))
+      };
+    });
+  }, []);
+
+  const handleAlert = useCallback((data: AnomalyAlert) => {
+    console.log('Anomaly alert received:', data);
+    onAlert?.(data);
+  }, [onAlert]);
+
+  const handleExportProgress = useCallback((data: ExportJob) => {
+    onExportProgress?.(data);
+  }, [onExportProgress]);
+
   // Connect to WebSocket
   const connect = useCallback(() => {
     if (wsClientRef.current?.isConnected()) {
@@ -191,6 +229,29 @@ export function useWebSocketAnalytics({
               handleAnalyticsUpdate(message.data as AnalyticsData);
             }
             break;
+          case 'response_deleted':
+            // Totals follow in the next analytics_update
+            if (message.formId === formId && message.data?.ids) {
+              handleResponsesDeleted(message.data.ids as string[]);
+            }
+            break;
+          case 'alert':
+            if (message.formId === formId && message.data) {
+              handleAlert(message.data as AnomalyAlert);
+            }
+            break;
+          case 'response_updated':
+            // Review changes don't affect the figures
+            break;
+          case 'responses_imported':
+            // A single summary per import; the figures follow in the next
+            // analytics_update
+            break;
+          case 'export_progress':
+            if (message.formId === formId && message.data) {
+              handleExportProgress(message.data as ExportJob);
+            }
+            break;
           case 'heartbeat':
             // Keep connection alive
             break;
@@ -206,7 +267,7 @@ export function useWebSocketAnalytics({
 
     client.connect();
     wsClientRef.current = client;
-  }, [formId, handleNewResponse, handleAnalyticsUpdate]);
+  }, [formId, handleNewResponse, handleAnalyticsUpdate, handleResponsesDeleted, handleAlert, handleExportProgress]);
 
   // Disconnect from WebSocket
   const disconnect = useCallback(() => {
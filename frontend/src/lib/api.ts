@@ -15,6 +15,31 @@ export interface FormField {
   placeholder?: string;
   required: boolean;
   options?: string[];
+  pii?: boolean; // answers identify the respondent
+}
+
+// Responses older than days are deleted or anonymized
+export interface RetentionPolicy {
+  days: number;
+  action: "delete" | "anonymize";
+}
+
+export interface RetentionResult {
+  formId: string;
+  action: RetentionPolicy["action"];
+  days: number;
+  cutoff: string;
+  deleted: number;
+  anonymized: number;
+  partialsDeleted: number;
+  error?: string;
+}
+
+export interface RetentionRun {
+  id: string;
+  startedAt: string;
+  completedAt: string;
+  forms: RetentionResult[];
 }
 
 export interface Form {
@@ -28,6 +53,9 @@ export interface Form {
   isActive: boolean;
   userId: string;
   responseCount?: number;
+  retention?: RetentionPolicy;
+  deletedAt?: string; // set while the form is in the trash
+  purgeAt?: string; // when a deleted form is removed for good
 }
 
 export interface CreateFormRequest {
@@ -43,6 +71,7 @@ export interface UpdateFormRequest {
   fields?: FormField[];
   status?: string;
   isActive?: boolean;
+  retention?: RetentionPolicy; // days 0 removes the policy
 }
 
 export interface FormResponse {
@@ -52,6 +81,105 @@ export interface FormResponse {
   createdAt: string;
   ipAddress: string;
   userAgent: string;
+  tags?: string[];
+  status?: ReviewStatus;
+  assignee?: string;
+  notes?: ResponseNote[];
+  anonymizedAt?: string; // set once retention stripped its personal data
+}
+
+export type ReviewStatus = "new" | "in_review" | "resolved";
+
+// An internal note left on a response by a reviewer
+export interface ResponseNote {
+  id: string;
+  author: string;
+  text: string;
+  createdAt: string;
+}
+
+// One page of a form's responses; pass nextCursor back to get the next page
+export interface ResponsePage {
+  responses: FormResponse[];
+  total: number;
+  nextCursor?: string;
+  hasMore: boolean;
+  // Snippets of the answers a search matched, keyed by response ID
+  highlights?: Record<string, Snippet[]>;
+}
+
+export interface Snippet {
+  fieldId: string;
+  parts: { text: string; match?: boolean }[];
+}
+
+// Listing options: sort is "createdAt" or a field ID, and filters map query
+// parameters such as from, to, tag or field.<id>[.contains|.min|.max]
+export interface ResponseListOptions {
+  search?: string;
+  cursor?: string;
+  limit?: number;
+  sort?: string;
+  order?: "asc" | "desc";
+  filters?: Record<string, string>;
+}
+
+// The outcome of an import; rows are numbered from 1, not counting a CSV
+// header, and only the first 100 errors are listed
+export interface ImportResult {
+  rows: number;
+  imported: number;
+  failed: number;
+  errors: { row: number; error: string }[];
+  dryRun?: boolean;
+}
+
+export interface ImportOptions {
+  // Column names or JSON keys to field IDs; unmapped columns are matched by
+  // field ID or label
+  mapping?: Record<string, string>;
+  tz?: string;
+  dryRun?: boolean;
+}
+
+export type ExportFormat = "csv" | "xlsx" | "json" | "ndjson" | "pdf";
+
+// A background export; poll it, or follow export_progress WebSocket
+// messages, until it completes and can be downloaded
+export interface ExportJob {
+  id: string;
+  formId: string;
+  format: ExportFormat;
+  status: "queued" | "running" | "completed" | "failed" | "expired";
+  total: number;
+  processed: number;
+  progress: number;
+  error?: string;
+  filename?: string;
+  size?: number;
+  createdAt: string;
+  completedAt?: string;
+  expiresAt?: string;
+}
+
+// Where the respondent came from: the page that linked to the form, the
+// utm_* parameters on the form's URL and any tags it carries
+export interface Attribution {
+  referrer?: string;
+  tags?: string[];
+  utm?: {
+    source?: string;
+    medium?: string;
+    campaign?: string;
+    term?: string;
+    content?: string;
+  };
+}
+
+export interface FormEvent {
+  type: "view" | "start" | "field_focus" | "submit";
+  fieldId?: string;
+  timestamp?: string;
 }
 
 class ApiService {
@@ -108,9 +236,21 @@ class ApiService {
     });
   }
 
-  async deleteForm(id: string): Promise<{ message: string }> {
-    return this.request<{ message: string }>(`/forms/${id}`, {
-      method: "DELETE",
+  // Moves a form to the trash, where it can be restored until purgeAt
+  async deleteForm(
+    id: string
+  ): Promise<{ message: string; purgeAt?: string }> {
+    return this.request<{ message: string; purgeAt?: string }>(
+      `/forms/${id}`,
+      {
+        method: "DELETE",
+      }
+    );
+  }
+
+  async restoreForm(id: string): Promise<Form> {
+    return this.request<Form>(`/forms/${id}/restore`, {
+      method: "POST",
     });
   }
 
@@ -148,16 +288,164 @@ class ApiService {
 
   async submitFormResponse(
     formId: string,
-    data: Record<string, unknown>
+    data: Record<string, unknown>,
+    sessionId?: string,
+    attribution?: Attribution
   ): Promise<{ message: string; id: string }> {
     return this.request<{ message: string; id: string }>("/responses", {
       method: "POST",
-      body: JSON.stringify({ formId, data }),
+      body: JSON.stringify({ formId, data, sessionId, ...attribution }),
+    });
+  }
+
+  trackFormEvents(formId: string, sessionId: string, events: FormEvent[]) {
+    const url = `${API_BASE_URL}/public/forms/${formId}/events`;
+    const body = JSON.stringify({ sessionId, events });
+
+    // Beacons survive page unloads; fall back to fetch where unavailable
+    if (typeof navigator !== "undefined" && navigator.sendBeacon?.(url, body)) {
+      return;
+    }
+    fetch(url, { method: "POST", body, keepalive: true }).catch(() => {});
+  }
+
+  async getResponse(id: string): Promise<FormResponse> {
+    return this.request<FormResponse>(`/responses/${id}`);
+  }
+
+  async updateResponse(
+    id: string,
+    updates: {
+      data?: Record<string, unknown>;
+      tags?: string[];
+      status?: ReviewStatus;
+      assignee?: string;
+    }
+  ): Promise<FormResponse> {
+    return this.request<FormResponse>(`/responses/${id}`, {
+      method: "PATCH",
+      body: JSON.stringify(updates),
+    });
+  }
+
+  async addResponseNote(id: string, text: string): Promise<ResponseNote> {
+    return this.request<ResponseNote>(`/responses/${id}/notes`, {
+      method: "POST",
+      body: JSON.stringify({ text }),
+    });
+  }
+
+  async deleteResponseNote(
+    id: string,
+    noteId: string
+  ): Promise<{ message: string }> {
+    return this.request<{ message: string }>(
+      `/responses/${id}/notes/${noteId}`,
+      { method: "DELETE" }
+    );
+  }
+
+  async deleteResponse(id: string): Promise<{ message: string }> {
+    return this.request<{ message: string }>(`/responses/${id}`, {
+      method: "DELETE",
     });
   }
 
-  async getFormResponses(formId: string): Promise<FormResponse[]> {
-    return this.request<FormResponse[]>(`/responses/form/${formId}`);
+  // Deletes responses by ID, or all responses matching filters and/or search
+  async bulkDeleteResponses(
+    formId: string,
+    selection: { ids?: string[]; filter?: Record<string, unknown>; q?: string }
+  ): Promise<{ message: string; deleted: number }> {
+    return this.request<{ message: string; deleted: number }>(
+      `/responses/form/${formId}/bulk-delete`,
+      {
+        method: "POST",
+        body: JSON.stringify(selection),
+      }
+    );
+  }
+
+  async getFormResponses(
+    formId: string,
+    options: ResponseListOptions = {}
+  ): Promise<ResponsePage> {
+    const params = new URLSearchParams(options.filters);
+    if (options.search) params.set("q", options.search);
+    if (options.cursor) params.set("cursor", options.cursor);
+    if (options.limit) params.set("limit", String(options.limit));
+    if (options.sort) params.set("sort", options.sort);
+    if (options.order) params.set("order", options.order);
+    const query = params.toString();
+    return this.request<ResponsePage>(
+      `/responses/form/${formId}${query ? `?${query}` : ""}`
+    );
+  }
+
+  // importResponses uploads a CSV or JSON file of historical responses
+  async importResponses(
+    formId: string,
+    file: File,
+    options: ImportOptions = {}
+  ): Promise<ImportResult> {
+    const body = new FormData();
+    body.append("file", file);
+    if (options.mapping) body.append("mapping", JSON.stringify(options.mapping));
+    if (options.tz) body.append("tz", options.tz);
+    if (options.dryRun) body.append("dryRun", "true");
+
+    // The browser sets the multipart Content-Type and boundary itself
+    const response = await fetch(
+      `${API_BASE_URL}/responses/form/${formId}/import`,
+      { method: "POST", body }
+    );
+    const data = await response.json().catch(() => ({}));
+    if (!response.ok) {
+      throw new Error(data.error || `HTTP error! status: ${response.status}`);
+    }
+    return data;
+  }
+
+  async createExport(
+    formId: string,
+    format: ExportFormat,
+    options: ResponseListOptions = {}
+  ): Promise<ExportJob> {
+    return this.request<ExportJob>(`/forms/${formId}/exports`, {
+      method: "POST",
+      body: JSON.stringify({
+        format,
+        q: options.search,
+        sort: options.sort,
+        order: options.order,
+      }),
+    });
+  }
+
+  async getExport(formId: string, jobId: string): Promise<ExportJob> {
+    return this.request<ExportJob>(`/forms/${formId}/exports/${jobId}`);
+  }
+
+  async getRetentionRuns(formId: string): Promise<RetentionRun[]> {
+    return this.request<RetentionRun[]>(`/forms/${formId}/retention/runs`);
+  }
+
+  getExportDownloadUrl(formId: string, jobId: string): string {
+    return `${API_BASE_URL}/forms/${formId}/exports/${jobId}/download`;
+  }
+
+  // getResponsesExportUrl returns the download URL of a CSV or XLSX export
+  // of the responses matching options; the cursor and limit are ignored
+  getResponsesExportUrl(
+    formId: string,
+    format: "csv" | "xlsx",
+    options: ResponseListOptions = {}
+  ): string {
+    const params = new URLSearchParams(options.filters);
+    params.set("format", format);
+    if (options.search) params.set("q", options.search);
+    if (options.sort) params.set("sort", options.sort);
+    if (options.order) params.set("order", options.order);
+    return `${API_BASE_URL}/responses/form/${formId}/export?${params.toString()}`;
   }
 }
 
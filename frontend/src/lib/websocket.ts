@@ -1,7 +1,7 @@
 // WebSocket client for real-time updates
 
 export interface WSMessage {
-  type: 'new_response' | 'analytics_update' | 'heartbeat' | 'subscribed' | 'unsubscribed' | 'pong';
+  type: 'new_response' | 'analytics_update' | 'alert' | 'response_deleted' | 'response_updated' | 'export_progress' | 'responses_imported' | 'heartbeat' | 'subscribed' | 'unsubscribed' | 'pong';
   data?: any;
   timestamp: string;
   formId?: string;